@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/node"
 	"github.com/spf13/cobra"
 )
 
@@ -74,3 +76,46 @@ func balancesGetCmd() *cobra.Command {
 
 	return balancesGetCmd
 }
+
+// balancesHistoryCmd represents the balances history command
+func balancesHistoryCmd() *cobra.Command {
+	var balancesHistoryCmd = &cobra.Command{
+		Use:     "history",
+		Short:   "List transactions sent or received by address.",
+		PreRunE: prepareBlockChain,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			address, _ := cmd.Flags().GetString("address")
+			if !common.IsHexAddress(address) {
+				return fmt.Errorf("invalid address")
+			}
+
+			cursor, _ := cmd.Flags().GetString("cursor")
+			limit, _ := cmd.Flags().GetInt("limit")
+			order, _ := cmd.Flags().GetString("order")
+			if order != "asc" && order != "desc" {
+				return fmt.Errorf("invalid order: %s", order)
+			}
+
+			defer blockChain.Shutdown()
+
+			txs, next, err := blockChain.TransactionsByAddress(common.HexToAddress(address), cursor, limit, order == "desc")
+			if err != nil {
+				return err
+			}
+
+			return writeOutput(cmd, node.AddressTxsResult{
+				Txs:        txs,
+				NextCursor: next,
+			})
+		},
+	}
+
+	addAddressFlag(balancesHistoryCmd)
+	balancesHistoryCmd.Flags().String("cursor", "", "Cursor returned with previous page")
+	balancesHistoryCmd.Flags().Int("limit", core.DefaultAddressTxsLimit, "Limit to show")
+	balancesHistoryCmd.Flags().String("order", "asc", "Transactions order (asc/desc)")
+
+	addOutputFormatFlag(balancesHistoryCmd)
+
+	return balancesHistoryCmd
+}
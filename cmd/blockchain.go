@@ -18,6 +18,7 @@ func blockchainCmd() *cobra.Command {
 	blockchainCmd.AddCommand(blockchainListCmd())
 	blockchainCmd.AddCommand(blockchainLastBlockCmd())
 	blockchainCmd.AddCommand(blockchainGenesisCmd())
+	blockchainCmd.AddCommand(blockchainMigrateCmd())
 
 	return blockchainCmd
 }
@@ -46,6 +47,10 @@ func initBlockChainCmd() *cobra.Command {
 	}
 
 	addNetworkIdFlag(initBlockChainCmd)
+	initBlockChainCmd.Flags().StringSlice("signers", nil, "Genesis authorized block signers, network treasurer accounts are used by default")
+	bindViperFlag(initBlockChainCmd, "genesis.signers", "signers")
+	initBlockChainCmd.Flags().Int64("block-time", 15, "Genesis minimal seconds between blocks, 0 allows to seal blocks without delay")
+	bindViperFlag(initBlockChainCmd, "genesis.block_time", "block-time")
 
 	return initBlockChainCmd
 }
@@ -145,3 +150,23 @@ func blockchainGenesisCmd() *cobra.Command {
 
 	return blockchainGenesisCmd
 }
+
+// blockchainMigrateCmd represents the blockchain migrate command
+func blockchainMigrateCmd() *cobra.Command {
+	var blockchainMigrateCmd = &cobra.Command{
+		Use:     "migrate",
+		Short:   "Re-encode chain database to the current format version",
+		Long:    `Replays canonical chain stored with previous database format version into a new database, which replaces the stored one once every block is replayed. Side chain blocks are dropped.`,
+		PreRunE: prepareBlockChain,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			defer blockChain.Shutdown()
+
+			return blockChain.Migrate(ctx)
+		},
+		TraverseChildren: true,
+	}
+
+	return blockchainMigrateCmd
+}
@@ -0,0 +1,23 @@
+// Copyright 2021 The rbn Authors
+// This file is part of the rbn library.
+//
+// The rbn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The rbn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the rbn library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import "github.com/rovergulf/chain/cmd"
+
+func main() {
+	cmd.Execute()
+}
@@ -14,7 +14,7 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the rbn library. If not, see <http://www.gnu.org/licenses/>.
 
-package main
+package cmd
 
 import (
 	"fmt"
@@ -40,10 +40,6 @@ var (
 	localNode        *node.Node
 )
 
-func main() {
-	Execute()
-}
-
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -86,6 +82,7 @@ func init() {
 	rootCmd.AddCommand(balancesCmd)
 	balancesCmd.AddCommand(balancesListCmd())
 	balancesCmd.AddCommand(balancesGetCmd())
+	balancesCmd.AddCommand(balancesHistoryCmd())
 
 	// chain
 	rootCmd.AddCommand(blockchainCmd())
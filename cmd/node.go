@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/rovergulf/chain/node"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"strconv"
@@ -24,6 +26,7 @@ func nodeCmd() *cobra.Command {
 
 	nodeCmd.AddCommand(nodeRunCmd())
 	nodeCmd.AddCommand(nodeStopCmd())
+	nodeCmd.AddCommand(nodeSyncStatusCmd())
 	nodeCmd.AddCommand(nodeAccountDumpCmd())
 	//nodeCmd.AddCommand(nodeAccountImportCmd())
 
@@ -62,6 +65,32 @@ func nodeRunCmd() *cobra.Command {
 	bindViperFlag(nodeRunCmd, "http.addr", "http-addr")
 	nodeRunCmd.Flags().Int("http-port", 9469, "Node port would listen to accept Web API Requests")
 	bindViperFlag(nodeRunCmd, "http.port", "http-port")
+	// transactions pool
+	nodeRunCmd.Flags().String("txpool-journal", "transactions.rlp", "Local transactions journal file, relative to data directory")
+	bindViperFlag(nodeRunCmd, "txpool.journal", "txpool-journal")
+	nodeRunCmd.Flags().Int("txpool-rejournal", 3600, "Local transactions journal rotation interval in seconds")
+	bindViperFlag(nodeRunCmd, "txpool.rejournal", "txpool-rejournal")
+	nodeRunCmd.Flags().Uint64("txpool-pricebump", 10, "Minimal nether price increase percentage to replace pool transaction")
+	bindViperFlag(nodeRunCmd, "txpool.pricebump", "txpool-pricebump")
+
+	nodeRunCmd.Flags().Int("block-time", 15, "Seconds between sealed blocks")
+	bindViperFlag(nodeRunCmd, "node.block_time", "block-time")
+	nodeRunCmd.Flags().Bool("skip-empty-blocks", true, "Do not seal blocks without transactions")
+	bindViperFlag(nodeRunCmd, "node.skip_empty_blocks", "skip-empty-blocks")
+	nodeRunCmd.Flags().Bool("dev-seal", false, "Seal block as soon as transaction is added to the pool")
+	bindViperFlag(nodeRunCmd, "node.dev_seal", "dev-seal")
+	// consensus
+	nodeRunCmd.Flags().String("consensus", node.EnginePoA, "Consensus engine: poa or raft for permissioned networks")
+	bindViperFlag(nodeRunCmd, "consensus.engine", "consensus")
+	nodeRunCmd.Flags().String("raft-addr", node.DefaultRaftAddr, "Raft transport address would listen to")
+	bindViperFlag(nodeRunCmd, "raft.addr", "raft-addr")
+	nodeRunCmd.Flags().Bool("raft-bootstrap", false, "Bootstrap a new raft cluster with this node as the only voter")
+	bindViperFlag(nodeRunCmd, "raft.bootstrap", "raft-bootstrap")
+	// chain sync
+	nodeRunCmd.Flags().String("sync-mode", node.SyncModeDefault.String(), "Chain sync mode: default to sync headers only, full to download and execute blocks, or account to download only wallets accounts transactions")
+	bindViperFlag(nodeRunCmd, "node.sync_mode", "sync-mode")
+	nodeRunCmd.Flags().Int("sync-interval", 5, "Seconds between chain sync attempts")
+	bindViperFlag(nodeRunCmd, "node.sync_interval", "sync-interval")
 	// JSONRpc 2.0 – TBD (??)
 	//nodeRunCmd.Flags().String("jrpc-addr", "127.0.0.1", "Node address would listen to")
 	//bindViperFlag(nodeRunCmd, "jrpc.addr", "jrpc-addr")
@@ -110,6 +139,42 @@ func nodeStopCmd() *cobra.Command {
 	return nodeStopCmd
 }
 
+func nodeSyncStatusCmd() *cobra.Command {
+	var nodeSyncStatusCmd = &cobra.Command{
+		Use:   "sync-status",
+		Short: "Show running node chain sync progress",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			httpAddr, _ := cmd.Flags().GetString("http-addr")
+			httpPort, _ := cmd.Flags().GetInt("http-port")
+
+			res, err := http.Get(fmt.Sprintf("http://%s:%d/node/info", httpAddr, httpPort))
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+
+			var result map[string]interface{}
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				return err
+			}
+
+			if res.StatusCode != http.StatusOK {
+				return fmt.Errorf("unable to get sync status: %v", result["error"])
+			}
+
+			return writeOutput(cmd, result["sync"])
+		},
+		TraverseChildren: true,
+	}
+
+	// flags are not bound to viper http config, as it would override 'node run' bindings
+	nodeSyncStatusCmd.Flags().String("http-addr", "127.0.0.1", "Node Web API address")
+	nodeSyncStatusCmd.Flags().Int("http-port", 9469, "Node Web API port")
+	addOutputFormatFlag(nodeSyncStatusCmd)
+
+	return nodeSyncStatusCmd
+}
+
 func nodeAccountDumpCmd() *cobra.Command {
 	nodeAccountDumpCmd := &cobra.Command{
 		Use:   "account-dump",
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/node"
 	"github.com/spf13/cobra"
+	"net/http"
 )
 
 func txCmd() *cobra.Command {
@@ -18,6 +24,8 @@ func txCmd() *cobra.Command {
 
 	txCmd.AddCommand(txSendCmd())
 	txCmd.AddCommand(txGetCmd())
+	txCmd.AddCommand(txSpeedupCmd())
+	txCmd.AddCommand(txCancelCmd())
 
 	return txCmd
 }
@@ -36,12 +44,16 @@ func txGetCmd() *cobra.Command {
 				return err
 			}
 
-			tx, err := blockChain.FindTransaction(common.HexToHash(id))
+			tx, lookup, err := blockChain.FindTransaction(common.HexToHash(id))
 			if err != nil {
 				return err
 			}
 
-			return writeOutput(cmd, tx)
+			return writeOutput(cmd, node.TxResult{
+				Status: types.TxStatusMined,
+				Tx:     tx,
+				Lookup: lookup,
+			})
 		},
 		TraverseChildren: true,
 	}
@@ -62,7 +74,7 @@ func txSendCmd() *cobra.Command {
 		Args: func(cmd *cobra.Command, args []string) error {
 			from, _ := cmd.Flags().GetString("from")
 			to, _ := cmd.Flags().GetString("to")
-			amount, _ := cmd.Flags().GetInt("amount")
+			amount, _ := cmd.Flags().GetString("amount")
 			fmt.Println(from, to, amount)
 			return nil
 		},
@@ -75,7 +87,7 @@ func txSendCmd() *cobra.Command {
 			//dataFormat, _ := cmd.Flags().GetString("data-format")
 			from, _ := cmd.Flags().GetString("address")
 			to, _ := cmd.Flags().GetString("to")
-			amount, _ := cmd.Flags().GetFloat64("amount")
+			amountValue, _ := cmd.Flags().GetString("amount")
 
 			if !common.IsHexAddress(to) {
 				return fmt.Errorf("recipient address is not Valid")
@@ -85,7 +97,12 @@ func txSendCmd() *cobra.Command {
 				return fmt.Errorf("sender address is not Valid")
 			}
 
-			if amount <= 0 {
+			amount, err := core.ParseCoinAmount(amountValue)
+			if err != nil {
+				return err
+			}
+
+			if amount.Sign() <= 0 {
 				return fmt.Errorf("amount must be more than 0")
 			}
 
@@ -124,7 +141,7 @@ func txSendCmd() *cobra.Command {
 	}
 
 	txSendCmd.Flags().String("to", "", "Receiver address")
-	txSendCmd.Flags().Int("amount", 0, "Transaction coin amount")
+	txSendCmd.Flags().String("amount", "", "Transaction coins amount, like 1.5")
 	txSendCmd.MarkFlagRequired("to")
 	txSendCmd.MarkFlagRequired("amount")
 
@@ -132,3 +149,94 @@ func txSendCmd() *cobra.Command {
 
 	return txSendCmd
 }
+
+// txSpeedupCmd represents the speedup command
+func txSpeedupCmd() *cobra.Command {
+	var txSpeedupCmd = &cobra.Command{
+		Use:   "speedup",
+		Short: "Replace pending transaction with the same one with higher nether price",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return replaceTx(cmd, false)
+		},
+		TraverseChildren: true,
+	}
+
+	txSpeedupCmd.Flags().Uint64("nether-price", 0, "Replacement nether price, minimal required price is used if lower")
+	addTxReplaceFlags(txSpeedupCmd)
+
+	return txSpeedupCmd
+}
+
+// txCancelCmd represents the cancel command
+func txCancelCmd() *cobra.Command {
+	var txCancelCmd = &cobra.Command{
+		Use:   "cancel",
+		Short: "Replace pending transaction with zero value transaction sent to yourself",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return replaceTx(cmd, true)
+		},
+		TraverseChildren: true,
+	}
+
+	addTxReplaceFlags(txCancelCmd)
+
+	return txCancelCmd
+}
+
+func addTxReplaceFlags(cmd *cobra.Command) {
+	cmd.Flags().Uint64("nonce", 0, "Pending transaction nonce")
+	cmd.MarkFlagRequired("nonce")
+	// flags are not bound to viper http config, as it would override 'node run' bindings
+	cmd.Flags().String("http-addr", "127.0.0.1", "Node Web API address")
+	cmd.Flags().Int("http-port", 9469, "Node Web API port")
+
+	addAddressFlag(cmd)
+	addOutputFormatFlag(cmd)
+}
+
+// replaceTx requests running node to replace its pool transaction
+func replaceTx(cmd *cobra.Command, cancel bool) error {
+	address, _ := cmd.Flags().GetString("address")
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("sender address is not Valid")
+	}
+
+	nonce, _ := cmd.Flags().GetUint64("nonce")
+	netherPrice, _ := cmd.Flags().GetUint64("nether-price")
+	httpAddr, _ := cmd.Flags().GetString("http-addr")
+	httpPort, _ := cmd.Flags().GetInt("http-port")
+
+	auth, err := getPassPhrase("Enter passphrase to decrypt wallet:", false)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(node.TxReplaceRequest{
+		From:        address,
+		FromPwd:     auth,
+		Nonce:       nonce,
+		NetherPrice: netherPrice,
+		Cancel:      cancel,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("http://%s:%d/tx/replace", httpAddr, httpPort)
+	res, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to replace transaction: %v", result["error"])
+	}
+
+	return writeOutput(cmd, result)
+}
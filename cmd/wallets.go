@@ -7,6 +7,7 @@ import (
 	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/rovergulf/chain/wallets"
 	"github.com/spf13/cobra"
+	"github.com/tyler-smith/go-bip39"
 	"io/ioutil"
 	"path"
 )
@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+)
+
+const (
+	ElectionRoundRobin = "round_robin" // validators take turns by block number
+	ElectionRandom     = "random"      // validators are ranked by the parent block hash, which its author may grind
+)
+
+// Election orders validators to seal the block, so every node computes the same block author
+type Election interface {
+	// Elect returns validators ordered by their priority to seal block of provided number on top
+	// of the parent block hash. The first one is the winner, and the rest are fallback authors
+	Elect(number uint64, parentHash common.Hash, validators []common.Address) []common.Address
+}
+
+// NewElection returns election by its name, round robin one is used by default
+func NewElection(name string) (Election, error) {
+	switch name {
+	case "", ElectionRoundRobin:
+		return RoundRobin{}, nil
+	case ElectionRandom:
+		return Random{}, nil
+	default:
+		return nil, fmt.Errorf("unknown election: %s", name)
+	}
+}
+
+// RoundRobin election winner is the validator at block number position of the ascending validators list,
+// and fallback authors are the validators following it
+type RoundRobin struct{}
+
+func (RoundRobin) Elect(number uint64, parentHash common.Hash, validators []common.Address) []common.Address {
+	sorted := sortedValidators(validators)
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	offset := int(number % uint64(len(sorted)))
+	return append(sorted[offset:], sorted[:offset]...)
+}
+
+// Random election ranks validators by the hash of the parent block hash and validator address,
+// so the winner is unpredictable until the parent block is sealed. It is biased: the parent block author
+// may grind its hash by the transactions selection, timestamp or vote to win the next block as well,
+// so it is only suitable for signers trusting each other, and is not used by default
+type Random struct{}
+
+func (Random) Elect(number uint64, parentHash common.Hash, validators []common.Address) []common.Address {
+	sorted := sortedValidators(validators)
+
+	scores := make(map[common.Address][]byte, len(sorted))
+	for _, validator := range sorted {
+		score := sha256.Sum256(append(parentHash.Bytes(), validator.Bytes()...))
+		scores[validator] = score[:]
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(scores[sorted[i]], scores[sorted[j]]) < 0
+	})
+
+	return sorted
+}
+
+// sortedValidators returns a copy of validators in ascending order
+func sortedValidators(validators []common.Address) []common.Address {
+	sorted := make([]common.Address, len(validators))
+	copy(sorted, validators)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+
+	return sorted
+}
@@ -0,0 +1,31 @@
+package consensus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewElection(t *testing.T) {
+	tests := []struct {
+		name     string
+		election Election
+		err      bool
+	}{
+		{name: "", election: RoundRobin{}},
+		{name: ElectionRoundRobin, election: RoundRobin{}},
+		{name: ElectionRandom, election: Random{}},
+		{name: "unknown", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			election, err := NewElection(tt.name)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(election, tt.election) {
+				t.Fatalf("expected %T election, got %T", tt.election, election)
+			}
+		})
+	}
+}
@@ -2,22 +2,63 @@ package consensus
 
 import (
 	"context"
+	"errors"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/rovergulf/chain/core/types"
 )
 
+var (
+	ErrNotAuthor = errors.New("node account is not eligible to seal block")
+)
+
+// SignerFn signs transaction with the node account key
+type SignerFn func(tx *types.Transaction) (*types.SignedTx, error)
+
+// SignHashFn signs block header seal hash with the node account key
+type SignHashFn func(hash []byte) ([]byte, error)
+
+// ChainReader provides stored chain headers to the consensus engine
+type ChainReader interface {
+	// GetBlockHeader returns stored block header by its hash
+	GetBlockHeader(hash common.Hash) (*types.BlockHeader, error)
+}
+
 // Engine is the consensus Node interface
 type Engine interface {
-	// Author returns a consensus game winner who would mine block and take the most of nether pool award
-	Author(ctx context.Context) (common.Address, error)
+	// Author returns a consensus game winner who would mine block on top of provided parent
+	// and take the most of nether pool award
+	Author(ctx context.Context, parent *types.BlockHeader) (common.Address, error)
 
-	// VerifyHeader waits for required amount of network verifications
-	VerifyHeader(header types.BlockHeader, sig []byte) error
+	// Authors returns accounts eligible to seal block on top of provided parent in their priority order.
+	// The first one is the Author, and the rest are fallback authors in case it has missed its slot
+	Authors(ctx context.Context, parent *types.BlockHeader) ([]common.Address, error)
 
-	Prepare(ctx context.Context, block *types.Block) error
+	// VerifyHeader checks block header is sealed according to consensus rules
+	VerifyHeader(header *types.BlockHeader) error
 
-	Apply(ctx context.Context) error
+	// Prepare initializes consensus fields of the new block header,
+	// or returns ErrNotAuthor if the node account may not seal it
+	Prepare(ctx context.Context, header *types.BlockHeader) error
+
+	// Finalize applies post-transactions, such as block rewards
+	Finalize(ctx context.Context, block *types.Block) error
+
+	// RewardRecipients returns validators, which share reward of the block on top of provided parent
+	// with its author. Every node computes the same ones, so block reward transactions are verified
+	RewardRecipients(parent *types.BlockHeader) ([]common.Address, error)
+
+	// Seal signs block header with the node account, block values must not be changed afterwards
+	Seal(ctx context.Context, block *types.Block) error
+
+	// Finality returns numbers of the safe and finalized blocks of the chain with provided head.
+	// Safe block is confirmed by the majority of block authors, finalized block is never reverted
+	Finality(head *types.BlockHeader) (safe uint64, finalized uint64, err error)
+}
 
-	// Finalize applies post-transactions
-	Finalize(ctx context.Context, transactions []*types.SignedTx, receipts []*types.Receipt) (*types.Block, error)
+// Committer is implemented by engines, which replicate sealed blocks between nodes before
+// they are applied. Committed blocks are final, so the chain must never be reorganized
+type Committer interface {
+	// Commit replicates sealed block and returns once it has been committed and inserted to the chain,
+	// or returns ErrNotAuthor if the node may not commit blocks
+	Commit(ctx context.Context, block *types.Block) error
 }
@@ -0,0 +1,463 @@
+package poa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+const (
+	inmemorySnapshots = 128 // amount of recent vote snapshots to keep in memory
+
+	voteLength = common.AddressLength + 1 // vote extra data is a candidate address and authorization flag
+
+	voteDrop      byte = 0x00
+	voteAuthorize byte = 0x01
+
+	diffInTurn uint64 = 2 // block difficulty of the in-turn signer
+	diffNoTurn uint64 = 1 // block difficulty of the out-of-turn signer
+)
+
+var (
+	ErrUnknownAncestor    = errors.New("unknown ancestor")
+	ErrMissingSignature   = consensus.ErrMissingSignature
+	ErrInvalidSignature   = consensus.ErrInvalidSignature
+	ErrUnauthorizedSigner = errors.New("unauthorized signer")
+	ErrRecentlySigned     = errors.New("signer has signed recently")
+	ErrInvalidCoinbase    = errors.New("block coinbase is not its signer")
+	ErrInvalidDifficulty  = errors.New("invalid block difficulty")
+	ErrInvalidVote        = errors.New("invalid signer vote")
+	ErrInvalidCheckpoint  = errors.New("invalid checkpoint signers list")
+	ErrInvalidTimestamp   = errors.New("block timestamp precedes signer slot")
+)
+
+// PoA is a proof-of-authority consensus engine. Blocks are sealed by authorized signers,
+// which take in-turn slots by the election, and may seal out-of-turn blocks with lower difficulty.
+// Election winner may seal block a period after its parent, and every next fallback author one period later.
+// Signers are added and removed by the majority of signers votes, carried in the block header
+// extra data. Every epoch checkpoint block lists authorized signers and resets pending votes
+type PoA struct {
+	chain    consensus.ChainReader
+	epoch    uint64
+	period   int64              // minimal seconds between parent and its election winner block
+	election consensus.Election // orders signers to seal every block
+
+	snapshots *lru.ARCCache // recent snapshots by block hash
+
+	account    common.Address
+	signFn     consensus.SignerFn
+	signHashFn consensus.SignHashFn
+
+	proposals map[common.Address]bool // votes this node casts when it seals blocks
+
+	lock sync.RWMutex // protects signing account and proposals
+}
+
+// New creates PoA engine, epoch is the checkpoint blocks interval and period is the genesis block time.
+// Genesis authorized signers are read from the genesis block header extra data
+func New(chain consensus.ChainReader, epoch uint64, period int64, election consensus.Election) *PoA {
+	snapshots, _ := lru.NewARC(inmemorySnapshots)
+
+	return &PoA{
+		chain:     chain,
+		epoch:     epoch,
+		period:    period,
+		election:  election,
+		snapshots: snapshots,
+		proposals: make(map[common.Address]bool),
+	}
+}
+
+// Authorize sets node account, which seals blocks and signs their reward transactions
+func (p *PoA) Authorize(account common.Address, signFn consensus.SignerFn, signHashFn consensus.SignHashFn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.account = account
+	p.signFn = signFn
+	p.signHashFn = signHashFn
+}
+
+// Propose adds a vote to authorize or drop the candidate signer,
+// which is cast in the blocks sealed by this node
+func (p *PoA) Propose(candidate common.Address, authorize bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.proposals[candidate] = authorize
+}
+
+// Discard drops proposal for the candidate
+func (p *PoA) Discard(candidate common.Address) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.proposals, candidate)
+}
+
+// Proposals returns this node votes
+func (p *PoA) Proposals() map[common.Address]bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	proposals := make(map[common.Address]bool, len(p.proposals))
+	for candidate, authorize := range p.proposals {
+		proposals[candidate] = authorize
+	}
+	return proposals
+}
+
+// Snapshot returns authorization voting state after provided block
+func (p *PoA) Snapshot(header *types.BlockHeader) (*Snapshot, error) {
+	return p.snapshot(header.Number, header.BlockHash)
+}
+
+// snapshot retrieves voting state at the given block, starting from the closest
+// cached snapshot or checkpoint block and applying headers after it
+func (p *PoA) snapshot(number uint64, hash common.Hash) (*Snapshot, error) {
+	var headers []*types.BlockHeader
+	var snap *Snapshot
+
+	for snap == nil {
+		if cached, ok := p.snapshots.Get(hash); ok {
+			snap = cached.(*Snapshot)
+			break
+		}
+
+		header, err := p.chain.GetBlockHeader(hash)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAncestor, err)
+		}
+		if header.Number != number {
+			return nil, ErrUnknownAncestor
+		}
+
+		if number == 0 {
+			signers, err := decodeGenesis(header.Extra)
+			if err != nil {
+				return nil, err
+			}
+			snap = newSnapshot(0, hash, signers)
+			break
+		}
+
+		if number%p.epoch == 0 {
+			signers, err := decodeCheckpoint(header.Extra)
+			if err != nil {
+				return nil, err
+			}
+			snap = newSnapshot(number, hash, signers)
+			break
+		}
+
+		headers = append(headers, header)
+		number, hash = number-1, header.PrevHash
+	}
+
+	// headers were collected from the newest one
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+	}
+
+	snap, err := snap.apply(headers, p.epoch)
+	if err != nil {
+		return nil, err
+	}
+	p.snapshots.Add(snap.Hash, snap)
+
+	return snap, nil
+}
+
+// authors returns elected signers of the block, except the ones which have signed recently
+func (p *PoA) authors(snap *Snapshot, number uint64, parentHash common.Hash) []common.Address {
+	var authors []common.Address
+	for _, signer := range p.election.Elect(number, parentHash, snap.SignersList()) {
+		if !snap.recentlySigned(number, signer) {
+			authors = append(authors, signer)
+		}
+	}
+	return authors
+}
+
+// Authors returns signers eligible to seal block following provided parent in the election order
+func (p *PoA) Authors(ctx context.Context, parent *types.BlockHeader) ([]common.Address, error) {
+	snap, err := p.Snapshot(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.authors(snap, parent.Number+1, parent.BlockHash), nil
+}
+
+// Author returns the in-turn signer of the block following provided parent, which is the election winner
+func (p *PoA) Author(ctx context.Context, parent *types.BlockHeader) (common.Address, error) {
+	authors, err := p.Authors(ctx, parent)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if len(authors) == 0 {
+		return common.Address{}, ErrUnauthorizedSigner
+	}
+
+	return authors[0], nil
+}
+
+// rank returns signer position among the block authors, 0 is the election winner.
+// It is -1 if the signer may not seal the block
+func (p *PoA) rank(snap *Snapshot, header *types.BlockHeader, signer common.Address) int {
+	for i, author := range p.authors(snap, header.Number, header.PrevHash) {
+		if author == signer {
+			return i
+		}
+	}
+	return -1
+}
+
+// VerifyHeader checks header is sealed by authorized signer, which has not signed recently,
+// its difficulty and timestamp match the signer turn, and its extra data is a valid vote or checkpoint
+func (p *PoA) VerifyHeader(header *types.BlockHeader) error {
+	if header.Number == 0 {
+		return nil
+	}
+
+	snap, err := p.snapshot(header.Number-1, header.PrevHash)
+	if err != nil {
+		return err
+	}
+
+	if header.Number%p.epoch == 0 {
+		signers, err := decodeCheckpoint(header.Extra)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(encodeCheckpoint(signers), encodeCheckpoint(snap.SignersList())) {
+			return ErrInvalidCheckpoint
+		}
+	} else if _, _, _, err := decodeVote(header.Extra); err != nil {
+		return err
+	}
+
+	signer, err := consensus.Ecrecover(header)
+	if err != nil {
+		return err
+	}
+
+	if signer != header.Coinbase {
+		return fmt.Errorf("%w: %s; signer: %s", ErrInvalidCoinbase, header.Coinbase, signer)
+	}
+
+	if _, ok := snap.Signers[signer]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnauthorizedSigner, signer)
+	}
+
+	if snap.recentlySigned(header.Number, signer) {
+		return fmt.Errorf("%w: %s", ErrRecentlySigned, signer)
+	}
+
+	rank := p.rank(snap, header, signer)
+
+	difficulty := diffNoTurn
+	if rank == 0 {
+		difficulty = diffInTurn
+	}
+	if header.Difficulty != difficulty {
+		return fmt.Errorf("%w: %d; expected: %d", ErrInvalidDifficulty, header.Difficulty, difficulty)
+	}
+
+	parent, err := p.chain.GetBlockHeader(header.PrevHash)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnknownAncestor, err)
+	}
+	if slot := parent.Timestamp + p.period*int64(rank+1); header.Timestamp < slot {
+		return fmt.Errorf("%w: %d; rank %d slot: %d", ErrInvalidTimestamp, header.Timestamp, rank, slot)
+	}
+
+	return nil
+}
+
+// Prepare sets node account as the block coinbase, difficulty by its turn
+// and extra data with checkpoint signers or one of the node votes
+func (p *PoA) Prepare(ctx context.Context, header *types.BlockHeader) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	snap, err := p.snapshot(header.Number-1, header.PrevHash)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := snap.Signers[p.account]; !ok {
+		return fmt.Errorf("%w: %s: %s", consensus.ErrNotAuthor, ErrUnauthorizedSigner, p.account)
+	}
+
+	if snap.recentlySigned(header.Number, p.account) {
+		return fmt.Errorf("%w: %s: %s", consensus.ErrNotAuthor, ErrRecentlySigned, p.account)
+	}
+
+	header.Coinbase = p.account
+
+	header.Difficulty = diffNoTurn
+	if p.rank(snap, header, p.account) == 0 {
+		header.Difficulty = diffInTurn
+	}
+
+	if header.Number%p.epoch == 0 {
+		header.Extra = encodeCheckpoint(snap.SignersList())
+		return nil
+	}
+
+	// one of the proposals, which make sense, is cast
+	var candidates []common.Address
+	for candidate, authorize := range p.proposals {
+		if snap.validVote(candidate, authorize) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) > 0 {
+		sort.Slice(candidates, func(i, j int) bool {
+			return bytes.Compare(candidates[i].Bytes(), candidates[j].Bytes()) < 0
+		})
+		candidate := candidates[rand.Intn(len(candidates))]
+		header.Extra = encodeVote(candidate, p.proposals[candidate])
+	}
+
+	return nil
+}
+
+// Finalize appends block reward transactions for the block signer and the other authorized signers
+func (p *PoA) Finalize(ctx context.Context, block *types.Block) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.signFn == nil {
+		return fmt.Errorf("%w: %s: signer is not authorized", consensus.ErrNotAuthor, ErrUnauthorizedSigner)
+	}
+
+	snap, err := p.snapshot(block.Number-1, block.PrevHash)
+	if err != nil {
+		return err
+	}
+
+	return core.AppendRewardTxs(block, snap.SignersList(), p.signFn)
+}
+
+// RewardRecipients returns signers authorized at the parent block, every one of them is rewarded
+func (p *PoA) RewardRecipients(parent *types.BlockHeader) ([]common.Address, error) {
+	snap, err := p.Snapshot(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	return snap.SignersList(), nil
+}
+
+// Finality returns the safe block, which is followed by blocks of the signers majority, as every
+// signer seals one of len(signers)/2+1 consecutive blocks, and finalized block of params.PoAFinalityDepth confirmations
+func (p *PoA) Finality(head *types.BlockHeader) (uint64, uint64, error) {
+	snap, err := p.Snapshot(head)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var safe, finalized uint64
+	if majority := uint64(len(snap.Signers) / 2); head.Number > majority {
+		safe = head.Number - majority
+	}
+	if head.Number > params.PoAFinalityDepth {
+		finalized = head.Number - params.PoAFinalityDepth
+	}
+	if safe < finalized {
+		safe = finalized
+	}
+
+	return safe, finalized, nil
+}
+
+// Seal signs block header seal hash with the node account
+func (p *PoA) Seal(ctx context.Context, block *types.Block) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.signHashFn == nil || block.Coinbase != p.account {
+		return fmt.Errorf("%w: %s", ErrUnauthorizedSigner, block.Coinbase)
+	}
+
+	hash, err := block.SealHash()
+	if err != nil {
+		return err
+	}
+
+	sig, err := p.signHashFn(hash)
+	if err != nil {
+		return err
+	}
+	block.Signature = sig
+
+	return nil
+}
+
+func encodeVote(candidate common.Address, authorize bool) []byte {
+	flag := voteDrop
+	if authorize {
+		flag = voteAuthorize
+	}
+	return append(candidate.Bytes(), flag)
+}
+
+// decodeVote returns vote values of the block header extra data, which may be empty
+func decodeVote(extra []byte) (common.Address, bool, bool, error) {
+	if len(extra) == 0 {
+		return common.Address{}, false, false, nil
+	}
+
+	if len(extra) != voteLength || (extra[common.AddressLength] != voteDrop && extra[common.AddressLength] != voteAuthorize) {
+		return common.Address{}, false, false, ErrInvalidVote
+	}
+
+	return common.BytesToAddress(extra[:common.AddressLength]), extra[common.AddressLength] == voteAuthorize, true, nil
+}
+
+func encodeCheckpoint(signers []common.Address) []byte {
+	extra := make([]byte, 0, len(signers)*common.AddressLength)
+	for _, signer := range signers {
+		extra = append(extra, signer.Bytes()...)
+	}
+	return extra
+}
+
+// decodeGenesis returns signers listed in the genesis block header extra data, which is the first checkpoint
+func decodeGenesis(extra []byte) ([]common.Address, error) {
+	genesisExtra, err := core.DecodeGenesisExtra(extra)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCheckpoint, err)
+	}
+
+	if len(genesisExtra.Signers) == 0 {
+		return nil, ErrInvalidCheckpoint
+	}
+	return genesisExtra.Signers, nil
+}
+
+// decodeCheckpoint returns signers listed in the checkpoint block header extra data
+func decodeCheckpoint(extra []byte) ([]common.Address, error) {
+	if len(extra) == 0 || len(extra)%common.AddressLength != 0 {
+		return nil, ErrInvalidCheckpoint
+	}
+
+	signers := make([]common.Address, 0, len(extra)/common.AddressLength)
+	for i := 0; i < len(extra); i += common.AddressLength {
+		signers = append(signers, common.BytesToAddress(extra[i:i+common.AddressLength]))
+	}
+	return signers, nil
+}
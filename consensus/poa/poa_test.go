@@ -0,0 +1,505 @@
+package poa
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+const testPeriod int64 = 15 // seconds between parent and its election winner block
+
+var genesisHash = common.HexToHash("0x01")
+
+// testChain keeps headers by their hashes, starting with genesis
+type testChain map[common.Hash]*types.BlockHeader
+
+// newTestChain returns chain of the genesis, which authorizes the signers
+func newTestChain(t *testing.T, signers []common.Address) testChain {
+	t.Helper()
+	return testChain{genesisHash: {BlockHash: genesisHash, NetherUsed: new(big.Int), Extra: genesisExtra(t, signers)}}
+}
+
+// genesisExtra returns the genesis block header extra data, which lists the signers
+func genesisExtra(t *testing.T, signers []common.Address) []byte {
+	t.Helper()
+
+	extra, err := rlp.EncodeToBytes(core.GenesisExtra{Signers: signers, Election: consensus.ElectionRoundRobin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extra
+}
+
+func (c testChain) GetBlockHeader(hash common.Hash) (*types.BlockHeader, error) {
+	header, ok := c[hash]
+	if !ok {
+		return nil, errors.New("block does not exists")
+	}
+	return header, nil
+}
+
+// newTestSigners returns keys of n signers in ascending order of their addresses
+func newTestSigners(t *testing.T, n int) ([]common.Address, []*ecdsa.PrivateKey) {
+	t.Helper()
+
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := crypto.PubkeyToAddress(keys[i].PublicKey), crypto.PubkeyToAddress(keys[j].PublicKey)
+		return bytes.Compare(a.Bytes(), b.Bytes()) < 0
+	})
+
+	signers := make([]common.Address, n)
+	for i, key := range keys {
+		signers[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	return signers, keys
+}
+
+// newTestHeader returns header on top of the parent sealed by the key,
+// its timestamp is within the slot of any test signer rank
+func newTestHeader(t *testing.T, parent *types.BlockHeader, key *ecdsa.PrivateKey, difficulty uint64, extra []byte) *types.BlockHeader {
+	t.Helper()
+
+	header := &types.BlockHeader{
+		PrevHash:   parent.BlockHash,
+		Number:     parent.Number + 1,
+		Timestamp:  parent.Timestamp + 5*testPeriod,
+		Coinbase:   crypto.PubkeyToAddress(key.PublicKey),
+		NetherUsed: new(big.Int),
+		Difficulty: difficulty,
+		Extra:      extra,
+	}
+	sealTestHeader(t, header, key)
+	return header
+}
+
+// sealTestHeader signs header seal hash with the key and updates its hash
+func sealTestHeader(t *testing.T, header *types.BlockHeader, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	hash, err := header.SealHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Signature, err = crypto.Sign(hash, key); err != nil {
+		t.Fatal(err)
+	}
+
+	blockHash, err := header.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.BlockHash = common.BytesToHash(blockHash)
+}
+
+// extend seals the next header by the key with the difficulty of its turn, the checkpoint
+// header lists the signers instead of the vote. Header is verified and stored in the chain
+func extend(t *testing.T, engine *PoA, chain testChain, parent *types.BlockHeader, key *ecdsa.PrivateKey, vote []byte) *types.BlockHeader {
+	t.Helper()
+
+	authors, err := engine.Authors(context.Background(), parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	difficulty := diffNoTurn
+	if len(authors) > 0 && authors[0] == crypto.PubkeyToAddress(key.PublicKey) {
+		difficulty = diffInTurn
+	}
+
+	if (parent.Number+1)%engine.epoch == 0 {
+		snap, err := engine.Snapshot(parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vote = encodeCheckpoint(snap.SignersList())
+	}
+
+	header := newTestHeader(t, parent, key, difficulty, vote)
+	if err := engine.VerifyHeader(header); err != nil {
+		t.Fatalf("block %d: %s", header.Number, err)
+	}
+	chain[header.BlockHash] = header
+	return header
+}
+
+func TestVerifyHeader(t *testing.T) {
+	signers, keys := newTestSigners(t, 3)
+	outsider, _ := crypto.GenerateKey()
+	chain := newTestChain(t, signers)
+	engine := New(chain, params.PoAEpoch, testPeriod, consensus.RoundRobin{})
+
+	// round robin winner of the block 1 is the second signer, block 2 is the third one's
+	genesis := chain[genesisHash]
+	parent := extend(t, engine, chain, genesis, keys[1], nil)
+
+	tests := []struct {
+		name   string
+		header func() *types.BlockHeader
+		err    error
+	}{
+		{name: "in-turn signer", header: func() *types.BlockHeader { return newTestHeader(t, parent, keys[2], diffInTurn, nil) }},
+		{name: "out-of-turn signer", header: func() *types.BlockHeader { return newTestHeader(t, parent, keys[0], diffNoTurn, nil) }},
+		{
+			name:   "in-turn signer with out-of-turn difficulty",
+			header: func() *types.BlockHeader { return newTestHeader(t, parent, keys[2], diffNoTurn, nil) },
+			err:    ErrInvalidDifficulty,
+		},
+		{
+			name:   "out-of-turn signer with in-turn difficulty",
+			header: func() *types.BlockHeader { return newTestHeader(t, parent, keys[0], diffInTurn, nil) },
+			err:    ErrInvalidDifficulty,
+		},
+		{
+			name:   "recent signer",
+			header: func() *types.BlockHeader { return newTestHeader(t, parent, keys[1], diffNoTurn, nil) },
+			err:    ErrRecentlySigned,
+		},
+		{
+			name:   "unauthorized signer",
+			header: func() *types.BlockHeader { return newTestHeader(t, parent, outsider, diffNoTurn, nil) },
+			err:    ErrUnauthorizedSigner,
+		},
+		{
+			name: "coinbase is not the signer",
+			header: func() *types.BlockHeader {
+				header := newTestHeader(t, parent, keys[2], diffInTurn, nil)
+				header.Coinbase = signers[0]
+				return header
+			},
+			err: ErrInvalidCoinbase,
+		},
+		{
+			name: "missing signature",
+			header: func() *types.BlockHeader {
+				header := newTestHeader(t, parent, keys[2], diffInTurn, nil)
+				header.Signature = nil
+				return header
+			},
+			err: ErrMissingSignature,
+		},
+		{
+			name:   "invalid vote",
+			header: func() *types.BlockHeader { return newTestHeader(t, parent, keys[2], diffInTurn, []byte{1, 2, 3}) },
+			err:    ErrInvalidVote,
+		},
+		{
+			name: "in-turn signer before its slot",
+			header: func() *types.BlockHeader {
+				header := newTestHeader(t, parent, keys[2], diffInTurn, nil)
+				header.Timestamp = parent.Timestamp + testPeriod - 1
+				sealTestHeader(t, header, keys[2])
+				return header
+			},
+			err: ErrInvalidTimestamp,
+		},
+		{
+			name: "in-turn signer slot",
+			header: func() *types.BlockHeader {
+				header := newTestHeader(t, parent, keys[2], diffInTurn, nil)
+				header.Timestamp = parent.Timestamp + testPeriod
+				sealTestHeader(t, header, keys[2])
+				return header
+			},
+		},
+		{
+			name: "out-of-turn signer in the in-turn slot",
+			header: func() *types.BlockHeader {
+				header := newTestHeader(t, parent, keys[0], diffNoTurn, nil)
+				header.Timestamp = parent.Timestamp + 2*testPeriod - 1
+				sealTestHeader(t, header, keys[0])
+				return header
+			},
+			err: ErrInvalidTimestamp,
+		},
+		{
+			name: "out-of-turn signer slot",
+			header: func() *types.BlockHeader {
+				header := newTestHeader(t, parent, keys[0], diffNoTurn, nil)
+				header.Timestamp = parent.Timestamp + 2*testPeriod
+				sealTestHeader(t, header, keys[0])
+				return header
+			},
+		},
+		{
+			name: "unknown parent",
+			header: func() *types.BlockHeader {
+				return newTestHeader(t, &types.BlockHeader{Number: 1}, keys[2], diffInTurn, nil)
+			},
+			err: ErrUnknownAncestor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := engine.VerifyHeader(tt.header()); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGenesisSnapshot(t *testing.T) {
+	signers, _ := newTestSigners(t, 3)
+	chain := newTestChain(t, signers)
+	engine := New(chain, params.PoAEpoch, testPeriod, consensus.RoundRobin{})
+
+	snap, err := engine.Snapshot(chain[genesisHash])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := snap.SignersList(); !equalAddresses(got, signers) {
+		t.Fatalf("expected genesis signers %v, got %v", signers, got)
+	}
+
+	for name, extra := range map[string][]byte{
+		"empty extra":      nil,
+		"no signers":       genesisExtra(t, nil),
+		"undecodable list": {0x01, 0x02},
+	} {
+		t.Run(name, func(t *testing.T) {
+			chain := newTestChain(t, signers)
+			chain[genesisHash].Extra = extra
+			engine := New(chain, params.PoAEpoch, testPeriod, consensus.RoundRobin{})
+			if _, err := engine.Snapshot(chain[genesisHash]); !errors.Is(err, ErrInvalidCheckpoint) {
+				t.Fatalf("expected %s, got %v", ErrInvalidCheckpoint, err)
+			}
+		})
+	}
+}
+
+func TestSnapshotVoting(t *testing.T) {
+	signers, keys := newTestSigners(t, 3)
+	candidate := common.HexToAddress("0xc0ffee")
+
+	type vote struct {
+		signer    int // index of the signer, which seals the block with the vote
+		candidate common.Address
+		authorize bool
+	}
+
+	tests := []struct {
+		name    string
+		epoch   uint64
+		votes   []vote // votes of the consecutive blocks
+		signers []common.Address
+		tally   map[common.Address]Tally
+	}{
+		{
+			name:    "vote is pending until the majority agreed",
+			votes:   []vote{{signer: 0, candidate: candidate, authorize: true}},
+			signers: signers,
+			tally:   map[common.Address]Tally{candidate: {Authorize: true, Votes: 1}},
+		},
+		{
+			name:    "majority authorizes the candidate",
+			votes:   []vote{{signer: 0, candidate: candidate, authorize: true}, {signer: 1, candidate: candidate, authorize: true}},
+			signers: append([]common.Address{candidate}, signers...),
+		},
+		{
+			name:    "majority drops the signer",
+			votes:   []vote{{signer: 0, candidate: signers[2]}, {signer: 1, candidate: signers[2]}},
+			signers: signers[:2],
+		},
+		{
+			name:    "votes of the already authorized signer are ignored",
+			votes:   []vote{{signer: 0, candidate: signers[1], authorize: true}},
+			signers: signers,
+		},
+		{
+			name:    "checkpoint resets votes",
+			epoch:   2,
+			votes:   []vote{{signer: 0, candidate: candidate, authorize: true}, {signer: 1}},
+			signers: signers,
+		},
+		{
+			name: "dropped signer votes are discarded",
+			votes: []vote{
+				{signer: 0, candidate: candidate, authorize: true},
+				{signer: 1, candidate: signers[0]}, {signer: 2, candidate: signers[0]},
+			},
+			signers: signers[1:],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			epoch := tt.epoch
+			if epoch == 0 {
+				epoch = params.PoAEpoch
+			}
+			chain := newTestChain(t, signers)
+			engine := New(chain, epoch, testPeriod, consensus.RoundRobin{})
+
+			head := chain[genesisHash]
+			for _, v := range tt.votes {
+				var extra []byte
+				if v.candidate != (common.Address{}) {
+					extra = encodeVote(v.candidate, v.authorize)
+				}
+				head = extend(t, engine, chain, head, keys[v.signer], extra)
+			}
+
+			snap, err := engine.Snapshot(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := sortedAddresses(tt.signers)
+			if got := snap.SignersList(); !equalAddresses(got, want) {
+				t.Fatalf("expected signers %v, got %v", want, got)
+			}
+			if len(snap.Tally) != len(tt.tally) {
+				t.Fatalf("expected tally %v, got %v", tt.tally, snap.Tally)
+			}
+			for addr, tally := range tt.tally {
+				if snap.Tally[addr] != tally {
+					t.Fatalf("expected %s tally %+v, got %+v", addr, tally, snap.Tally[addr])
+				}
+			}
+		})
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	signers, keys := newTestSigners(t, 3)
+
+	// random election ranks signers by the hash of the parent hash and signer address
+	random := func(parent common.Hash, candidates ...common.Address) []common.Address {
+		sorted := sortedAddresses(candidates)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a := sha256.Sum256(append(parent.Bytes(), sorted[i].Bytes()...))
+			b := sha256.Sum256(append(parent.Bytes(), sorted[j].Bytes()...))
+			return bytes.Compare(a[:], b[:]) < 0
+		})
+		return sorted
+	}
+
+	tests := []struct {
+		name     string
+		election consensus.Election
+		sealed   int // signer of the block 1, which authors of the block 2 are returned
+		want     func(parent common.Hash) []common.Address
+	}{
+		{
+			name:     "round robin",
+			election: consensus.RoundRobin{},
+			sealed:   1,
+			// block 2 winner is the third signer, and the second one has signed recently
+			want: func(common.Hash) []common.Address { return []common.Address{signers[2], signers[0]} },
+		},
+		{
+			name:     "random",
+			election: consensus.Random{},
+			sealed:   0,
+			want:     func(parent common.Hash) []common.Address { return random(parent, signers[1], signers[2]) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newTestChain(t, signers)
+			engine := New(chain, params.PoAEpoch, testPeriod, tt.election)
+			parent := extend(t, engine, chain, chain[genesisHash], keys[tt.sealed], nil)
+
+			authors, err := engine.Authors(context.Background(), parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.want(parent.BlockHash); !equalAddresses(authors, want) {
+				t.Fatalf("expected authors %v, got %v", want, authors)
+			}
+
+			author, err := engine.Author(context.Background(), parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if author != authors[0] {
+				t.Fatalf("expected author %s, got %s", authors[0], author)
+			}
+		})
+	}
+}
+
+func TestFinality(t *testing.T) {
+	tests := []struct {
+		name      string
+		signers   int
+		head      uint64
+		safe      uint64
+		finalized uint64
+	}{
+		{name: "genesis", signers: 3, head: 0},
+		{name: "block of the single signer is safe", signers: 1, head: 2, safe: 2},
+		{name: "safe block is followed by the signers majority", signers: 3, head: 3, safe: 2},
+		{name: "majority of five signers", signers: 5, head: 4, safe: 2},
+		{
+			name:      "finalized block",
+			signers:   3,
+			head:      params.PoAFinalityDepth + 5,
+			safe:      params.PoAFinalityDepth + 4,
+			finalized: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signers, keys := newTestSigners(t, tt.signers)
+			chain := newTestChain(t, signers)
+			engine := New(chain, params.PoAEpoch, testPeriod, consensus.RoundRobin{})
+
+			// every block is sealed by the in-turn signer
+			head := chain[genesisHash]
+			for head.Number < tt.head {
+				head = extend(t, engine, chain, head, keys[(head.Number+1)%uint64(len(keys))], nil)
+			}
+
+			safe, finalized, err := engine.Finality(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if safe != tt.safe || finalized != tt.finalized {
+				t.Fatalf("expected safe %d and finalized %d, got %d and %d", tt.safe, tt.finalized, safe, finalized)
+			}
+		})
+	}
+}
+
+func sortedAddresses(addrs []common.Address) []common.Address {
+	sorted := make([]common.Address, len(addrs))
+	copy(sorted, addrs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+	return sorted
+}
+
+func equalAddresses(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
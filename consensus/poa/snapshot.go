@@ -0,0 +1,255 @@
+package poa
+
+import (
+	"bytes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core/types"
+	"sort"
+)
+
+// Vote is a single signer vote to authorize or drop the candidate account
+type Vote struct {
+	Signer    common.Address `json:"signer" yaml:"signer"`
+	Number    uint64         `json:"number" yaml:"number"` // block number the vote was cast in
+	Candidate common.Address `json:"candidate" yaml:"candidate"`
+	Authorize bool           `json:"authorize" yaml:"authorize"`
+}
+
+// Tally is the current candidate votes score
+type Tally struct {
+	Authorize bool `json:"authorize" yaml:"authorize"`
+	Votes     int  `json:"votes" yaml:"votes"`
+}
+
+// Snapshot is the authorization voting state at the given block
+type Snapshot struct {
+	Number  uint64                      `json:"number" yaml:"number"`
+	Hash    common.Hash                 `json:"hash" yaml:"hash"`
+	Signers map[common.Address]struct{} `json:"signers" yaml:"signers"`
+	Recents map[uint64]common.Address   `json:"recents" yaml:"recents"` // recent blocks signers, to prevent spamming
+	Votes   []*Vote                     `json:"votes" yaml:"votes"`
+	Tally   map[common.Address]Tally    `json:"tally" yaml:"tally"`
+}
+
+func newSnapshot(number uint64, hash common.Hash, signers []common.Address) *Snapshot {
+	snap := &Snapshot{
+		Number:  number,
+		Hash:    hash,
+		Signers: make(map[common.Address]struct{}),
+		Recents: make(map[uint64]common.Address),
+		Tally:   make(map[common.Address]Tally),
+	}
+	for _, signer := range signers {
+		snap.Signers[signer] = struct{}{}
+	}
+	return snap
+}
+
+func (s *Snapshot) copy() *Snapshot {
+	cpy := &Snapshot{
+		Number:  s.Number,
+		Hash:    s.Hash,
+		Signers: make(map[common.Address]struct{}, len(s.Signers)),
+		Recents: make(map[uint64]common.Address, len(s.Recents)),
+		Votes:   make([]*Vote, len(s.Votes)),
+		Tally:   make(map[common.Address]Tally, len(s.Tally)),
+	}
+	for signer := range s.Signers {
+		cpy.Signers[signer] = struct{}{}
+	}
+	for number, signer := range s.Recents {
+		cpy.Recents[number] = signer
+	}
+	for address, tally := range s.Tally {
+		cpy.Tally[address] = tally
+	}
+	copy(cpy.Votes, s.Votes)
+
+	return cpy
+}
+
+// SignersList returns authorized signers in ascending order
+func (s *Snapshot) SignersList() []common.Address {
+	signers := make([]common.Address, 0, len(s.Signers))
+	for signer := range s.Signers {
+		signers = append(signers, signer)
+	}
+
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i].Bytes(), signers[j].Bytes()) < 0
+	})
+
+	return signers
+}
+
+// recentlySigned returns whether signer has sealed one of the last len(signers)/2+1 blocks,
+// so it may not seal block of provided number
+func (s *Snapshot) recentlySigned(number uint64, signer common.Address) bool {
+	limit := uint64(len(s.Signers)/2 + 1)
+	for seen, recent := range s.Recents {
+		if recent == signer && (number < limit || seen > number-limit) {
+			return true
+		}
+	}
+	return false
+}
+
+// validVote returns whether it makes sense to cast the vote,
+// e.g. it is not possible to authorize already authorized signer or drop the last one
+func (s *Snapshot) validVote(candidate common.Address, authorize bool) bool {
+	_, signer := s.Signers[candidate]
+	if authorize {
+		return !signer
+	}
+	return signer && len(s.Signers) > 1
+}
+
+func (s *Snapshot) cast(candidate common.Address, authorize bool) bool {
+	if !s.validVote(candidate, authorize) {
+		return false
+	}
+
+	if tally, ok := s.Tally[candidate]; ok {
+		if tally.Authorize != authorize {
+			return false
+		}
+		tally.Votes++
+		s.Tally[candidate] = tally
+	} else {
+		s.Tally[candidate] = Tally{Authorize: authorize, Votes: 1}
+	}
+	return true
+}
+
+func (s *Snapshot) uncast(candidate common.Address, authorize bool) bool {
+	tally, ok := s.Tally[candidate]
+	if !ok || tally.Authorize != authorize {
+		return false
+	}
+
+	if tally.Votes > 1 {
+		tally.Votes--
+		s.Tally[candidate] = tally
+	} else {
+		delete(s.Tally, candidate)
+	}
+	return true
+}
+
+// apply creates a new snapshot by applying provided headers, which have to be contiguous
+// and follow the snapshot block
+func (s *Snapshot) apply(headers []*types.BlockHeader, epoch uint64) (*Snapshot, error) {
+	if len(headers) == 0 {
+		return s, nil
+	}
+
+	snap := s.copy()
+	for _, header := range headers {
+		if header.Number != snap.Number+1 || header.PrevHash != snap.Hash {
+			return nil, ErrUnknownAncestor
+		}
+
+		number := header.Number
+		if limit := uint64(len(snap.Signers)/2 + 1); number >= limit {
+			delete(snap.Recents, number-limit)
+		}
+
+		signer, err := consensus.Ecrecover(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := snap.Signers[signer]; !ok {
+			return nil, ErrUnauthorizedSigner
+		}
+		if snap.recentlySigned(number, signer) {
+			return nil, ErrRecentlySigned
+		}
+		snap.Recents[number] = signer
+		snap.Number, snap.Hash = number, header.BlockHash
+
+		// misbehaving signers are dropped by the block evidence without voting
+		for _, offender := range header.Punished {
+			snap.drop(offender, number)
+		}
+
+		// votes are reset on every checkpoint
+		if number%epoch == 0 {
+			snap.Votes = nil
+			snap.Tally = make(map[common.Address]Tally)
+			continue
+		}
+
+		candidate, authorize, ok, err := decodeVote(header.Extra)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		// signer may change its previous vote for the candidate
+		for i, vote := range snap.Votes {
+			if vote.Signer == signer && vote.Candidate == candidate {
+				snap.uncast(vote.Candidate, vote.Authorize)
+				snap.Votes = append(snap.Votes[:i], snap.Votes[i+1:]...)
+				break
+			}
+		}
+		if snap.cast(candidate, authorize) {
+			snap.Votes = append(snap.Votes, &Vote{
+				Signer:    signer,
+				Number:    number,
+				Candidate: candidate,
+				Authorize: authorize,
+			})
+		}
+
+		// vote passes once the majority of signers agreed
+		if tally := snap.Tally[candidate]; tally.Votes > len(snap.Signers)/2 {
+			if tally.Authorize {
+				snap.Signers[candidate] = struct{}{}
+				snap.discardVotes(candidate)
+			} else {
+				snap.drop(candidate, number)
+			}
+		}
+	}
+
+	return snap, nil
+}
+
+// drop removes signer with its votes and votes for it, the last signer is never dropped
+func (s *Snapshot) drop(signer common.Address, number uint64) {
+	if _, ok := s.Signers[signer]; !ok || len(s.Signers) == 1 {
+		return
+	}
+	delete(s.Signers, signer)
+
+	// signers list shrunk, so the oldest recent signer may seal again
+	if limit := uint64(len(s.Signers)/2 + 1); number >= limit {
+		delete(s.Recents, number-limit)
+	}
+
+	// votes of the dropped signer are discarded
+	for i := 0; i < len(s.Votes); i++ {
+		if s.Votes[i].Signer == signer {
+			s.uncast(s.Votes[i].Candidate, s.Votes[i].Authorize)
+			s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+			i--
+		}
+	}
+
+	s.discardVotes(signer)
+}
+
+// discardVotes removes votes for the candidate, once they are done
+func (s *Snapshot) discardVotes(candidate common.Address) {
+	for i := 0; i < len(s.Votes); i++ {
+		if s.Votes[i].Candidate == candidate {
+			s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+			i--
+		}
+	}
+	delete(s.Tally, candidate)
+}
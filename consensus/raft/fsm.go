@@ -0,0 +1,151 @@
+package raft
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	hraft "github.com/hashicorp/raft"
+	"github.com/rovergulf/chain/core/types"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid raft snapshot")
+)
+
+const snapshotLength = common.HashLength + 8 // the last committed block hash and number
+
+// fsm applies committed raft log blocks to the chain
+type fsm struct {
+	chain  Chain
+	logger *zap.SugaredLogger
+	resync func() // requests missing blocks sync from peers
+
+	applying   common.Hash // block being inserted by the committed log entry
+	last       common.Hash // the last committed block
+	lastNumber uint64
+	lock       sync.RWMutex
+}
+
+// Apply inserts committed block, entries applied before the node restart are skipped.
+// Block, which can not be inserted, is still committed, so the node requests the sync of missing blocks,
+// which are accepted up to the last committed one. Returned error is the Commit result on the leader
+func (f *fsm) Apply(log *hraft.Log) interface{} {
+	var block types.Block
+	if err := block.Deserialize(log.Data); err != nil {
+		f.logger.Errorw("Unable to decode committed block", "index", log.Index, "err", err)
+		return err
+	}
+
+	f.setLast(block.BlockHash, block.Number)
+	if _, err := f.chain.GetBlockHeader(block.BlockHash); err == nil {
+		return nil
+	}
+
+	f.lock.Lock()
+	f.applying = block.BlockHash
+	f.lock.Unlock()
+
+	defer func() {
+		f.lock.Lock()
+		f.applying = common.Hash{}
+		f.lock.Unlock()
+	}()
+
+	if err := f.chain.InsertBlock(context.Background(), &block); err != nil {
+		f.logger.Errorw("Unable to apply committed block, requesting resync", "index", log.Index,
+			"number", block.Number, "hash", block.BlockHash, "err", err)
+		f.requestResync()
+		return err
+	}
+
+	return nil
+}
+
+// committed returns whether block is being applied by the committed log entry
+func (f *fsm) committed(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return hash != common.Hash{} && f.applying == hash
+}
+
+// lastCommitted returns the last committed block hash and number
+func (f *fsm) lastCommitted() (common.Hash, uint64) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.last, f.lastNumber
+}
+
+// setLast updates the last committed block, raft log entries are applied in order
+func (f *fsm) setLast(hash common.Hash, number uint64) {
+	f.lock.Lock()
+	f.last = hash
+	f.lastNumber = number
+	f.lock.Unlock()
+}
+
+func (f *fsm) requestResync() {
+	if f.resync != nil {
+		f.resync()
+	}
+}
+
+// Snapshot captures the last committed block hash and number, blocks themselves are kept by the chain
+func (f *fsm) Snapshot() (hraft.FSMSnapshot, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return &fsmSnapshot{last: f.last, number: f.lastNumber}, nil
+}
+
+// Restore sets the snapshot block as the last committed one. Raft log does not keep compacted blocks,
+// so the node requests the sync of them, if the snapshot block is missing in the local chain
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if len(data) != snapshotLength {
+		return fmt.Errorf("%w: length %d", ErrInvalidSnapshot, len(data))
+	}
+
+	last := common.BytesToHash(data[:common.HashLength])
+	f.setLast(last, binary.BigEndian.Uint64(data[common.HashLength:]))
+
+	if last != (common.Hash{}) {
+		if _, err := f.chain.GetBlockHeader(last); err != nil {
+			f.logger.Warnw("Raft snapshot block is missing in the local chain, requesting resync", "hash", last)
+			f.requestResync()
+		}
+	}
+
+	return nil
+}
+
+type fsmSnapshot struct {
+	last   common.Hash
+	number uint64
+}
+
+func (s *fsmSnapshot) Persist(sink hraft.SnapshotSink) error {
+	data := make([]byte, snapshotLength)
+	copy(data, s.last.Bytes())
+	binary.BigEndian.PutUint64(data[common.HashLength:], s.number)
+
+	if _, err := sink.Write(data); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {}
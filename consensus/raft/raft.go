@@ -0,0 +1,468 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	hraft "github.com/hashicorp/raft"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	difficulty uint64 = 1 // committed blocks have the same weight
+
+	commitTimeout = 10 * time.Second // raft log entry and membership change apply timeout
+)
+
+var (
+	ErrNotStarted         = errors.New("raft node is not started")
+	ErrNotLeader          = errors.New("node is not the raft leader")
+	ErrNoLeader           = errors.New("raft cluster has no leader")
+	ErrNotCommitted       = errors.New("block is not committed by raft cluster")
+	ErrUnauthorizedSigner = errors.New("unauthorized signer")
+	ErrInvalidCoinbase    = errors.New("block coinbase is not its signer")
+	ErrInvalidDifficulty  = errors.New("invalid block difficulty")
+)
+
+// Chain is the blockchain, which committed blocks are inserted to
+type Chain interface {
+	consensus.ChainReader
+
+	// InsertBlock validates and applies the block on top of the chain
+	InsertBlock(ctx context.Context, block *types.Block) error
+}
+
+// Config is raft node storage and network transport
+type Config struct {
+	Transport     hraft.Transport
+	LogStore      hraft.LogStore
+	StableStore   hraft.StableStore
+	SnapshotStore hraft.SnapshotStore
+
+	// Bootstrap starts a new cluster with this node as the only voter, unless the node has raft state already
+	Bootstrap bool
+
+	// Raft overrides the library timeouts and limits, default configuration is used if it is nil
+	Raft *hraft.Config
+
+	// Resync is called once the committed block can not be inserted, because the local chain is behind
+	// the raft log or its snapshot. Node syncs missing blocks from peers, committed ones are accepted
+	Resync func()
+
+	Logger *zap.SugaredLogger
+}
+
+// Member is the raft cluster server, identified by the node account
+type Member struct {
+	Account common.Address `json:"account" yaml:"account"`
+	Address string         `json:"address" yaml:"address"` // raft transport address
+	Voter   bool           `json:"voter" yaml:"voter"`
+	Leader  bool           `json:"leader" yaml:"leader"`
+}
+
+// Raft is a consensus engine for permissioned networks. The cluster leader seals blocks and
+// replicates them through the raft log, every node inserts the block only once it is committed
+// by the majority of voters, so committed blocks are final and the chain is never reorganized.
+// Cluster members are added and removed by the leader
+type Raft struct {
+	chain  Chain
+	config Config
+	fsm    *fsm
+	raft   *hraft.Raft
+
+	account    common.Address // raft server ID
+	signFn     consensus.SignerFn
+	signHashFn consensus.SignHashFn
+
+	lock sync.RWMutex // protects signing account
+}
+
+// New creates raft engine, which is started after the node account is authorized
+func New(chain Chain, config Config) *Raft {
+	if config.Logger == nil {
+		config.Logger = zap.NewNop().Sugar()
+	}
+
+	return &Raft{
+		chain:  chain,
+		config: config,
+		fsm: &fsm{
+			chain:  chain,
+			logger: config.Logger,
+			resync: config.Resync,
+		},
+	}
+}
+
+// Authorize sets node account, which is the raft server ID, seals blocks and signs their reward transactions
+func (r *Raft) Authorize(account common.Address, signFn consensus.SignerFn, signHashFn consensus.SignHashFn) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.account = account
+	r.signFn = signFn
+	r.signHashFn = signHashFn
+}
+
+// Start runs raft node, committed log entries are applied to the chain since then
+func (r *Raft) Start() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conf := hraft.DefaultConfig()
+	if r.config.Raft != nil {
+		c := *r.config.Raft
+		conf = &c
+	}
+	conf.LocalID = hraft.ServerID(r.account.Hex())
+	if conf.Logger == nil {
+		conf.Logger = hclog.New(&hclog.LoggerOptions{Name: "raft", Level: hclog.Warn})
+	}
+
+	if r.config.Bootstrap {
+		exists, err := hraft.HasExistingState(r.config.LogStore, r.config.StableStore, r.config.SnapshotStore)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			if err := hraft.BootstrapCluster(conf, r.config.LogStore, r.config.StableStore, r.config.SnapshotStore,
+				r.config.Transport, hraft.Configuration{Servers: []hraft.Server{{
+					Suffrage: hraft.Voter,
+					ID:       conf.LocalID,
+					Address:  r.config.Transport.LocalAddr(),
+				}}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	node, err := hraft.NewRaft(conf, r.fsm, r.config.LogStore, r.config.StableStore, r.config.SnapshotStore, r.config.Transport)
+	if err != nil {
+		return err
+	}
+	r.raft = node
+
+	return nil
+}
+
+// Shutdown stops raft node
+func (r *Raft) Shutdown() error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.raft == nil {
+		return nil
+	}
+
+	return r.raft.Shutdown().Error()
+}
+
+// node returns running raft node
+func (r *Raft) node() (*hraft.Raft, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.raft == nil {
+		return nil, ErrNotStarted
+	}
+	return r.raft, nil
+}
+
+// IsLeader returns whether the node is the cluster leader
+func (r *Raft) IsLeader() bool {
+	node, err := r.node()
+	return err == nil && node.State() == hraft.Leader
+}
+
+// State returns the node raft state: follower, candidate, leader or shutdown
+func (r *Raft) State() string {
+	node, err := r.node()
+	if err != nil {
+		return hraft.Shutdown.String()
+	}
+	return node.State().String()
+}
+
+// Leader returns account of the cluster leader
+func (r *Raft) Leader() (common.Address, error) {
+	members, err := r.Members()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	for _, member := range members {
+		if member.Leader {
+			return member.Account, nil
+		}
+	}
+
+	return common.Address{}, ErrNoLeader
+}
+
+// Members returns the latest cluster configuration servers
+func (r *Raft) Members() ([]Member, error) {
+	node, err := r.node()
+	if err != nil {
+		return nil, err
+	}
+
+	future := node.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	leader := node.Leader()
+	servers := future.Configuration().Servers
+
+	members := make([]Member, 0, len(servers))
+	for _, server := range servers {
+		members = append(members, Member{
+			Account: common.HexToAddress(string(server.ID)),
+			Address: string(server.Address),
+			Voter:   server.Suffrage == hraft.Voter,
+			Leader:  leader != "" && server.Address == leader,
+		})
+	}
+
+	return members, nil
+}
+
+// AddVoter adds the node account with raft transport address to the cluster voters, only leader may change the cluster
+func (r *Raft) AddVoter(account common.Address, address string) error {
+	node, err := r.node()
+	if err != nil {
+		return err
+	}
+
+	if err := node.AddVoter(hraft.ServerID(account.Hex()), hraft.ServerAddress(address), 0, commitTimeout).Error(); err != nil {
+		if errors.Is(err, hraft.ErrNotLeader) {
+			return fmt.Errorf("%w: %s", ErrNotLeader, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RemoveMember removes the node account from the cluster, only leader may change the cluster
+func (r *Raft) RemoveMember(account common.Address) error {
+	node, err := r.node()
+	if err != nil {
+		return err
+	}
+
+	if err := node.RemoveServer(hraft.ServerID(account.Hex()), 0, commitTimeout).Error(); err != nil {
+		if errors.Is(err, hraft.ErrNotLeader) {
+			return fmt.Errorf("%w: %s", ErrNotLeader, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Authors returns the cluster leader, which is the only account eligible to seal blocks
+func (r *Raft) Authors(ctx context.Context, parent *types.BlockHeader) ([]common.Address, error) {
+	leader, err := r.Leader()
+	if err != nil {
+		return nil, err
+	}
+
+	return []common.Address{leader}, nil
+}
+
+// Author returns the cluster leader
+func (r *Raft) Author(ctx context.Context, parent *types.BlockHeader) (common.Address, error) {
+	return r.Leader()
+}
+
+// VerifyHeader checks header belongs to the block committed by the raft log and is sealed
+// by its coinbase. Blocks are inserted by committed log entries, their signers were the leaders
+// at the commit time. Blocks synced from peers are verified by verifySynced
+func (r *Raft) VerifyHeader(header *types.BlockHeader) error {
+	if header.Number == 0 {
+		return nil
+	}
+
+	signer, err := consensus.Ecrecover(header)
+	if err != nil {
+		return err
+	}
+
+	if signer != header.Coinbase {
+		return fmt.Errorf("%w: %s; signer: %s", ErrInvalidCoinbase, header.Coinbase, signer)
+	}
+
+	if header.Difficulty != difficulty {
+		return fmt.Errorf("%w: %d; expected: %d", ErrInvalidDifficulty, header.Difficulty, difficulty)
+	}
+
+	if r.fsm.committed(header.BlockHash) {
+		return nil
+	}
+
+	return r.verifySynced(header, signer)
+}
+
+// verifySynced checks the header synced from peers is not above the last committed block and matches it
+// at its height, so the node catches up with the raft log, which is compacted or has been applied
+// before the block parent was known. Header must be sealed by the cluster member
+func (r *Raft) verifySynced(header *types.BlockHeader, signer common.Address) error {
+	last, number := r.fsm.lastCommitted()
+	if last == (common.Hash{}) || header.Number > number {
+		return fmt.Errorf("%w: %s", ErrNotCommitted, header.BlockHash)
+	}
+	if header.Number == number && header.BlockHash != last {
+		return fmt.Errorf("%w: %s; committed: %s", ErrNotCommitted, header.BlockHash, last)
+	}
+
+	members, err := r.Members()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.Account == signer {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnauthorizedSigner, signer)
+}
+
+// Prepare sets the leader node account as the block coinbase
+func (r *Raft) Prepare(ctx context.Context, header *types.BlockHeader) error {
+	if !r.IsLeader() {
+		return fmt.Errorf("%w: block %d", consensus.ErrNotAuthor, header.Number)
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	header.Coinbase = r.account
+	header.Difficulty = difficulty
+	header.Extra = nil
+
+	return nil
+}
+
+// Finalize appends block reward transaction for the block signer
+func (r *Raft) Finalize(ctx context.Context, block *types.Block) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.signFn == nil {
+		return fmt.Errorf("%w: %s: signer is not authorized", consensus.ErrNotAuthor, ErrUnauthorizedSigner)
+	}
+
+	return core.AppendRewardTxs(block, nil, r.signFn)
+}
+
+// RewardRecipients returns no validators, as cluster membership is not a part of the chain state,
+// so only the leader, which has sealed the block, is rewarded
+func (r *Raft) RewardRecipients(parent *types.BlockHeader) ([]common.Address, error) {
+	return nil, nil
+}
+
+// Seal signs block header seal hash with the node account
+func (r *Raft) Seal(ctx context.Context, block *types.Block) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.signHashFn == nil || block.Coinbase != r.account {
+		return fmt.Errorf("%w: %s", ErrUnauthorizedSigner, block.Coinbase)
+	}
+
+	hash, err := block.SealHash()
+	if err != nil {
+		return err
+	}
+
+	sig, err := r.signHashFn(hash)
+	if err != nil {
+		return err
+	}
+	block.Signature = sig
+
+	return nil
+}
+
+// Finality returns the chain head as both safe and finalized block,
+// because blocks are inserted only once they are committed
+func (r *Raft) Finality(head *types.BlockHeader) (uint64, uint64, error) {
+	return head.Number, head.Number, nil
+}
+
+// Commit replicates sealed block through the raft log. It returns once the block is committed
+// by the majority of voters and inserted to the leader chain, or the block insertion error.
+// Accounts punished by the block evidence are removed from the cluster afterwards
+func (r *Raft) Commit(ctx context.Context, block *types.Block) error {
+	node, err := r.node()
+	if err != nil {
+		return err
+	}
+
+	if node.State() != hraft.Leader {
+		return fmt.Errorf("%w: %s: block %d", consensus.ErrNotAuthor, ErrNotLeader, block.Number)
+	}
+
+	data, err := block.Serialize()
+	if err != nil {
+		return err
+	}
+
+	timeout := commitTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	future := node.Apply(data, timeout)
+	if err := future.Error(); err != nil {
+		if errors.Is(err, hraft.ErrNotLeader) || errors.Is(err, hraft.ErrLeadershipLost) {
+			return fmt.Errorf("%w: %s: %s", consensus.ErrNotAuthor, ErrNotLeader, err)
+		}
+		return err
+	}
+
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+
+	r.removePunished(block.Punished)
+
+	return nil
+}
+
+// removePunished removes accounts punished by the committed block evidence from the cluster
+func (r *Raft) removePunished(accounts []common.Address) {
+	if len(accounts) == 0 {
+		return
+	}
+
+	members, err := r.Members()
+	if err != nil {
+		r.config.Logger.Warnw("Unable to get raft members to remove punished accounts", "err", err)
+		return
+	}
+
+	for _, member := range members {
+		for _, account := range accounts {
+			if member.Account != account {
+				continue
+			}
+
+			if err := r.RemoveMember(account); err != nil {
+				r.config.Logger.Warnw("Unable to remove punished raft member", "account", account, "err", err)
+			} else {
+				r.config.Logger.Warnw("Removed punished raft member", "account", account)
+			}
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package raft
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/go-hclog"
+	hraft "github.com/hashicorp/raft"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core/types"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var genesisHash = common.HexToHash("0x01")
+
+// testChain keeps headers of the blocks inserted on top of its head
+type testChain struct {
+	engine  *Raft
+	headers map[common.Hash]*types.BlockHeader
+	head    *types.BlockHeader
+	missing common.Hash // block, which is not inserted, as if the chain is behind the raft log
+	lock    sync.RWMutex
+}
+
+func newTestChain() *testChain {
+	genesis := &types.BlockHeader{BlockHash: genesisHash, NetherUsed: new(big.Int)}
+	return &testChain{
+		headers: map[common.Hash]*types.BlockHeader{genesisHash: genesis},
+		head:    genesis,
+	}
+}
+
+func (c *testChain) GetBlockHeader(hash common.Hash) (*types.BlockHeader, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	header, ok := c.headers[hash]
+	if !ok {
+		return nil, errors.New("block does not exists")
+	}
+	return header, nil
+}
+
+func (c *testChain) InsertBlock(ctx context.Context, block *types.Block) error {
+	if err := c.engine.VerifyHeader(&block.BlockHeader); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if block.BlockHash == c.missing {
+		return fmt.Errorf("block %s is missing", block.BlockHash)
+	}
+	if block.PrevHash != c.head.BlockHash {
+		return fmt.Errorf("block %s does not extend head %s", block.BlockHash, c.head.BlockHash)
+	}
+
+	header := block.BlockHeader
+	c.headers[header.BlockHash] = &header
+	c.head = &header
+	return nil
+}
+
+func (c *testChain) setMissing(hash common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.missing = hash
+}
+
+func (c *testChain) Head() *types.BlockHeader {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.head
+}
+
+type testNode struct {
+	account common.Address
+	key     *ecdsa.PrivateKey
+	chain   *testChain
+	engine  *Raft
+	addr    hraft.ServerAddress
+	resyncs int32 // resync requests of the node engine
+}
+
+// newTestCluster starts raft nodes connected by the in-memory transport,
+// the first one bootstraps the cluster and the rest are not its members yet
+func newTestCluster(t *testing.T, size int) []*testNode {
+	conf := hraft.DefaultConfig()
+	conf.HeartbeatTimeout = 50 * time.Millisecond
+	conf.ElectionTimeout = 50 * time.Millisecond
+	conf.LeaderLeaseTimeout = 50 * time.Millisecond
+	conf.CommitTimeout = 5 * time.Millisecond
+	conf.Logger = hclog.NewNullLogger()
+
+	nodes := make([]*testNode, size)
+	transports := make([]*hraft.InmemTransport, size)
+	for i := range nodes {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr, transport := hraft.NewInmemTransport("")
+		transports[i] = transport
+
+		node := &testNode{key: key, chain: newTestChain(), addr: addr}
+		chain := node.chain
+		store := hraft.NewInmemStore()
+		engine := New(chain, Config{
+			Transport:     transport,
+			LogStore:      store,
+			StableStore:   store,
+			SnapshotStore: hraft.NewInmemSnapshotStore(),
+			Bootstrap:     i == 0,
+			Raft:          conf,
+			Resync: func() {
+				atomic.AddInt32(&node.resyncs, 1)
+			},
+		})
+		chain.engine = engine
+
+		account := crypto.PubkeyToAddress(key.PublicKey)
+		engine.Authorize(account, nil, func(hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+
+		node.account, node.engine = account, engine
+		nodes[i] = node
+	}
+
+	for i := range transports {
+		for j := range transports {
+			if i != j {
+				transports[i].Connect(nodes[j].addr, transports[j])
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		if err := node.engine.Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, node := range nodes {
+			node.engine.Shutdown()
+		}
+	})
+
+	return nodes
+}
+
+// waitFor polls condition until it is true or the test times out
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitLeader(t *testing.T, nodes []*testNode) *testNode {
+	t.Helper()
+
+	var leader *testNode
+	waitFor(t, func() bool {
+		for _, node := range nodes {
+			if node.engine.IsLeader() {
+				leader = node
+				return true
+			}
+		}
+		return false
+	})
+	return leader
+}
+
+// sealBlock prepares and seals empty block on top of the node chain head
+func sealBlock(t *testing.T, node *testNode) (*types.Block, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	head := node.chain.Head()
+
+	header := types.BlockHeader{
+		PrevHash:   head.BlockHash,
+		Number:     head.Number + 1,
+		Timestamp:  time.Now().Unix(),
+		NetherUsed: new(big.Int),
+	}
+	if err := node.engine.Prepare(ctx, &header); err != nil {
+		return nil, err
+	}
+
+	block := types.NewBlock(header, nil)
+	if err := node.engine.Seal(ctx, block); err != nil {
+		return nil, err
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		return nil, err
+	}
+	block.BlockHash = common.BytesToHash(hash)
+
+	return block, nil
+}
+
+func TestCommitReplicatesBlocks(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 3)
+
+	leader := waitLeader(t, nodes)
+	if leader != nodes[0] {
+		t.Fatalf("bootstrap node is expected to be the leader")
+	}
+
+	for _, node := range nodes[1:] {
+		if err := leader.engine.AddVoter(node.account, string(node.addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	members, err := leader.engine.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != len(nodes) {
+		t.Fatalf("expected %d members, got %d", len(nodes), len(members))
+	}
+
+	author, err := nodes[1].engine.Author(ctx, nodes[1].chain.Head())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author != leader.account {
+		t.Fatalf("expected leader %s to be the author, got %s", leader.account, author)
+	}
+
+	for i := 0; i < 3; i++ {
+		block, err := sealBlock(t, leader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := leader.engine.Commit(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+
+		// leader block is inserted by the time commit returns
+		if leader.chain.Head().BlockHash != block.BlockHash {
+			t.Fatalf("leader head is not the committed block")
+		}
+	}
+
+	head := leader.chain.Head()
+	for _, node := range nodes[1:] {
+		node := node
+		waitFor(t, func() bool {
+			return node.chain.Head().BlockHash == head.BlockHash
+		})
+	}
+}
+
+func TestFollowerMayNotCommit(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 2)
+
+	leader := waitLeader(t, nodes)
+	if err := leader.engine.AddVoter(nodes[1].account, string(nodes[1].addr)); err != nil {
+		t.Fatal(err)
+	}
+
+	follower := nodes[1]
+	if _, err := sealBlock(t, follower); !errors.Is(err, consensus.ErrNotAuthor) {
+		t.Fatalf("expected %s, got %v", consensus.ErrNotAuthor, err)
+	}
+
+	// block sealed by the leader can not be committed by the follower
+	block, err := sealBlock(t, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := follower.engine.Commit(ctx, block); !errors.Is(err, consensus.ErrNotAuthor) {
+		t.Fatalf("expected %s, got %v", consensus.ErrNotAuthor, err)
+	}
+
+	// and is not inserted by anybody without commit
+	if err := follower.chain.InsertBlock(ctx, block); !errors.Is(err, ErrNotCommitted) {
+		t.Fatalf("expected %s, got %v", ErrNotCommitted, err)
+	}
+	if err := follower.engine.AddVoter(leader.account, string(leader.addr)); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("expected %s, got %v", ErrNotLeader, err)
+	}
+}
+
+func TestLeaderFailover(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 3)
+
+	leader := waitLeader(t, nodes)
+	for _, node := range nodes[1:] {
+		if err := leader.engine.AddVoter(node.account, string(node.addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block, err := sealBlock(t, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.engine.Commit(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := leader.engine.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rest []*testNode
+	for _, node := range nodes {
+		if node != leader {
+			rest = append(rest, node)
+		}
+	}
+
+	next := waitLeader(t, rest)
+	waitFor(t, func() bool {
+		return next.chain.Head().BlockHash == block.BlockHash
+	})
+
+	if err := next.engine.RemoveMember(leader.account); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err = sealBlock(t, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Coinbase != next.account {
+		t.Fatalf("expected new leader coinbase %s, got %s", next.account, block.Coinbase)
+	}
+	if err := next.engine.Commit(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, node := range rest {
+		node := node
+		waitFor(t, func() bool {
+			return node.chain.Head().BlockHash == block.BlockHash
+		})
+	}
+
+	members, err := next.engine.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != len(rest) {
+		t.Fatalf("expected %d members, got %d", len(rest), len(members))
+	}
+}
+
+func TestLaggingFollowerResync(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 2)
+
+	leader := waitLeader(t, nodes)
+	follower := nodes[1]
+	if err := leader.engine.AddVoter(follower.account, string(follower.addr)); err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []*types.Block
+	for i := 0; i < 2; i++ {
+		block, err := sealBlock(t, leader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			follower.chain.setMissing(block.BlockHash)
+		}
+		if err := leader.engine.Commit(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	// neither of committed blocks is inserted, so the follower requests resync
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&follower.resyncs) == int32(len(blocks))
+	})
+
+	// synced blocks are accepted up to the last committed one
+	follower.chain.setMissing(common.Hash{})
+	for _, block := range blocks {
+		if err := follower.chain.InsertBlock(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next, err := sealBlock(t, leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := follower.engine.VerifyHeader(&next.BlockHeader); !errors.Is(err, ErrNotCommitted) {
+		t.Fatalf("expected %s, got %v", ErrNotCommitted, err)
+	}
+
+	// another block of the committed height is not accepted
+	forked := next.BlockHeader
+	forked.Number = blocks[1].Number
+	forked.Timestamp++
+	signHeader(t, &forked, leader.key)
+	if err := follower.engine.VerifyHeader(&forked); !errors.Is(err, ErrNotCommitted) {
+		t.Fatalf("expected %s, got %v", ErrNotCommitted, err)
+	}
+
+	// and blocks sealed by non members are not accepted at all
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsider := blocks[0].BlockHeader
+	outsider.Coinbase = crypto.PubkeyToAddress(key.PublicKey)
+	signHeader(t, &outsider, key)
+	if err := follower.engine.VerifyHeader(&outsider); !errors.Is(err, ErrUnauthorizedSigner) {
+		t.Fatalf("expected %s, got %v", ErrUnauthorizedSigner, err)
+	}
+}
+
+// signHeader seals the header with the key and updates its hash
+func signHeader(t *testing.T, header *types.BlockHeader, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	hash, err := header.SealHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Signature, err = crypto.Sign(hash, key); err != nil {
+		t.Fatal(err)
+	}
+
+	blockHash, err := header.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.BlockHash = common.BytesToHash(blockHash)
+}
@@ -0,0 +1,208 @@
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/rlp"
+	hraft "github.com/hashicorp/raft"
+	"time"
+)
+
+var (
+	logsPrefix   = []byte("raftLogs/")
+	stablePrefix = []byte("raftStable/")
+
+	// errKeyNotFound is the stable store missing key error, raft library matches it by the message
+	errKeyNotFound = errors.New("not found")
+)
+
+func logDbPrefix(index uint64) []byte {
+	key := make([]byte, len(logsPrefix)+8)
+	copy(key, logsPrefix)
+	binary.BigEndian.PutUint64(key[len(logsPrefix):], index)
+	return key
+}
+
+func stableDbPrefix(key []byte) []byte {
+	return append(append([]byte{}, stablePrefix...), key...)
+}
+
+// storedLog is the raft log entry database encoding
+type storedLog struct {
+	Index      uint64
+	Term       uint64
+	Type       uint8
+	Data       []byte
+	Extensions []byte
+	AppendedAt uint64 // unix nanoseconds
+}
+
+// Store is the raft log and stable store, kept in the node badger database
+type Store struct {
+	db *badger.DB
+}
+
+// NewStore creates raft log and stable store within provided database
+func NewStore(db *badger.DB) *Store {
+	return &Store{db: db}
+}
+
+// FirstIndex returns the first stored log index, or 0 if there are no logs
+func (s *Store) FirstIndex() (uint64, error) {
+	return s.edgeIndex(false)
+}
+
+// LastIndex returns the last stored log index, or 0 if there are no logs
+func (s *Store) LastIndex() (uint64, error) {
+	return s.edgeIndex(true)
+}
+
+func (s *Store) edgeIndex(last bool) (uint64, error) {
+	var index uint64
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = logsPrefix
+		opts.Reverse = last
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		if last {
+			it.Seek(logDbPrefix(^uint64(0)))
+		} else {
+			it.Rewind()
+		}
+
+		if it.Valid() {
+			index = binary.BigEndian.Uint64(it.Item().Key()[len(logsPrefix):])
+		}
+		return nil
+	})
+
+	return index, err
+}
+
+// GetLog reads log entry by its index
+func (s *Store) GetLog(index uint64, log *hraft.Log) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(logDbPrefix(index))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return hraft.ErrLogNotFound
+			}
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			var stored storedLog
+			if err := rlp.DecodeBytes(val, &stored); err != nil {
+				return err
+			}
+
+			*log = hraft.Log{
+				Index:      stored.Index,
+				Term:       stored.Term,
+				Type:       hraft.LogType(stored.Type),
+				Data:       stored.Data,
+				Extensions: stored.Extensions,
+			}
+			if stored.AppendedAt > 0 {
+				log.AppendedAt = time.Unix(0, int64(stored.AppendedAt))
+			}
+			return nil
+		})
+	})
+}
+
+// StoreLog writes log entry
+func (s *Store) StoreLog(log *hraft.Log) error {
+	return s.StoreLogs([]*hraft.Log{log})
+}
+
+// StoreLogs writes log entries within single database transaction
+func (s *Store) StoreLogs(logs []*hraft.Log) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, log := range logs {
+			stored := storedLog{
+				Index:      log.Index,
+				Term:       log.Term,
+				Type:       uint8(log.Type),
+				Data:       log.Data,
+				Extensions: log.Extensions,
+			}
+			if !log.AppendedAt.IsZero() {
+				stored.AppendedAt = uint64(log.AppendedAt.UnixNano())
+			}
+
+			value, err := rlp.EncodeToBytes(stored)
+			if err != nil {
+				return err
+			}
+
+			if err := txn.Set(logDbPrefix(log.Index), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRange removes log entries from min to max index inclusively
+func (s *Store) DeleteRange(min, max uint64) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for index := min; index <= max; index++ {
+			if err := txn.Delete(logDbPrefix(index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Set writes stable store value
+func (s *Store) Set(key []byte, val []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(stableDbPrefix(key), val)
+	})
+}
+
+// Get reads stable store value
+func (s *Store) Get(key []byte) ([]byte, error) {
+	var value []byte
+
+	if err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(stableDbPrefix(key))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return errKeyNotFound
+			}
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// SetUint64 writes stable store integer value
+func (s *Store) SetUint64(key []byte, val uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, val)
+	return s.Set(key, value)
+}
+
+// GetUint64 reads stable store integer value
+func (s *Store) GetUint64(key []byte) (uint64, error) {
+	value, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
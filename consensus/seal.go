@@ -0,0 +1,45 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"math/big"
+)
+
+var (
+	ErrMissingSignature = errors.New("block header signature is missing")
+	ErrInvalidSignature = errors.New("invalid block header signature")
+)
+
+// Ecrecover returns address of the account, which has signed block header seal hash.
+// Only canonical low S value signatures are accepted
+func Ecrecover(header *types.BlockHeader) (common.Address, error) {
+	if len(header.Signature) == 0 {
+		return common.Address{}, ErrMissingSignature
+	}
+	if len(header.Signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: length %d", ErrInvalidSignature, len(header.Signature))
+	}
+
+	// high S value signature recovers the same signer, so the same seal would have another block hash
+	r := new(big.Int).SetBytes(header.Signature[:32])
+	s := new(big.Int).SetBytes(header.Signature[32:64])
+	if !crypto.ValidateSignatureValues(header.Signature[64], r, s, true) {
+		return common.Address{}, fmt.Errorf("%w: malleable signature values", ErrInvalidSignature)
+	}
+
+	hash, err := header.SealHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pub, err := crypto.SigToPub(hash, header.Signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
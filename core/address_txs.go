@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+)
+
+const (
+	DefaultAddressTxsLimit = 20
+	MaxAddressTxsLimit     = 100
+
+	addressTxPositionLen = 12 // block number and tx index
+)
+
+var (
+	ErrInvalidCursor = fmt.Errorf("invalid cursor")
+)
+
+// addressTxPosition returns big endian encoded block number and tx index,
+// so address transactions index keys are sorted by chain order
+func addressTxPosition(blockNumber uint64, txIndex int) []byte {
+	pos := make([]byte, addressTxPositionLen)
+	binary.BigEndian.PutUint64(pos[:8], blockNumber)
+	binary.BigEndian.PutUint32(pos[8:], uint32(txIndex))
+	return pos
+}
+
+// saveAddressTx writes address transactions index entry within provided database transaction
+func saveAddressTx(txn *badger.Txn, addr common.Address, txHash common.Hash, blockNumber uint64, txIndex int) error {
+	key := append(addressTxsDbPrefix(addr), addressTxPosition(blockNumber, txIndex)...)
+	return txn.Set(key, txHash.Bytes())
+}
+
+// indexAddressTx writes both sender and recipient transactions index entries.
+// Reward transactions are indexed only for recipient
+func indexAddressTx(txn *badger.Txn, tx *types.SignedTx, txHash common.Hash, blockNumber uint64, txIndex int) error {
+	if !tx.IsReward() {
+		if err := saveAddressTx(txn, tx.From, txHash, blockNumber, txIndex); err != nil {
+			return err
+		}
+
+		if tx.From == tx.To {
+			return nil
+		}
+	}
+
+	return saveAddressTx(txn, tx.To, txHash, blockNumber, txIndex)
+}
+
+// TransactionsByAddress returns transactions sent or received by address in chain order,
+// or in reverse order if desc is set. Cursor is an opaque value returned with the previous page,
+// empty cursor starts from the first (or last) address transaction. Returned next cursor is empty
+// when there are no more transactions
+func (bc *BlockChain) TransactionsByAddress(addr common.Address, cursor string, limit int, desc bool) ([]*types.AddressTx, string, error) {
+	var from []byte
+	if len(cursor) > 0 {
+		pos, err := hex.DecodeString(cursor)
+		if err != nil || len(pos) != addressTxPositionLen {
+			return nil, "", ErrInvalidCursor
+		}
+		from = pos
+	}
+
+	if limit <= 0 {
+		limit = DefaultAddressTxsLimit
+	}
+	if limit > MaxAddressTxsLimit {
+		limit = MaxAddressTxsLimit
+	}
+
+	var results []*types.AddressTx
+	var next string
+
+	prefix := addressTxsDbPrefix(addr)
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.Reverse = desc
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := append(append([]byte{}, prefix...), from...)
+		if from == nil && desc {
+			seek = append(seek, bytes.Repeat([]byte{0xff}, addressTxPositionLen)...)
+		}
+
+		for it.Seek(seek); it.Valid(); it.Next() {
+			item := it.Item()
+			pos := item.Key()[len(prefix):]
+			if from != nil && bytes.Equal(pos, from) {
+				continue
+			}
+
+			if len(results) == limit {
+				last := results[len(results)-1]
+				next = hex.EncodeToString(addressTxPosition(last.BlockNumber, last.TxIndex))
+				break
+			}
+
+			hashValue, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			txHash := common.BytesToHash(hashValue)
+			tx, err := getTx(txn, txHash)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, &types.AddressTx{
+				TxHash:      txHash,
+				BlockNumber: binary.BigEndian.Uint64(pos[:8]),
+				TxIndex:     int(binary.BigEndian.Uint32(pos[8:])),
+				Tx:          tx,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		bc.logger.Errorw("Unable to iterate address transactions", "addr", addr, "err", err)
+		return nil, "", err
+	}
+
+	return results, next, nil
+}
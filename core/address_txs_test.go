@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestTransactionsByAddress(t *testing.T) {
+	ctx := context.Background()
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	author := crypto.PubkeyToAddress(authorKey.PublicKey)
+	recipient := common.HexToAddress("0x55")
+
+	bc := newTestChain(t, genesisAlloc{sender: {Balance: big.NewInt(1e9)}})
+	for nonce := uint64(1); nonce <= 5; nonce++ {
+		block := newTestBlock(t, bc, authorKey, nil, newTestTx(t, bc, senderKey, recipient, big.NewInt(1), nonce))
+		if err := bc.InsertBlock(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		addr   common.Address
+		limit  int
+		desc   bool
+		blocks [][]uint64
+	}{
+		{name: "sender pages", addr: sender, limit: 2, blocks: [][]uint64{{1, 2}, {3, 4}, {5}}},
+		{name: "sender pages in reverse order", addr: sender, limit: 2, desc: true, blocks: [][]uint64{{5, 4}, {3, 2}, {1}}},
+		{name: "limit of the last page", addr: recipient, limit: 5, blocks: [][]uint64{{1, 2, 3, 4, 5}}},
+		{name: "default limit", addr: recipient, blocks: [][]uint64{{1, 2, 3, 4, 5}}},
+		{name: "reward recipient", addr: author, limit: 3, desc: true, blocks: [][]uint64{{5, 4, 3}, {2, 1}}},
+		{name: "unknown address", addr: common.HexToAddress("0x66"), limit: 2, blocks: [][]uint64{nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cursor string
+			for i, expected := range tt.blocks {
+				txs, next, err := bc.TransactionsByAddress(tt.addr, cursor, tt.limit, tt.desc)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var blocks []uint64
+				for _, tx := range txs {
+					if tx.Tx.From != tt.addr && tx.Tx.To != tt.addr {
+						t.Fatalf("tx %s is not sent or received by %s", tx.TxHash, tt.addr)
+					}
+					blocks = append(blocks, tx.BlockNumber)
+				}
+				if !reflect.DeepEqual(blocks, expected) {
+					t.Fatalf("page #%d: expected blocks %v, got %v", i, expected, blocks)
+				}
+
+				if last := i == len(tt.blocks)-1; last != (next == "") {
+					t.Fatalf("page #%d: unexpected next cursor %q", i, next)
+				}
+				cursor = next
+			}
+		})
+	}
+
+	if _, _, err := bc.TransactionsByAddress(sender, "00ff", 2, false); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected %s, got %v", ErrInvalidCursor, err)
+	}
+}
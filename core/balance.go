@@ -4,24 +4,79 @@ import (
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/rovergulf/chain/core/types"
+	"math/big"
 )
 
 func (bc *BlockChain) GetBalance(addr common.Address) (*types.Balance, error) {
-	var balance types.Balance
+	var balance *types.Balance
 
-	key := balanceDbPrefix(addr)
 	if err := bc.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(key)
+		b, err := getBalance(txn, addr)
+		if err != nil {
+			return err
+		}
+
+		balance = b
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+// GetBalanceOrEmpty returns address balance, or empty one if address has not been registered yet
+func (bc *BlockChain) GetBalanceOrEmpty(addr common.Address) (*types.Balance, error) {
+	var balance *types.Balance
+
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		b, err := getBalanceOrEmpty(txn, addr)
+		if err != nil {
+			return err
+		}
+
+		balance = b
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+// GetBalanceAt returns address balance in the block state with provided root
+func (bc *BlockChain) GetBalanceAt(addr common.Address, root common.Hash) (*types.Balance, error) {
+	var balance *types.Balance
+
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		state, err := newBlockState(txn, root)
 		if err != nil {
-			if err == badger.ErrKeyNotFound {
-				return ErrBalanceNotExists
-			}
 			return err
 		}
 
-		return item.Value(func(val []byte) error {
-			return balance.Deserialize(val)
-		})
+		balance, err = state.getBalance(addr)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+// getBalance reads address balance within provided database transaction
+func getBalance(txn *badger.Txn, addr common.Address) (*types.Balance, error) {
+	var balance types.Balance
+
+	item, err := txn.Get(balanceDbPrefix(addr))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrBalanceNotExists
+		}
+		return nil, err
+	}
+
+	if err := item.Value(func(val []byte) error {
+		return balance.Deserialize(val)
 	}); err != nil {
 		return nil, err
 	}
@@ -29,6 +84,41 @@ func (bc *BlockChain) GetBalance(addr common.Address) (*types.Balance, error) {
 	return &balance, nil
 }
 
+// getBalanceOrEmpty returns empty balance if address has not been registered yet
+func getBalanceOrEmpty(txn *badger.Txn, addr common.Address) (*types.Balance, error) {
+	balance, err := getBalance(txn, addr)
+	if err != nil {
+		if err == ErrBalanceNotExists {
+			return &types.Balance{Address: addr, Balance: new(big.Int)}, nil
+		}
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+// addBalance adds value to the account balance, result must fit 256 bits
+func addBalance(balance *types.Balance, value *big.Int) error {
+	sum := new(big.Int).Add(balance.Balance, value)
+	if sum.BitLen() > 256 {
+		return ErrBalanceOverflow
+	}
+
+	balance.Balance = sum
+	return nil
+}
+
+// subBalance subtracts value from the account balance, result must not be negative
+func subBalance(balance *types.Balance, value *big.Int) error {
+	diff := new(big.Int).Sub(balance.Balance, value)
+	if diff.Sign() < 0 {
+		return ErrInsufficientBalance
+	}
+
+	balance.Balance = diff
+	return nil
+}
+
 func (bc *BlockChain) ListBalances() ([]*types.Balance, error) {
 	var balances []*types.Balance
 
@@ -58,39 +148,14 @@ func (bc *BlockChain) ListBalances() ([]*types.Balance, error) {
 	return balances, nil
 }
 
-func (bc *BlockChain) NewBalance(addr common.Address, value uint64) (*types.Balance, error) {
-	balance := &types.Balance{
-		Address: addr,
-		Balance: value,
-		Nonce:   0,
-	}
-
-	data, err := balance.Serialize()
-	if err != nil {
-		return nil, err
-	}
-
-	key := balanceDbPrefix(addr)
-	if err := bc.db.Update(func(txn *badger.Txn) error {
-		if _, err := txn.Get(key); err == nil {
-			return ErrBalanceAlreadyExists
-		}
-
-		return txn.Set(key, data)
-	}); err != nil {
-		return nil, err
-	}
-
-	return balance, nil
-}
-
+// GetNextAccountNonce returns nonce of the next account transaction, which is 1 for a new account
 func (bc *BlockChain) GetNextAccountNonce(addr common.Address) uint64 {
 	b, err := bc.GetBalance(addr)
 	if err != nil {
-		if err != badger.ErrKeyNotFound {
-			bc.logger.Errorw("Unable to get balance: %s", err)
+		if err != ErrBalanceNotExists {
+			bc.logger.Errorf("Unable to get balance: %s", err)
 		}
-		return 0
+		return 1
 	}
 
 	return b.Nonce + 1
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"math/big"
+	"testing"
+)
+
+// maxBalance is the largest balance fitting 256 bits
+var maxBalance = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+func TestAddBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance *big.Int
+		value   *big.Int
+		err     error
+	}{
+		{name: "add", balance: big.NewInt(1), value: big.NewInt(2)},
+		{name: "max balance", balance: new(big.Int).Sub(maxBalance, big.NewInt(2)), value: big.NewInt(2)},
+		{name: "overflow", balance: maxBalance, value: big.NewInt(1), err: ErrBalanceOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balance := &types.Balance{Balance: new(big.Int).Set(tt.balance)}
+			if err := addBalance(balance, tt.value); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			expected := new(big.Int).Add(tt.balance, tt.value)
+			if tt.err != nil {
+				expected = tt.balance
+			}
+			if balance.Balance.Cmp(expected) != 0 {
+				t.Fatalf("expected balance %s, got %s", expected, balance.Balance)
+			}
+		})
+	}
+}
+
+func TestExecuteBlockBalanceOverflow(t *testing.T) {
+	ctx := context.Background()
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	recipient := common.HexToAddress("0x55")
+
+	bc := newTestChain(t, genesisAlloc{
+		sender:    {Balance: big.NewInt(1e9)},
+		recipient: {Balance: maxBalance},
+	})
+
+	block := newTestBlockTxs(t, bc, authorKey, newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 1))
+	if _, _, err := bc.ExecuteBlock(ctx, block); !errors.Is(err, ErrBalanceOverflow) {
+		t.Fatalf("expected %s, got %v", ErrBalanceOverflow, err)
+	}
+
+	block.Root = common.HexToHash("0x01")
+	sealTestBlock(t, block, authorKey)
+	if err := bc.InsertBlock(ctx, block); !errors.Is(err, ErrBalanceOverflow) {
+		t.Fatalf("expected %s, got %v", ErrBalanceOverflow, err)
+	}
+
+	balance, err := bc.GetBalance(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance.Cmp(maxBalance) != 0 {
+		t.Fatalf("expected recipient balance %s, got %s", maxBalance, balance.Balance)
+	}
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
+	"time"
+)
+
+// validateBlock checks block values, which do not depend on the parent block state:
+// header hashes, timestamp, transactions chain id and signatures, nether used and reward transactions
+// of the block author and provided recipients, which are the consensus validators of the parent block.
+// Nonce sequencing, balances, evidence and state root are checked on block execution
+func validateBlock(signer types.Signer, blockTime int64, parent *types.BlockHeader, recipients []common.Address, block *types.Block) error {
+	if err := validateBlockRules(signer, blockTime, parent, recipients, block); err != nil {
+		return err
+	}
+
+	if block.Timestamp > time.Now().Unix()+params.MaxFutureBlockTime {
+		return fmt.Errorf("%w: %d", ErrFutureBlock, block.Timestamp)
+	}
+
+	return nil
+}
+
+// validateHeader checks the header extends its parent and is not too far in the future.
+// Header hash is checked by the caller, and its body hashes once the block body is received
+func validateHeader(parent, header *types.BlockHeader, blockTime int64) error {
+	if err := validateHeaderRules(parent, header, blockTime); err != nil {
+		return err
+	}
+
+	if header.Timestamp > time.Now().Unix()+params.MaxFutureBlockTime {
+		return fmt.Errorf("%w: %d", ErrFutureBlock, header.Timestamp)
+	}
+
+	return nil
+}
+
+// validateHeaderRules checks header parent hash, number and timestamp, which may not precede
+// the parent one by less than the chain block time. Fallback authors delay is checked by the consensus engine
+func validateHeaderRules(parent, header *types.BlockHeader, blockTime int64) error {
+	if header.PrevHash != parent.BlockHash {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidPrevHash, header.PrevHash, parent.BlockHash)
+	}
+
+	if header.Number != parent.Number+1 {
+		return fmt.Errorf("%w: %d; expected: %d", ErrInvalidBlockNumber, header.Number, parent.Number+1)
+	}
+
+	if header.Timestamp < parent.Timestamp+blockTime {
+		return fmt.Errorf("%w: %d; parent: %d, block time: %d", ErrInvalidTimestamp, header.Timestamp, parent.Timestamp, blockTime)
+	}
+
+	return nil
+}
+
+// validateBlockRules checks block values the same way as validateBlock, except the local clock,
+// so the result is the same on every node and invalid block proves its author fault
+func validateBlockRules(signer types.Signer, blockTime int64, parent *types.BlockHeader, recipients []common.Address, block *types.Block) error {
+	if err := validateHeaderRules(parent, &block.BlockHeader, blockTime); err != nil {
+		return err
+	}
+
+	if err := validateBlockHashes(block); err != nil {
+		return err
+	}
+
+	netherUsed := new(big.Int)
+	var rewards []*types.SignedTx
+	for i, tx := range block.Transactions {
+		if err := validateTxValues(&tx.Transaction); err != nil {
+			return fmt.Errorf("%w: tx #%d", err, i)
+		}
+
+		ok, err := tx.IsAuthentic(signer)
+		if errors.Is(err, types.ErrInvalidChainId) {
+			return fmt.Errorf("%w: tx #%d", err, i)
+		}
+
+		if tx.IsReward() {
+			rewards = append(rewards, tx)
+			continue
+		}
+
+		if err != nil || !ok {
+			return fmt.Errorf("%w: tx #%d", ErrInvalidTxSignature, i)
+		}
+
+		netherUsed.Add(netherUsed, tx.Nether)
+	}
+
+	if block.NetherUsed == nil || netherUsed.Cmp(block.NetherUsed) != 0 {
+		return fmt.Errorf("%w: %d; expected: %d", ErrInvalidNetherUsed, block.NetherUsed, netherUsed)
+	}
+
+	return validateRewardTxs(signer, block, recipients, rewards)
+}
+
+// validateBlockHashes recomputes block transactions hash and bloom, evidence and block hashes
+func validateBlockHashes(block *types.Block) error {
+	if IsHashEmpty(block.Root) {
+		return fmt.Errorf("%w: empty state root", ErrInvalidStateRoot)
+	}
+
+	txHash, err := block.HashTransactions()
+	if err != nil {
+		return err
+	}
+	if common.BytesToHash(txHash) != block.TxHash {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidTxHash, block.TxHash, common.BytesToHash(txHash))
+	}
+	if types.CreateBloom(block.Transactions) != block.Bloom {
+		return ErrInvalidBloom
+	}
+
+	if len(block.Evidence) > params.EvidencePerBlockLimit {
+		return fmt.Errorf("%w: %d; limit: %d", ErrTooMuchEvidence, len(block.Evidence), params.EvidencePerBlockLimit)
+	}
+
+	evidenceHash, err := block.HashEvidence()
+	if err != nil {
+		return err
+	}
+	if evidenceHash != block.EvidenceHash {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidEvidenceHash, block.EvidenceHash, evidenceHash)
+	}
+
+	blockHash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if common.BytesToHash(blockHash) != block.BlockHash {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidBlockHash, block.BlockHash, common.BytesToHash(blockHash))
+	}
+
+	return nil
+}
+
+// validateRewardTxs checks reward transactions are signed by block author and match the ones
+// computed for the block author and provided recipients, in the same order
+func validateRewardTxs(signer types.Signer, block *types.Block, recipients []common.Address, rewards []*types.SignedTx) error {
+	expected, err := rewardTxs(block, recipients)
+	if err != nil {
+		return err
+	}
+
+	if len(rewards) != len(expected) {
+		return fmt.Errorf("%w: %d reward txs; expected: %d", ErrInvalidRewardTxs, len(rewards), len(expected))
+	}
+
+	for i, tx := range rewards {
+		sender, err := signer.Sender(tx)
+		if err != nil || sender != block.Coinbase {
+			return fmt.Errorf("%w: is not signed by block author", ErrInvalidRewardTxs)
+		}
+
+		want := &expected[i]
+		if tx.From != want.From || tx.To != want.To || tx.Nonce != want.Nonce ||
+			tx.Value.Cmp(want.Value) != 0 || tx.Nether.Cmp(want.Nether) != 0 || tx.NetherPrice != want.NetherPrice {
+			return fmt.Errorf("%w: reward #%d to %s value %d; expected: %s value %d",
+				ErrInvalidRewardTxs, i, tx.To, tx.Value, want.To, want.Value)
+		}
+	}
+
+	return nil
+}
+
+// validateTxValues checks transaction value and nether are set and not negative
+func validateTxValues(tx *types.Transaction) error {
+	if tx.Value == nil || tx.Value.Sign() < 0 {
+		return fmt.Errorf("%w: value %s", ErrInvalidTxValue, tx.Value)
+	}
+
+	if tx.Nether == nil || tx.Nether.Sign() < 0 {
+		return fmt.Errorf("%w: nether %s", ErrInvalidTxValue, tx.Nether)
+	}
+
+	return nil
+}
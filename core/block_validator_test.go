@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// rehashTestBlock recomputes modified block transactions hash and seals it again
+func rehashTestBlock(t *testing.T, block *types.Block, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	txHash, err := block.HashTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.TxHash = common.BytesToHash(txHash)
+	block.Bloom = types.CreateBloom(block.Transactions)
+	sealTestBlock(t, block, key)
+}
+
+// setTestRewards replaces block reward transactions with the ones for provided recipients
+func setTestRewards(t *testing.T, bc *BlockChain, block *types.Block, key *ecdsa.PrivateKey, recipients []common.Address) {
+	t.Helper()
+
+	var txs []*types.SignedTx
+	for _, tx := range block.Transactions {
+		if !tx.IsReward() {
+			txs = append(txs, tx)
+		}
+	}
+	block.Transactions = txs
+
+	if err := AppendRewardTxs(block, recipients, func(tx *types.Transaction) (*types.SignedTx, error) {
+		return bc.Signer().Sign(*tx, key)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rehashTestBlock(t, block, key)
+}
+
+func TestValidateBlock(t *testing.T) {
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	validators := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0xff")}
+
+	bc := newTestChain(t, genesisAlloc{sender: {Balance: big.NewInt(1e9)}})
+	parent, err := bc.GetBlockHeader(bc.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resignReward replaces the reward transaction at index, so it is signed by the key account
+	resignReward := func(t *testing.T, block *types.Block, i int, key *ecdsa.PrivateKey, modify func(tx *types.Transaction)) {
+		tx := block.Transactions[i].Transaction
+		tx.Value = new(big.Int).Set(tx.Value)
+		modify(&tx)
+		signedTx, err := bc.Signer().Sign(tx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Transactions[i] = signedTx
+		rehashTestBlock(t, block, authorKey)
+	}
+
+	tests := []struct {
+		name       string
+		recipients []common.Address
+		modify     func(t *testing.T, block *types.Block)
+		err        error
+	}{
+		{name: "valid", modify: func(t *testing.T, block *types.Block) {}},
+		{name: "valid with validators rewards", recipients: validators, modify: func(t *testing.T, block *types.Block) {
+			setTestRewards(t, bc, block, authorKey, validators)
+		}},
+		{name: "previous hash", err: ErrInvalidPrevHash, modify: func(t *testing.T, block *types.Block) {
+			block.PrevHash = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "block number", err: ErrInvalidBlockNumber, modify: func(t *testing.T, block *types.Block) {
+			block.Number++
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "timestamp older than parent", err: ErrInvalidTimestamp, modify: func(t *testing.T, block *types.Block) {
+			block.Timestamp = parent.Timestamp - 1
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "timestamp within parent block time", err: ErrInvalidTimestamp, modify: func(t *testing.T, block *types.Block) {
+			block.Timestamp = parent.Timestamp + bc.BlockTime() - 1
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "future timestamp", err: ErrFutureBlock, modify: func(t *testing.T, block *types.Block) {
+			block.Timestamp = time.Now().Unix() + params.MaxFutureBlockTime + 60
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "empty state root", err: ErrInvalidStateRoot, modify: func(t *testing.T, block *types.Block) {
+			block.Root = common.Hash{}
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "transactions hash", err: ErrInvalidTxHash, modify: func(t *testing.T, block *types.Block) {
+			block.TxHash = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "addresses bloom", err: ErrInvalidBloom, modify: func(t *testing.T, block *types.Block) {
+			block.Bloom.Add(common.HexToAddress("0x66"))
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "too much evidence", err: ErrTooMuchEvidence, modify: func(t *testing.T, block *types.Block) {
+			block.Evidence = make([]*types.Evidence, params.EvidencePerBlockLimit+1)
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "evidence hash", err: ErrInvalidEvidenceHash, modify: func(t *testing.T, block *types.Block) {
+			block.EvidenceHash = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "block hash", err: ErrInvalidBlockHash, modify: func(t *testing.T, block *types.Block) {
+			block.BlockHash = common.HexToHash("0x01")
+		}},
+		{name: "empty tx nether", err: ErrInvalidTxValue, modify: func(t *testing.T, block *types.Block) {
+			block.Transactions[0].Nether = nil
+			rehashTestBlock(t, block, authorKey)
+		}},
+		{name: "tx signature", err: ErrInvalidTxSignature, modify: func(t *testing.T, block *types.Block) {
+			block.Transactions[0].Value = big.NewInt(11)
+			rehashTestBlock(t, block, authorKey)
+		}},
+		{name: "tx of other chain", err: types.ErrInvalidChainId, modify: func(t *testing.T, block *types.Block) {
+			signedTx, err := types.NewSigner(big.NewInt(1<<20)).Sign(block.Transactions[0].Transaction, senderKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.Transactions[0] = signedTx
+			rehashTestBlock(t, block, authorKey)
+		}},
+		{name: "nether used", err: ErrInvalidNetherUsed, modify: func(t *testing.T, block *types.Block) {
+			block.NetherUsed = new(big.Int).Add(block.NetherUsed, big.NewInt(1))
+			sealTestBlock(t, block, authorKey)
+		}},
+		{name: "missing validators rewards", recipients: validators, err: ErrInvalidRewardTxs, modify: func(t *testing.T, block *types.Block) {}},
+		{name: "extra validator reward", err: ErrInvalidRewardTxs, modify: func(t *testing.T, block *types.Block) {
+			setTestRewards(t, bc, block, authorKey, validators)
+		}},
+		{name: "other validator rewarded", recipients: validators, err: ErrInvalidRewardTxs, modify: func(t *testing.T, block *types.Block) {
+			setTestRewards(t, bc, block, authorKey, []common.Address{validators[0], common.HexToAddress("0xee")})
+		}},
+		{name: "rewards order", recipients: validators, err: ErrInvalidRewardTxs, modify: func(t *testing.T, block *types.Block) {
+			setTestRewards(t, bc, block, authorKey, validators)
+			n := len(block.Transactions)
+			block.Transactions[n-1], block.Transactions[n-2] = block.Transactions[n-2], block.Transactions[n-1]
+			rehashTestBlock(t, block, authorKey)
+		}},
+		{name: "reward amount", err: ErrInvalidRewardTxs, modify: func(t *testing.T, block *types.Block) {
+			resignReward(t, block, len(block.Transactions)-1, authorKey, func(tx *types.Transaction) {
+				tx.Value.Add(tx.Value, big.NewInt(1))
+			})
+		}},
+		{name: "reward signed by other account", err: ErrInvalidRewardTxs, modify: func(t *testing.T, block *types.Block) {
+			resignReward(t, block, len(block.Transactions)-1, otherKey, func(tx *types.Transaction) {})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := newTestBlock(t, bc, authorKey, nil, newTestTx(t, bc, senderKey, common.HexToAddress("0x55"), big.NewInt(10), 1))
+			tt.modify(t, block)
+
+			err := validateBlock(bc.Signer(), bc.BlockTime(), parent, tt.recipients, block)
+			if tt.err == nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %s, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestInsertBlockExecution(t *testing.T) {
+	ctx := context.Background()
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	recipient := common.HexToAddress("0x55")
+
+	tests := []struct {
+		name  string
+		block func(t *testing.T, bc *BlockChain) *types.Block
+		err   error
+	}{
+		{name: "tx nonce", err: ErrInvalidTxNonce, block: func(t *testing.T, bc *BlockChain) *types.Block {
+			block := newTestBlockTxs(t, bc, authorKey, newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 2))
+			block.Root = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+			return block
+		}},
+		{name: "insufficient balance", err: ErrInsufficientBalance, block: func(t *testing.T, bc *BlockChain) *types.Block {
+			block := newTestBlockTxs(t, bc, authorKey, newTestTx(t, bc, senderKey, recipient, big.NewInt(1e12), 1))
+			block.Root = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+			return block
+		}},
+		{name: "state root", err: ErrInvalidStateRoot, block: func(t *testing.T, bc *BlockChain) *types.Block {
+			block := newTestBlock(t, bc, authorKey, nil, newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 1))
+			block.Root = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+			return block
+		}},
+		{name: "receipts hash", err: ErrInvalidReceiptHash, block: func(t *testing.T, bc *BlockChain) *types.Block {
+			block := newTestBlock(t, bc, authorKey, nil, newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 1))
+			block.ReceiptHash = common.HexToHash("0x01")
+			sealTestBlock(t, block, authorKey)
+			return block
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestChain(t, genesisAlloc{sender: {Balance: big.NewInt(1e9)}})
+			if err := bc.InsertBlock(ctx, tt.block(t, bc)); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %s, got %v", tt.err, err)
+			}
+			if bc.ChainLength != 1 {
+				t.Fatalf("invalid block is inserted, chain length %d", bc.ChainLength)
+			}
+		})
+	}
+}
@@ -2,14 +2,18 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/opentracing/opentracing-go"
+	"github.com/rovergulf/chain/consensus"
 	"github.com/rovergulf/chain/core/types"
 	"github.com/rovergulf/chain/database/badgerdb"
 	"github.com/rovergulf/chain/params"
 	"go.uber.org/zap"
 	"io"
+	"sync"
 )
 
 type BlockChain struct {
@@ -19,9 +23,20 @@ type BlockChain struct {
 	genesis *Genesis
 	//currentBlock *types.Block
 
-	//mu *sync.RWMutex
+	headHeader common.Hash // the heaviest header chain head, which may be ahead of blocks
+
+	mu sync.RWMutex // protects chain tip on block insertion
+
+	safe      uint64 // the highest block confirmed by the majority of block authors
+	finalized uint64 // the highest block, which may not be reverted
+
+	reorgFeed event.Feed
+	headFeed  event.Feed
+
+	engine consensus.Engine // verifies blocks consensus seal, if set
 
 	db     *badger.DB
+	dbPath string
 	logger *zap.SugaredLogger
 	tracer opentracing.Tracer
 	closer io.Closer
@@ -36,6 +51,11 @@ func (bc *BlockChain) Shutdown() {
 }
 
 func NewBlockChain(opts params.Options) (*BlockChain, error) {
+	if err := recoverMigration(opts.DbFilePath); err != nil {
+		opts.Logger.Errorf("Unable to recover interrupted database migration: %s", err)
+		return nil, err
+	}
+
 	opts.Badger = badger.DefaultOptions(opts.DbFilePath)
 	db, err := badgerdb.OpenDB(opts.DbFilePath, opts.Badger)
 	if err != nil {
@@ -47,11 +67,39 @@ func NewBlockChain(opts params.Options) (*BlockChain, error) {
 		LastHash:    common.HexToHash(""),
 		ChainLength: 0,
 		db:          db,
+		dbPath:      opts.DbFilePath,
 		logger:      opts.Logger,
 		tracer:      opts.Tracer,
 	}, nil
 }
 
+// SetEngine sets consensus engine, which verifies every inserted block header and decides its finality.
+// Blocks are not verified by consensus rules until engine is set
+func (bc *BlockChain) SetEngine(engine consensus.Engine) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.engine = engine
+
+	// safe block is not stored, so it is restored by the chain tip
+	if head, err := bc.GetBlockHeader(bc.LastHash); err == nil {
+		bc.updateFinality(head)
+	}
+}
+
+// verifyHeader checks block header against consensus engine rules
+func (bc *BlockChain) verifyHeader(header *types.BlockHeader) error {
+	if bc.engine == nil {
+		return nil
+	}
+
+	if err := bc.engine.VerifyHeader(header); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBlockSeal, err)
+	}
+
+	return nil
+}
+
 func (bc *BlockChain) Run(ctx context.Context) error {
 	if err := bc.loadGenesis(ctx); err != nil {
 		return err
@@ -63,6 +111,10 @@ func (bc *BlockChain) Run(ctx context.Context) error {
 // LoadChainState loads BlockChain state from database
 func (bc *BlockChain) LoadChainState(ctx context.Context) error {
 	return bc.db.View(func(txn *badger.Txn) error {
+		if err := checkDatabaseVersion(txn); err != nil {
+			return err
+		}
+
 		lh, err := txn.Get(lastHashKey)
 		if err != nil {
 			// is it ok??
@@ -73,6 +125,30 @@ func (bc *BlockChain) LoadChainState(ctx context.Context) error {
 			return err
 		}
 
+		if bc.genesis == nil {
+			if err := bc.loadGenesis(ctx); err != nil {
+				return err
+			}
+		}
+
+		if hh, err := txn.Get(headHeaderKey); err == nil {
+			if err := hh.Value(func(val []byte) error {
+				bc.headHeader = common.BytesToHash(val)
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		finalized, err := getFinalized(txn)
+		if err != nil {
+			return err
+		}
+		bc.finalized = finalized
+		bc.safe = finalized
+
 		return lh.Value(func(val []byte) error {
 			bc.LastHash = common.BytesToHash(val)
 
@@ -2,16 +2,40 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rovergulf/chain/core/types"
 	"github.com/rovergulf/chain/pkg/traceutil"
 	"github.com/spf13/viper"
+	"math/big"
 )
 
 func (bc *BlockChain) NewGenesisBlockWithRewrite(ctx context.Context) error {
-	gen := genesisByNetworkId(viper.GetString("network.id"))
+	gen := genesisByNetworkId(big.NewInt(viper.GetInt64("network.id")))
+
+	// authorized signers may be replaced, e.g. by the local node account for development network
+	if signers := viper.GetStringSlice("genesis.signers"); len(signers) > 0 {
+		gen.Signers = gen.Signers[:0]
+		for _, signer := range signers {
+			if !common.IsHexAddress(signer) {
+				return fmt.Errorf("invalid genesis signer address: %s", signer)
+			}
+			gen.Signers = append(gen.Signers, common.HexToAddress(signer))
+		}
+	}
+
+	// zero block time lets the development network seal blocks as soon as transactions arrive
+	if viper.IsSet("genesis.block_time") {
+		gen.BlockTime = viper.GetInt64("genesis.block_time")
+	}
+
+	return bc.writeGenesis(gen)
+}
 
+// writeGenesis writes genesis, its block and allocation balances state with current database version
+func (bc *BlockChain) writeGenesis(gen *Genesis) error {
 	genesisBlock, err := gen.ToBlock()
 	if err != nil {
 		bc.logger.Errorf("Unable to prepare genesis block")
@@ -30,9 +54,20 @@ func (bc *BlockChain) NewGenesisBlockWithRewrite(ctx context.Context) error {
 		return err
 	}
 
+	serializedHeader, err := genesisBlock.BlockHeader.Serialize()
+	if err != nil {
+		bc.logger.Errorf("Unable to serialize genesis block header: %s", err)
+		return err
+	}
+
 	blockKey := blockDbPrefix(genesisBlock.BlockHeader.BlockHash)
 	blockNumKey := blockNumDbPrefix(genesisBlock.Number)
 	return bc.db.Update(func(txn *badger.Txn) error {
+		if err := putDatabaseVersion(txn, DatabaseVersion); err != nil {
+			bc.logger.Errorf("Unable to save database version: %s", err)
+			return err
+		}
+
 		if err := txn.Set(genesisKey, genSerialized); err != nil {
 			bc.logger.Errorf("Unable to save genesis value: %s", err)
 			return err
@@ -43,7 +78,7 @@ func (bc *BlockChain) NewGenesisBlockWithRewrite(ctx context.Context) error {
 			return err
 		}
 
-		if err := txn.Set(blockNumKey, blockKey); err != nil {
+		if err := txn.Set(blockNumKey, genesisBlock.BlockHeader.BlockHash.Bytes()); err != nil {
 			bc.logger.Errorf("Unable to save genesis block by number: %s", err)
 			return err
 		}
@@ -53,32 +88,47 @@ func (bc *BlockChain) NewGenesisBlockWithRewrite(ctx context.Context) error {
 			return err
 		}
 
+		if err := txn.Set(blockHeaderDbPrefix(genesisBlock.BlockHeader.BlockHash), serializedHeader); err != nil {
+			bc.logger.Errorf("Unable to put genesis block header: %s", err)
+			return err
+		}
+
 		if err := txn.Set(lastHashKey, genesisBlock.BlockHeader.BlockHash.Bytes()); err != nil {
 			bc.logger.Errorf("Unable to put genesis block hash: %s", err)
 			return err
 		}
 
-		for addr := range gen.Alloc {
+		state, err := newBlockState(txn, common.Hash{})
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range gen.sortedAlloc() {
 			alloc := gen.Alloc[addr]
 
-			bal := types.Balance{
+			bal := &types.Balance{
 				Address: addr,
 				Balance: alloc.Balance,
+				Stake:   alloc.Stake,
 				Nonce:   0,
 			}
 
-			balanceEncoded, err := bal.Serialize()
-			if err != nil {
-				return err
-			}
-
-			balanceKey := balanceDbPrefix(bal.Address)
-			if err := txn.Set(balanceKey, balanceEncoded); err != nil {
+			if err := state.putBalance(bal); err != nil {
 				bc.logger.Errorf("Unable to save balance: %s", err)
 				return err
 			}
 		}
 
+		root, err := state.Commit()
+		if err != nil {
+			bc.logger.Errorf("Unable to commit genesis state: %s", err)
+			return err
+		}
+
+		if root != genesisBlock.Root {
+			return fmt.Errorf("%w: %s; expected: %s", ErrInvalidStateRoot, root, genesisBlock.Root)
+		}
+
 		bc.genesis = gen
 		bc.LastHash = genesisBlock.BlockHeader.BlockHash
 		return nil
@@ -96,15 +146,19 @@ func (bc *BlockChain) loadGenesis(ctx context.Context) error {
 			return err
 		}
 
-		return gen.Value(func(val []byte) error {
-			return bc.genesis.Deserialize(val)
-		})
+		genesis := new(Genesis)
+		if err := gen.Value(genesis.Deserialize); err != nil {
+			bc.logger.Errorf("Unable to decode genesis: %s", err)
+			return err
+		}
+
+		bc.genesis = genesis
+		return nil
 	})
 }
 
 func (bc *BlockChain) GetGenesis(ctx context.Context) (*Genesis, error) {
 	if bc.genesis == nil {
-		bc.genesis = new(Genesis)
 		if err := bc.loadGenesis(ctx); err != nil {
 			return nil, err
 		}
@@ -112,6 +166,15 @@ func (bc *BlockChain) GetGenesis(ctx context.Context) (*Genesis, error) {
 	return bc.genesis, nil
 }
 
+// BlockTime returns the chain genesis minimal seconds between the parent block and the block of its election winner.
+// Every next fallback author waits for one more block time
+func (bc *BlockChain) BlockTime() int64 {
+	if bc.genesis == nil {
+		return 0
+	}
+	return bc.genesis.BlockTime
+}
+
 func (bc *BlockChain) GetGenesisBlock(ctx context.Context) (*types.Block, error) {
 	if bc.tracer != nil {
 		span := bc.tracer.StartSpan("get_genesis_block", traceutil.ProvideParentSpan(ctx))
@@ -137,3 +200,17 @@ func (bc *BlockChain) GetGenesisBlock(ctx context.Context) (*types.Block, error)
 	}
 	return &block, nil
 }
+
+// ChainId returns chain id of loaded genesis, transactions are signed with.
+// Returns nil, if genesis is not loaded yet
+func (bc *BlockChain) ChainId() *big.Int {
+	if bc.genesis == nil {
+		return nil
+	}
+	return bc.genesis.ChainId
+}
+
+// Signer returns transactions signer of the chain
+func (bc *BlockChain) Signer() types.Signer {
+	return types.NewSigner(bc.ChainId())
+}
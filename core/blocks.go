@@ -1,66 +1,267 @@
 package core
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/opentracing/opentracing-go"
 	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/pkg/traceutil"
 )
 
-// ValidateNextBlock simply validates base block values // TBD made more efficient validation method
-func (bc *BlockChain) ValidateNextBlock(next *types.Block) error {
-	if bytes.Compare(next.PrevHash.Bytes(), bc.LastHash.Bytes()) != 0 {
-		return fmt.Errorf("invalid previous hash: %s", next.PrevHash)
+// ValidateNextBlock validates the block extending chain tip and re-executes it
+// to compare resulting state root // TBD made more efficient validation method
+func (bc *BlockChain) ValidateNextBlock(ctx context.Context, next *types.Block) error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if next.PrevHash != bc.LastHash {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidPrevHash, next.PrevHash, bc.LastHash)
 	}
-	if IsHashEmpty(next.Root) {
-		return fmt.Errorf("invalid root hash: %s", next.BlockHeader.BlockHash)
+
+	if err := bc.verifyHeader(&next.BlockHeader); err != nil {
+		return err
 	}
-	if IsHashEmpty(next.BlockHash) {
-		return fmt.Errorf("invalid block hash: %s", next.BlockHeader.BlockHash)
+
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		parent, err := getBlockHeader(txn, next.PrevHash)
+		if err != nil {
+			return err
+		}
+
+		recipients, err := bc.rewardRecipients(parent)
+		if err != nil {
+			return err
+		}
+
+		return validateBlock(bc.Signer(), bc.BlockTime(), parent, recipients, next)
+	}); err != nil {
+		return err
 	}
-	if next.Number != bc.ChainLength {
-		return fmt.Errorf("invalid block number: %d; expected: %d", next.Number, bc.ChainLength+1)
+
+	root, receipts, err := bc.ExecuteBlock(ctx, next)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	if root != next.Root {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidStateRoot, next.Root, root)
+	}
+
+	return validateReceiptHash(next, receipts)
+}
+
+// InsertBlock validates and applies the block. Block header and body, its number index,
+// chain tip, balance changes with state trie, receipts and transactions are written within
+// single database transaction, so either the whole block is applied, or nothing is written
+func (bc *BlockChain) InsertBlock(ctx context.Context, block *types.Block) error {
+	if bc.tracer != nil {
+		span := bc.tracer.StartSpan("insert_block", traceutil.ProvideParentSpan(ctx))
+		defer span.Finish()
+		ctx = opentracing.ContextWithSpan(ctx, span)
+	}
+
+	events, err := bc.insertBlock(ctx, block)
+	bc.postChainEvents(events)
+
+	return err
+}
+
+// insertBlock inserts the block under the chain lock and returns chain events,
+// which are sent once the lock is released
+func (bc *BlockChain) insertBlock(ctx context.Context, block *types.Block) ([]interface{}, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if block.PrevHash != bc.LastHash {
+		return bc.insertSideBlock(ctx, block)
+	}
+
+	if err := bc.verifyHeader(&block.BlockHeader); err != nil {
+		return nil, err
+	}
+
+	if err := bc.db.Update(func(txn *badger.Txn) error {
+		parent, err := getBlockHeader(txn, block.PrevHash)
+		if err != nil {
+			return err
+		}
+
+		recipients, err := bc.rewardRecipients(parent)
+		if err != nil {
+			return err
+		}
+
+		if err := validateBlock(bc.Signer(), bc.BlockTime(), parent, recipients, block); err != nil {
+			return err
+		}
+
+		return bc.applyCanonicalBlock(ctx, txn, block)
+	}); err != nil {
+		return nil, err
+	}
+
+	bc.LastHash = block.BlockHeader.BlockHash
+	bc.ChainLength = block.Number + 1
+
+	bc.logger.Infow("Saved block", "prev", block.PrevHash,
+		"hash", block.BlockHeader.BlockHash, "number", block.Number, "txs", len(block.Transactions))
+
+	bc.updateFinality(&block.BlockHeader)
+
+	return []interface{}{ChainHeadEvent{Block: block}}, nil
+}
+
+// applyCanonicalBlock executes the block on top of its parent state, compares resulting state root
+// and receipts hash, and writes the block as the new chain tip within provided database transaction
+func (bc *BlockChain) applyCanonicalBlock(ctx context.Context, txn *badger.Txn, block *types.Block) error {
+	root, receipts, err := bc.executeBlock(ctx, txn, block)
+	if err != nil {
+		bc.logger.Errorf("Unable to apply block: %s", err)
+		return err
+	}
+
+	if root != block.Root {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidStateRoot, block.Root, root)
+	}
+
+	if err := validateReceiptHash(block, receipts); err != nil {
+		return err
+	}
+
+	td, err := getTotalWeight(txn, block.PrevHash)
+	if err != nil {
+		return err
+	}
+
+	if err := bc.storeBlock(txn, block, td+blockWeight(&block.BlockHeader)); err != nil {
+		return err
+	}
+
+	return bc.setCanonical(txn, block)
 }
 
-// AddBlock adds a block with the provided transactions
-func (bc *BlockChain) AddBlock(block *types.Block) error {
-	if err := bc.ValidateNextBlock(block); err != nil {
+// validateReceiptHash compares block execution receipts hash with the block header ReceiptHash
+func validateReceiptHash(block *types.Block, receipts []*types.Receipt) error {
+	receiptHash, err := types.HashReceipts(receipts)
+	if err != nil {
 		return err
 	}
 
+	if receiptHash != block.ReceiptHash {
+		return fmt.Errorf("%w: %s; expected: %s", ErrInvalidReceiptHash, block.ReceiptHash, receiptHash)
+	}
+
+	return nil
+}
+
+// storeBlock writes block header, body and its chain total weight
+func (bc *BlockChain) storeBlock(txn *badger.Txn, block *types.Block, td uint64) error {
 	blockData, err := block.Serialize()
 	if err != nil {
 		return err
 	}
 
-	key := blockDbPrefix(block.BlockHeader.BlockHash)
-	numKey := blockNumDbPrefix(block.Number)
+	headerData, err := block.BlockHeader.Serialize()
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(blockDbPrefix(block.BlockHeader.BlockHash), blockData); err != nil {
+		bc.logger.Errorf("Unable to put block: %s", err)
+		return err
+	}
+
+	if err := txn.Set(blockHeaderDbPrefix(block.BlockHeader.BlockHash), headerData); err != nil {
+		bc.logger.Errorf("Unable to put block header: %s", err)
+		return err
+	}
+
+	if err := putTotalWeight(txn, block.BlockHeader.BlockHash, td); err != nil {
+		bc.logger.Errorf("Unable to put block total weight: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// setCanonical writes block number index and sets block as the chain tip
+func (bc *BlockChain) setCanonical(txn *badger.Txn, block *types.Block) error {
 	hashValue := block.BlockHeader.BlockHash.Bytes()
-	return bc.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(numKey, hashValue); err != nil {
-			return err
-		}
+	if err := txn.Set(blockNumDbPrefix(block.Number), hashValue); err != nil {
+		bc.logger.Errorf("Unable to put block number: %s", err)
+		return err
+	}
 
-		if err := txn.Set(key, blockData); err != nil {
-			return err
+	if err := txn.Set(lastHashKey, hashValue); err != nil {
+		bc.logger.Errorf("Unable to set last hash value: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetBlockHeader returns stored block header by its hash
+func (bc *BlockChain) GetBlockHeader(hash common.Hash) (*types.BlockHeader, error) {
+	var header *types.BlockHeader
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		var err error
+		header, err = getBlockHeader(txn, hash)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// getBlockHeader reads block header within provided database transaction
+func getBlockHeader(txn *badger.Txn, hash common.Hash) (*types.BlockHeader, error) {
+	var header types.BlockHeader
+
+	item, err := txn.Get(blockHeaderDbPrefix(hash))
+	if err != nil {
+		if err != badger.ErrKeyNotFound {
+			return nil, err
 		}
 
-		if err := txn.Set(lastHashKey, hashValue); err != nil {
-			bc.logger.Errorf("Unable to set last hash value: %s", err)
-			return err
+		// headers were not stored separately before, so look up for the whole block
+		block, err := getBlock(txn, hash)
+		if err != nil {
+			return nil, err
 		}
+		return &block.BlockHeader, nil
+	}
+
+	if err := item.Value(func(val []byte) error {
+		return header.Deserialize(val)
+	}); err != nil {
+		return nil, err
+	}
 
-		bc.LastHash = block.BlockHeader.BlockHash
-		bc.ChainLength = block.Number + 1
+	return &header, nil
+}
 
-		bc.logger.Infow("Saved block", "prev", block.PrevHash,
-			"hash", hashValue, "number", block.Number, "txs", len(block.Transactions))
+// getBlock reads block within provided database transaction
+func getBlock(txn *badger.Txn, hash common.Hash) (*types.Block, error) {
+	var block types.Block
 
-		return nil
-	})
+	item, err := txn.Get(blockDbPrefix(hash))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrBlockNotExists
+		}
+		return nil, err
+	}
+
+	if err := item.Value(func(val []byte) error {
+		return block.Deserialize(val)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &block, nil
 }
 
 func (bc *BlockChain) GetBlock(hash common.Hash) (types.Block, error) {
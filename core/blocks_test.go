@@ -0,0 +1,224 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"go.uber.org/zap"
+	"math/big"
+	"testing"
+)
+
+// newTestChain opens chain database with the development genesis, which allocates the provided balances
+func newTestChain(t *testing.T, alloc genesisAlloc) *BlockChain {
+	t.Helper()
+
+	bc, err := NewBlockChain(params.Options{DbFilePath: t.TempDir(), Logger: zap.NewNop().Sugar()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bc.Shutdown)
+
+	gen := DevNetGenesis()
+	gen.Alloc = alloc
+	if err := bc.writeGenesis(gen); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.LoadChainState(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return bc
+}
+
+// newTestTx returns transfer signed by the key account for the chain
+func newTestTx(t *testing.T, bc *BlockChain, key *ecdsa.PrivateKey, to common.Address, value *big.Int, nonce uint64) *types.SignedTx {
+	t.Helper()
+
+	tx, err := types.NewTransaction(crypto.PubkeyToAddress(key.PublicKey), to, value, nonce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx, err := bc.Signer().Sign(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signedTx
+}
+
+// newTestBlockTxs returns block on top of the chain tip with provided transactions and
+// the key account rewards. Its state and receipts are not computed, and it is not sealed
+func newTestBlockTxs(t *testing.T, bc *BlockChain, key *ecdsa.PrivateKey, txs ...*types.SignedTx) *types.Block {
+	t.Helper()
+
+	parent, err := bc.GetBlock(bc.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := types.NewBlock(types.BlockHeader{
+		PrevHash:   parent.BlockHash,
+		Number:     parent.Number + 1,
+		Timestamp:  parent.Timestamp + bc.BlockTime(),
+		Coinbase:   crypto.PubkeyToAddress(key.PublicKey),
+		NetherUsed: new(big.Int),
+	}, txs)
+	for _, tx := range txs {
+		block.NetherUsed.Add(block.NetherUsed, tx.Nether)
+	}
+
+	if err := AppendRewardTxs(block, nil, func(tx *types.Transaction) (*types.SignedTx, error) {
+		return bc.Signer().Sign(*tx, key)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	txHash, err := block.HashTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.TxHash = common.BytesToHash(txHash)
+	block.Bloom = types.CreateBloom(block.Transactions)
+
+	return block
+}
+
+// newTestBlock builds block on top of the chain tip with provided evidence and transactions,
+// authored and sealed by the key account
+func newTestBlock(t *testing.T, bc *BlockChain, key *ecdsa.PrivateKey, evidence []*types.Evidence, txs ...*types.SignedTx) *types.Block {
+	t.Helper()
+	ctx := context.Background()
+
+	block := newTestBlockTxs(t, bc, key, txs...)
+
+	var err error
+	block.Evidence = evidence
+	if block.EvidenceHash, err = block.HashEvidence(); err != nil {
+		t.Fatal(err)
+	}
+	if block.Punished, err = bc.Punished(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+
+	root, receipts, err := bc.ExecuteBlock(ctx, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Root = root
+	if block.ReceiptHash, err = types.HashReceipts(receipts); err != nil {
+		t.Fatal(err)
+	}
+
+	sealTestBlock(t, block, key)
+	return block
+}
+
+// sealTestBlock signs block seal hash with the key and updates its hash
+func sealTestBlock(t *testing.T, block *types.Block, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	sealHash, err := block.SealHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Signature, err = crypto.Sign(sealHash, key); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.BlockHash = common.BytesToHash(hash)
+}
+
+// readPrefixes returns all the stored values of keys starting with provided prefixes
+func readPrefixes(t *testing.T, bc *BlockChain, prefixes ...[]byte) map[string][]byte {
+	t.Helper()
+
+	values := make(map[string][]byte)
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for _, prefix := range prefixes {
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				value, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				values[string(it.Item().KeyCopy(nil))] = value
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return values
+}
+
+func TestInsertBlockAtomic(t *testing.T) {
+	ctx := context.Background()
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	recipient := common.HexToAddress("0x55")
+
+	bc := newTestChain(t, genesisAlloc{sender: {Balance: big.NewInt(1e9)}})
+	applied := newTestBlock(t, bc, authorKey, nil, newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 1))
+	if err := bc.InsertBlock(ctx, applied); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first transfer is valid, the second one exceeds the sender balance
+	block := newTestBlockTxs(t, bc, authorKey,
+		newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 2),
+		newTestTx(t, bc, senderKey, recipient, big.NewInt(1e12), 3),
+	)
+	block.Root = applied.Root
+	sealTestBlock(t, block, authorKey)
+
+	prefixes := [][]byte{lastHashKey, blocksPrefix, blockNumbersPrefix, balancesPrefix, stateTriePrefix,
+		stateUndoPrefix, receiptsPrefix, receiptListsPrefix, txsPrefix, txLookupPrefix, addressTxsPrefix}
+	before := readPrefixes(t, bc, prefixes...)
+
+	var txErr *BlockTxError
+	if err := bc.InsertBlock(ctx, block); !errors.As(err, &txErr) || txErr.Index != 1 {
+		t.Fatalf("expected tx #1 error, got %v", err)
+	}
+
+	if bc.LastHash != applied.BlockHash || bc.ChainLength != 2 {
+		t.Fatalf("expected chain tip %s of length 2, got %s of length %d", applied.BlockHash, bc.LastHash, bc.ChainLength)
+	}
+
+	after := readPrefixes(t, bc, prefixes...)
+	for key, value := range after {
+		if !bytes.Equal(before[key], value) {
+			t.Errorf("key %q is written by failed block", key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			t.Errorf("key %q is deleted by failed block", key)
+		}
+	}
+
+	txHash, err := block.Transactions[0].Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bc.FindTransaction(common.BytesToHash(txHash)); err == nil {
+		t.Fatalf("applied transaction of the failed block is stored")
+	}
+	if _, err := bc.GetReceipt(ctx, common.BytesToHash(txHash)); err == nil {
+		t.Fatalf("receipt of the failed block is stored")
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"encoding/binary"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/rovergulf/chain/core/types"
+)
+
+// FinalizedNumber returns the highest block number, which is never reverted by chain reorganization
+func (bc *BlockChain) FinalizedNumber() uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.finalized
+}
+
+// SafeNumber returns the highest block number confirmed by the majority of block authors.
+// It is not lower than the finalized block, but may move back on chain reorganization
+func (bc *BlockChain) SafeNumber() uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.safe
+}
+
+// updateFinality moves safe and finalized blocks by the consensus engine rules for the new chain head.
+// Finalized block never moves back, and state undo journals of the newly finalized blocks are pruned,
+// since they may not be reverted anymore. Chain tip lock must be held
+func (bc *BlockChain) updateFinality(head *types.BlockHeader) {
+	if bc.engine == nil {
+		return
+	}
+
+	safe, finalized, err := bc.engine.Finality(head)
+	if err != nil {
+		bc.logger.Errorw("Unable to get chain finality", "head", head.BlockHash, "err", err)
+		return
+	}
+
+	if finalized > bc.finalized {
+		if err := bc.db.Update(func(txn *badger.Txn) error {
+			for number := bc.finalized + 1; number <= finalized; number++ {
+				hash, err := getCanonicalHash(txn, number)
+				if err != nil {
+					return err
+				}
+
+				if err := txn.Delete(stateUndoDbPrefix(hash)); err != nil {
+					return err
+				}
+			}
+
+			return putFinalized(txn, finalized)
+		}); err != nil {
+			bc.logger.Errorw("Unable to store finalized block", "number", finalized, "err", err)
+			return
+		}
+
+		bc.finalized = finalized
+	}
+
+	bc.safe = safe
+	if bc.safe < bc.finalized {
+		bc.safe = bc.finalized
+	}
+}
+
+func putFinalized(txn *badger.Txn, number uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, number)
+	return txn.Set(finalizedKey, value)
+}
+
+// getFinalized returns stored finalized block number, or genesis if nothing has been finalized yet
+func getFinalized(txn *badger.Txn) (uint64, error) {
+	item, err := txn.Get(finalizedKey)
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
@@ -0,0 +1,392 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+)
+
+// ChainReorgEvent is sent to subscribers once canonical chain has been switched to heavier branch
+type ChainReorgEvent struct {
+	OldHead        common.Hash `json:"old_head" yaml:"old_head"`
+	NewHead        common.Hash `json:"new_head" yaml:"new_head"`
+	CommonAncestor common.Hash `json:"common_ancestor" yaml:"common_ancestor"`
+	Depth          uint64      `json:"depth" yaml:"depth"`
+
+	// DroppedTxs are transactions of dropped blocks, which are not included in the new branch
+	DroppedTxs []*types.SignedTx `json:"dropped_txs" yaml:"dropped_txs"`
+	// AppliedTxs are transactions of the new branch blocks
+	AppliedTxs []*types.SignedTx `json:"applied_txs" yaml:"applied_txs"`
+}
+
+// SubscribeChainReorg registers a subscription of chain reorganization events
+func (bc *BlockChain) SubscribeChainReorg(ch chan<- ChainReorgEvent) event.Subscription {
+	return bc.reorgFeed.Subscribe(ch)
+}
+
+// ChainHeadEvent is sent to subscribers once canonical chain head has been changed
+// by the new block or chain reorganization
+type ChainHeadEvent struct {
+	Block *types.Block `json:"block" yaml:"block"`
+}
+
+// SubscribeChainHead registers a subscription of chain head events
+func (bc *BlockChain) SubscribeChainHead(ch chan<- ChainHeadEvent) event.Subscription {
+	return bc.headFeed.Subscribe(ch)
+}
+
+// postChainEvents sends chain events to subscribers. Subscribers may read the chain on receive,
+// so events must be sent without holding the chain lock
+func (bc *BlockChain) postChainEvents(events []interface{}) {
+	for _, ev := range events {
+		switch ev := ev.(type) {
+		case ChainReorgEvent:
+			bc.reorgFeed.Send(ev)
+		case ChainHeadEvent:
+			bc.headFeed.Send(ev)
+		}
+	}
+}
+
+// blockWeight returns block weight used by the fork choice rule, which is the block
+// consensus difficulty. Blocks without difficulty have the same weight of 1
+func blockWeight(header *types.BlockHeader) uint64 {
+	if header.Difficulty == 0 {
+		return 1
+	}
+	return header.Difficulty
+}
+
+// GetTotalWeight returns chain total weight up to the block with provided hash
+func (bc *BlockChain) GetTotalWeight(hash common.Hash) (uint64, error) {
+	var td uint64
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		var err error
+		td, err = getTotalWeight(txn, hash)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	return td, nil
+}
+
+func putTotalWeight(txn *badger.Txn, hash common.Hash, td uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, td)
+	return txn.Set(totalWeightDbPrefix(hash), value)
+}
+
+// getTotalWeight returns chain total weight up to specified block
+func getTotalWeight(txn *badger.Txn, hash common.Hash) (uint64, error) {
+	item, err := txn.Get(totalWeightDbPrefix(hash))
+	if err != nil {
+		if err != badger.ErrKeyNotFound {
+			return 0, err
+		}
+
+		// blocks stored before fork choice has been introduced are canonical
+		header, err := getBlockHeader(txn, hash)
+		if err != nil {
+			return 0, err
+		}
+		return header.Number + 1, nil
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// getCanonicalHash returns canonical chain block hash by its number
+func getCanonicalHash(txn *badger.Txn, number uint64) (common.Hash, error) {
+	item, err := txn.Get(blockNumDbPrefix(number))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return common.Hash{}, ErrBlockNotExists
+		}
+		return common.Hash{}, err
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(value), nil
+}
+
+// insertSideBlock stores valid block that does not extend the chain tip
+// and switches canonical chain to its branch, if it becomes the heaviest one
+func (bc *BlockChain) insertSideBlock(ctx context.Context, block *types.Block) ([]interface{}, error) {
+	var reorg bool
+
+	// finalized canonical blocks may not be replaced by any other branch
+	if block.Number <= bc.finalized {
+		if _, err := bc.GetBlockHeader(block.BlockHash); err == nil {
+			return nil, ErrBlockAlreadyExists
+		}
+		return nil, fmt.Errorf("%w: block %d; finalized: %d", ErrFinalFork, block.Number, bc.finalized)
+	}
+
+	if err := bc.verifyHeader(&block.BlockHeader); err != nil {
+		return nil, err
+	}
+
+	if err := bc.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(blockDbPrefix(block.BlockHash)); err == nil {
+			return ErrBlockAlreadyExists
+		}
+
+		parent, err := getBlockHeader(txn, block.PrevHash)
+		if err != nil {
+			if err == ErrBlockNotExists {
+				return ErrUnknownParent
+			}
+			return err
+		}
+
+		recipients, err := bc.rewardRecipients(parent)
+		if err != nil {
+			return err
+		}
+
+		if err := validateBlock(bc.Signer(), bc.BlockTime(), parent, recipients, block); err != nil {
+			return err
+		}
+
+		parentTd, err := getTotalWeight(txn, block.PrevHash)
+		if err != nil {
+			return err
+		}
+
+		headTd, err := getTotalWeight(txn, bc.LastHash)
+		if err != nil {
+			return err
+		}
+
+		td := parentTd + blockWeight(&block.BlockHeader)
+		reorg = td > headTd
+
+		return bc.storeBlock(txn, block, td)
+	}); err != nil {
+		return nil, err
+	}
+
+	if !reorg {
+		bc.logger.Infow("Saved side block", "prev", block.PrevHash,
+			"hash", block.BlockHash, "number", block.Number)
+		return nil, nil
+	}
+
+	events, err := bc.reorg(ctx, block)
+	if err != nil {
+		// invalid branch head must not be chosen again
+		if err := bc.db.Update(func(txn *badger.Txn) error {
+			return deleteBlock(txn, block.BlockHash)
+		}); err != nil {
+			bc.logger.Errorf("Unable to remove invalid side block: %s", err)
+		}
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// deleteBlock removes stored non-canonical block
+func deleteBlock(txn *badger.Txn, hash common.Hash) error {
+	for _, key := range [][]byte{blockDbPrefix(hash), blockHeaderDbPrefix(hash), totalWeightDbPrefix(hash)} {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reorg rolls canonical chain back to the common ancestor with the new head branch
+// and applies new branch blocks. Everything is done within single database transaction,
+// so if any of the new branch blocks is invalid, canonical chain stays the same.
+// Returns reorg and new head events, which are sent once the chain lock is released
+func (bc *BlockChain) reorg(ctx context.Context, newHead *types.Block) ([]interface{}, error) {
+	ev := ChainReorgEvent{
+		OldHead: bc.LastHash,
+		NewHead: newHead.BlockHash,
+	}
+
+	if err := bc.db.Update(func(txn *badger.Txn) error {
+		oldHead, err := getBlockHeader(txn, bc.LastHash)
+		if err != nil {
+			return err
+		}
+
+		// collect new branch blocks down to the canonical chain
+		newBlocks := []*types.Block{newHead}
+		ancestor := newHead.PrevHash
+		for {
+			parent, err := getBlockHeader(txn, ancestor)
+			if err != nil {
+				return err
+			}
+
+			canonical, err := getCanonicalHash(txn, parent.Number)
+			if err != nil && err != ErrBlockNotExists {
+				return err
+			}
+			if canonical == ancestor {
+				ev.Depth = oldHead.Number - parent.Number
+				break
+			}
+
+			if newHead.Number-parent.Number > params.MaxReorgDepth {
+				return ErrReorgTooDeep
+			}
+
+			parentBlock, err := getBlock(txn, ancestor)
+			if err != nil {
+				return err
+			}
+			newBlocks = append([]*types.Block{parentBlock}, newBlocks...)
+			ancestor = parent.PrevHash
+		}
+		ev.CommonAncestor = ancestor
+
+		if ev.Depth > params.MaxReorgDepth {
+			return ErrReorgTooDeep
+		}
+
+		if oldHead.Number-ev.Depth < bc.finalized {
+			return fmt.Errorf("%w: ancestor %d; finalized: %d", ErrFinalFork, oldHead.Number-ev.Depth, bc.finalized)
+		}
+
+		// roll canonical blocks back, starting from the tip
+		appliedTxs := make(map[common.Hash]bool)
+		var droppedTxs []*types.SignedTx
+		for hash := oldHead.BlockHash; hash != ancestor; {
+			block, err := getBlock(txn, hash)
+			if err != nil {
+				return err
+			}
+
+			if err := bc.revertBlock(txn, block); err != nil {
+				bc.logger.Errorf("Unable to revert block: %s", err)
+				return err
+			}
+
+			for _, tx := range block.Transactions {
+				if !tx.IsReward() {
+					droppedTxs = append(droppedTxs, tx)
+				}
+			}
+
+			hash = block.PrevHash
+		}
+
+		for _, block := range newBlocks {
+			if err := bc.applyCanonicalBlock(ctx, txn, block); err != nil {
+				return err
+			}
+
+			for _, tx := range block.Transactions {
+				if tx.IsReward() {
+					continue
+				}
+
+				hash, err := tx.Hash()
+				if err != nil {
+					return err
+				}
+				appliedTxs[common.BytesToHash(hash)] = true
+				ev.AppliedTxs = append(ev.AppliedTxs, tx)
+			}
+		}
+
+		for _, tx := range droppedTxs {
+			hash, err := tx.Hash()
+			if err != nil {
+				return err
+			}
+			if !appliedTxs[common.BytesToHash(hash)] {
+				ev.DroppedTxs = append(ev.DroppedTxs, tx)
+			}
+		}
+
+		// old branch could be longer, than the new one
+		for number := newHead.Number + 1; number <= oldHead.Number; number++ {
+			if err := txn.Delete(blockNumDbPrefix(number)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		bc.logger.Errorw("Unable to reorganize chain", "new_head", newHead.BlockHash, "err", err)
+		return nil, err
+	}
+
+	bc.LastHash = newHead.BlockHash
+	bc.ChainLength = newHead.Number + 1
+
+	bc.logger.Warnw("Chain reorganized", "old_head", ev.OldHead, "new_head", ev.NewHead,
+		"ancestor", ev.CommonAncestor, "depth", ev.Depth, "dropped_txs", len(ev.DroppedTxs))
+
+	bc.updateFinality(&newHead.BlockHeader)
+
+	return []interface{}{ev, ChainHeadEvent{Block: newHead}}, nil
+}
+
+// revertBlock rolls block balances changes back and removes its transactions, receipts, evidence and indexes
+func (bc *BlockChain) revertBlock(txn *badger.Txn, block *types.Block) error {
+	if err := revertBalances(txn, block.BlockHash); err != nil {
+		return err
+	}
+
+	if err := txn.Delete(blockReceiptsDbPrefix(block.BlockHash)); err != nil {
+		return err
+	}
+
+	// evidence of the dropped block may be included by the new branch
+	for _, ev := range block.Evidence {
+		hash, err := ev.Hash()
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(evidenceDbPrefix(hash)); err != nil {
+			return err
+		}
+	}
+
+	for i, tx := range block.Transactions {
+		hashValue, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+		txHash := common.BytesToHash(hashValue)
+
+		keys := [][]byte{
+			txDbPrefix(txHash),
+			txLookupDbPrefix(txHash),
+			receiptDbPrefix(txHash),
+			append(addressTxsDbPrefix(tx.To), addressTxPosition(block.Number, i)...),
+		}
+		if !tx.IsReward() {
+			keys = append(keys, append(addressTxsDbPrefix(tx.From), addressTxPosition(block.Number, i)...))
+		}
+
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
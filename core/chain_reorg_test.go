@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"math/big"
+	"testing"
+)
+
+func TestChainReorg(t *testing.T) {
+	ctx := context.Background()
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	recipient := common.HexToAddress("0x55")
+	// balance does not fit uint64, so the undo journal must restore it as is
+	senderBalance := new(big.Int).Lsh(big.NewInt(1), 70)
+	alloc := genesisAlloc{sender: {Balance: senderBalance}}
+
+	tests := []struct {
+		name      string
+		mainLen   int
+		forkLen   int
+		finalized uint64
+		reorg     bool
+		err       error
+	}{
+		{name: "shorter fork is kept as side chain", mainLen: 2, forkLen: 1},
+		{name: "equal weight fork keeps the head", mainLen: 2, forkLen: 2},
+		{name: "heavier fork reorganizes the chain", mainLen: 2, forkLen: 3, reorg: true},
+		{name: "fork of the finalized block is rejected", mainLen: 2, forkLen: 3, finalized: 1, err: ErrFinalFork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mainKey, _ := crypto.GenerateKey()
+			forkKey, _ := crypto.GenerateKey()
+
+			bc := newTestChain(t, alloc)
+			transfer := newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 1)
+			var main []*types.Block
+			for i := 0; i < tt.mainLen; i++ {
+				var txs []*types.SignedTx
+				if i == 0 {
+					txs = append(txs, transfer)
+				}
+				block := newTestBlock(t, bc, mainKey, nil, txs...)
+				if err := bc.InsertBlock(ctx, block); err != nil {
+					t.Fatal(err)
+				}
+				main = append(main, block)
+			}
+			bc.finalized = tt.finalized
+
+			other := newTestChain(t, alloc)
+			var fork []*types.Block
+			for i := 0; i < tt.forkLen; i++ {
+				block := newTestBlock(t, other, forkKey, nil)
+				if err := other.InsertBlock(ctx, block); err != nil {
+					t.Fatal(err)
+				}
+				fork = append(fork, block)
+			}
+
+			reorgs := make(chan ChainReorgEvent, 1)
+			sub := bc.SubscribeChainReorg(reorgs)
+			defer sub.Unsubscribe()
+
+			for _, block := range fork {
+				if err := bc.InsertBlock(ctx, block); err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Fatalf("expected %v, got %v", tt.err, err)
+					}
+					break
+				}
+			}
+			if err := bc.InsertBlock(ctx, main[0]); !errors.Is(err, ErrBlockAlreadyExists) {
+				t.Fatalf("expected %s, got %v", ErrBlockAlreadyExists, err)
+			}
+
+			senderState, err := bc.GetBalance(sender)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, recipientErr := bc.GetBalance(recipient)
+
+			if !tt.reorg {
+				if bc.LastHash != main[len(main)-1].BlockHash || bc.ChainLength != uint64(tt.mainLen+1) {
+					t.Fatalf("expected main chain head, got %s of length %d", bc.LastHash, bc.ChainLength)
+				}
+				if len(reorgs) != 0 {
+					t.Fatalf("unexpected reorg event %+v", <-reorgs)
+				}
+				if senderState.Nonce != 1 || recipientErr != nil {
+					t.Fatalf("main chain transfer is reverted: %+v, %v", senderState, recipientErr)
+				}
+				return
+			}
+
+			if bc.LastHash != fork[len(fork)-1].BlockHash || bc.ChainLength != uint64(tt.forkLen+1) {
+				t.Fatalf("expected fork head, got %s of length %d", bc.LastHash, bc.ChainLength)
+			}
+
+			ev := <-reorgs
+			if ev.OldHead != main[len(main)-1].BlockHash || ev.NewHead != bc.LastHash || ev.Depth != uint64(tt.mainLen) {
+				t.Fatalf("unexpected reorg event %+v", ev)
+			}
+			var dropped bool
+			for _, tx := range ev.DroppedTxs {
+				dropped = dropped || (tx.From == sender && tx.Nonce == transfer.Nonce)
+			}
+			if !dropped {
+				t.Fatalf("transfer is not dropped by reorg")
+			}
+
+			// undone state of the main chain
+			if senderState.Balance.Cmp(senderBalance) != 0 || senderState.Nonce != 0 {
+				t.Fatalf("expected sender balance %s and nonce 0, got %s and %d",
+					senderBalance, senderState.Balance, senderState.Nonce)
+			}
+			if !errors.Is(recipientErr, ErrBalanceNotExists) {
+				t.Fatalf("expected recipient %s, got %v", ErrBalanceNotExists, recipientErr)
+			}
+			if _, err := bc.GetBalance(crypto.PubkeyToAddress(mainKey.PublicKey)); !errors.Is(err, ErrBalanceNotExists) {
+				t.Fatalf("expected main chain author %s, got %v", ErrBalanceNotExists, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,576 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/database/badgerdb"
+	"math/big"
+	"os"
+)
+
+// DatabaseVersion is the current chain database format version.
+//
+//	0 - values are encoded with gob, database has no version key
+//	1 - values are encoded with canonical RLP encoding, genesis is stored as JSON
+//	2 - transactions are bound to chain id, values are big integers, headers carry consensus seal,
+//	    evidence, punished accounts and addresses bloom, transactions and receipts hashes are Merkle roots
+const DatabaseVersion uint64 = 2
+
+const (
+	migrateDirSuffix = ".migrate" // migrated database is written next to the stored one
+	legacyDirSuffix  = ".legacy"  // stored database is moved away, until the migrated one replaces it
+)
+
+func putDatabaseVersion(txn *badger.Txn, version uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, version)
+	return txn.Set(databaseVersionKey, value)
+}
+
+// getDatabaseVersion returns stored database version.
+// Database with stored chain, but without version key, is written by legacy gob encoding
+func getDatabaseVersion(txn *badger.Txn) (uint64, error) {
+	item, err := txn.Get(databaseVersionKey)
+	if err == nil {
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(value), nil
+	}
+	if err != badger.ErrKeyNotFound {
+		return 0, err
+	}
+
+	if _, err := txn.Get(lastHashKey); err != nil {
+		if err == badger.ErrKeyNotFound {
+			// empty database
+			return DatabaseVersion, nil
+		}
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// GetDatabaseVersion returns chain database format version
+func (bc *BlockChain) GetDatabaseVersion() (uint64, error) {
+	var version uint64
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		v, err := getDatabaseVersion(txn)
+		version = v
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// checkDatabaseVersion returns ErrDatabaseVersion if database has to be migrated
+func checkDatabaseVersion(txn *badger.Txn) error {
+	version, err := getDatabaseVersion(txn)
+	if err != nil {
+		return err
+	}
+
+	if version != DatabaseVersion {
+		return fmt.Errorf("%w: %d; expected: %d. Run 'rbn blockchain migrate'", ErrDatabaseVersion, version, DatabaseVersion)
+	}
+
+	return nil
+}
+
+// Migrate re-encodes chain database written with previous format version.
+// Legacy canonical chain is replayed block by block into a new database next to the stored one,
+// which replaces it only once every block has been applied, so the stored chain is kept
+// if the migration fails or is interrupted. Side chain blocks are not migrated.
+// Block, transaction and receipt hashes are changed by the new encoding, transactions keep
+// their legacy signing version, so IsAuthentic verifies them against the legacy hashes
+func (bc *BlockChain) Migrate(ctx context.Context) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	version, err := bc.GetDatabaseVersion()
+	if err != nil {
+		return err
+	}
+
+	if version == DatabaseVersion {
+		bc.logger.Infow("Database is up to date", "version", version)
+		return nil
+	}
+	if version > DatabaseVersion {
+		return fmt.Errorf("%w: %d is not supported", ErrDatabaseVersion, version)
+	}
+
+	gen, hashes, err := bc.readLegacyChain(version)
+	if err != nil {
+		bc.logger.Errorf("Unable to read legacy chain: %s", err)
+		return err
+	}
+
+	// database left by the interrupted migration is replayed again
+	migrateDir := bc.dbPath + migrateDirSuffix
+	if err := os.RemoveAll(migrateDir); err != nil {
+		return err
+	}
+
+	db, err := badgerdb.OpenDB(migrateDir, badger.DefaultOptions(migrateDir))
+	if err != nil {
+		return err
+	}
+
+	replica := &BlockChain{db: db, logger: bc.logger, tracer: bc.tracer}
+	if err := replica.replayLegacyChain(ctx, bc, version, gen, hashes); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	if err := bc.swapDatabase(migrateDir); err != nil {
+		bc.logger.Errorf("Unable to replace legacy database: %s", err)
+		return err
+	}
+
+	bc.genesis = gen
+	bc.LastHash = replica.LastHash
+	bc.ChainLength = replica.ChainLength
+
+	bc.logger.Infow("Database migrated", "from", version, "to", DatabaseVersion,
+		"blocks", len(hashes), "tip", bc.LastHash)
+
+	return nil
+}
+
+// replayLegacyChain writes genesis and replays legacy canonical chain blocks read one by one
+func (bc *BlockChain) replayLegacyChain(ctx context.Context, legacy *BlockChain, version uint64, gen *Genesis, hashes []common.Hash) error {
+	if err := bc.writeGenesis(gen); err != nil {
+		return err
+	}
+	bc.ChainLength = 1
+
+	for _, hash := range hashes[1:] {
+		block, err := legacy.readLegacyBlock(hash, version, gen.ChainId)
+		if err != nil {
+			return err
+		}
+
+		if err := bc.replayLegacyBlock(ctx, block); err != nil {
+			bc.logger.Errorw("Unable to replay legacy block", "number", block.Number, "err", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// swapDatabase replaces the chain database directory with the migrated one and reopens it.
+// Swap interrupted after the legacy database is moved away is completed by recoverMigration
+func (bc *BlockChain) swapDatabase(migrateDir string) error {
+	if err := bc.db.Close(); err != nil {
+		return err
+	}
+
+	legacyDir := bc.dbPath + legacyDirSuffix
+	if err := os.Rename(bc.dbPath, legacyDir); err != nil {
+		if db, openErr := badgerdb.OpenDB(bc.dbPath, badger.DefaultOptions(bc.dbPath)); openErr == nil {
+			bc.db = db
+		}
+		return err
+	}
+	if err := os.Rename(migrateDir, bc.dbPath); err != nil {
+		return err
+	}
+
+	db, err := badgerdb.OpenDB(bc.dbPath, badger.DefaultOptions(bc.dbPath))
+	if err != nil {
+		return err
+	}
+	bc.db = db
+
+	return os.RemoveAll(legacyDir)
+}
+
+// recoverMigration completes the database swap interrupted by the node crash. Migrated database
+// is moved only once it is complete, so it replaces the missing one, and legacy one is removed
+func recoverMigration(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return os.RemoveAll(dir + legacyDirSuffix)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if _, err := os.Stat(dir + migrateDirSuffix); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err := os.Rename(dir+migrateDirSuffix, dir); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir + legacyDirSuffix)
+}
+
+// legacy gob layouts of version 0 database values
+type (
+	legacyTransaction struct {
+		From        common.Address
+		To          common.Address
+		Nonce       uint64
+		Value       uint64
+		Nether      uint64
+		NetherPrice uint64
+		Data        []byte
+		Time        int64
+	}
+
+	legacySignedTx struct {
+		Transaction legacyTransaction
+		Sig         []byte
+	}
+
+	legacyBlockHeader struct {
+		Number     uint64
+		Timestamp  int64
+		NetherUsed uint64
+		Coinbase   common.Address
+		PrevHash   common.Hash
+	}
+
+	legacyBlock struct {
+		BlockHeader  legacyBlockHeader
+		Transactions []*legacySignedTx
+	}
+
+	legacyGenesisAccount struct {
+		Balance uint64
+	}
+
+	legacyGenesis struct {
+		ChainId     *big.Int
+		GenesisTime int64
+		NetherPrice uint64
+		Nonce       uint64
+		Coinbase    common.Address
+		Symbol      string
+		Units       string
+		ParentHash  common.Hash
+		Alloc       map[common.Address]legacyGenesisAccount
+		ExtraData   []byte
+	}
+)
+
+func (g *legacyGenesis) toGenesis() *Genesis {
+	gen := &Genesis{
+		ChainId:     g.ChainId,
+		GenesisTime: g.GenesisTime,
+		NetherPrice: g.NetherPrice,
+		Nonce:       g.Nonce,
+		Coinbase:    g.Coinbase,
+		Symbol:      g.Symbol,
+		Units:       g.Units,
+		ParentHash:  g.ParentHash,
+		Alloc:       make(genesisAlloc),
+		ExtraData:   g.ExtraData,
+	}
+
+	for addr, account := range g.Alloc {
+		gen.Alloc[addr] = GenesisAccount{
+			Address: addr,
+			Balance: new(big.Int).SetUint64(account.Balance),
+		}
+	}
+
+	return gen
+}
+
+func (b *legacyBlock) toBlock() *types.Block {
+	var txs []*types.SignedTx
+	for _, tx := range b.Transactions {
+		txs = append(txs, &types.SignedTx{
+			Transaction: types.Transaction{
+				From:        tx.Transaction.From,
+				To:          tx.Transaction.To,
+				Nonce:       tx.Transaction.Nonce,
+				Value:       new(big.Int).SetUint64(tx.Transaction.Value),
+				Nether:      new(big.Int).SetUint64(tx.Transaction.Nether),
+				NetherPrice: tx.Transaction.NetherPrice,
+				Data:        tx.Transaction.Data,
+				Time:        tx.Transaction.Time,
+			},
+			Sig:     tx.Sig,
+			Signing: types.SigningGob,
+		})
+	}
+
+	return types.NewBlock(types.BlockHeader{
+		PrevHash:   b.BlockHeader.PrevHash,
+		Number:     b.BlockHeader.Number,
+		Timestamp:  b.BlockHeader.Timestamp,
+		NetherUsed: new(big.Int).SetUint64(b.BlockHeader.NetherUsed),
+		Coinbase:   b.BlockHeader.Coinbase,
+	}, txs)
+}
+
+// v1 RLP layouts of version 1 database values, transactions are not bound to chain
+// and header has no consensus values, which are optional in the current encoding
+type (
+	v1Transaction struct {
+		From        common.Address
+		To          common.Address
+		Nonce       uint64
+		Value       uint64
+		Nether      uint64
+		NetherPrice uint64
+		Data        []byte
+		Time        uint64
+	}
+
+	v1SignedTx struct {
+		Tx  *v1Transaction
+		Sig []byte
+	}
+
+	v1Block struct {
+		Header       *types.BlockHeader
+		Transactions []*v1SignedTx
+		TxHashes     []common.Hash
+		ReceivedAt   uint64
+	}
+)
+
+func (b *v1Block) toBlock() *types.Block {
+	var txs []*types.SignedTx
+	for _, tx := range b.Transactions {
+		txs = append(txs, &types.SignedTx{
+			Transaction: types.Transaction{
+				From:        tx.Tx.From,
+				To:          tx.Tx.To,
+				Nonce:       tx.Tx.Nonce,
+				Value:       new(big.Int).SetUint64(tx.Tx.Value),
+				Nether:      new(big.Int).SetUint64(tx.Tx.Nether),
+				NetherPrice: tx.Tx.NetherPrice,
+				Data:        tx.Tx.Data,
+				Time:        int64(tx.Tx.Time),
+			},
+			Sig:     tx.Sig,
+			Signing: types.SigningRLP,
+		})
+	}
+
+	return types.NewBlock(types.BlockHeader{
+		PrevHash:   b.Header.PrevHash,
+		Number:     b.Header.Number,
+		Timestamp:  b.Header.Timestamp,
+		NetherUsed: b.Header.NetherUsed,
+		Coinbase:   b.Header.Coinbase,
+	}, txs)
+}
+
+// readLegacyChain decodes genesis of the given database version
+// and returns canonical chain block hashes in ascending order
+func (bc *BlockChain) readLegacyChain(version uint64) (*Genesis, []common.Hash, error) {
+	var gen *Genesis
+	var hashes []common.Hash
+
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		g, err := getLegacyGenesis(txn, version)
+		if err != nil {
+			return err
+		}
+		gen = g
+
+		item, err := txn.Get(lastHashKey)
+		if err != nil {
+			return err
+		}
+		hashValue, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		for hash := common.BytesToHash(hashValue); ; {
+			legacy, err := getLegacyBlock(txn, hash, version)
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, hash)
+
+			if legacy.Number == 0 {
+				break
+			}
+			hash = legacy.PrevHash
+		}
+
+		for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+			hashes[i], hashes[j] = hashes[j], hashes[i]
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return gen, hashes, nil
+}
+
+// readLegacyBlock decodes block of the given database version. Legacy transactions are not bound to chain,
+// so they are bound to the genesis chain id, while their signatures are verified with the legacy signing version
+func (bc *BlockChain) readLegacyBlock(hash common.Hash, version uint64, chainId *big.Int) (*types.Block, error) {
+	var block *types.Block
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		legacy, err := getLegacyBlock(txn, hash, version)
+		if err != nil {
+			return err
+		}
+
+		block = legacy
+		for _, tx := range block.Transactions {
+			tx.ChainId = chainId
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// getLegacyGenesis decodes gob encoded version 0 genesis, or JSON encoded one of later versions
+func getLegacyGenesis(txn *badger.Txn, version uint64) (*Genesis, error) {
+	item, err := txn.Get(genesisKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gen := new(Genesis)
+	if err := item.Value(func(val []byte) error {
+		if version > 0 {
+			return gen.Deserialize(val)
+		}
+
+		var legacy legacyGenesis
+		if err := gob.NewDecoder(bytes.NewReader(val)).Decode(&legacy); err != nil {
+			return err
+		}
+		gen = legacy.toGenesis()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return gen, nil
+}
+
+// getLegacyBlock decodes gob encoded version 0 block, or RLP encoded version 1 one
+func getLegacyBlock(txn *badger.Txn, hash common.Hash, version uint64) (*types.Block, error) {
+	item, err := txn.Get(blockDbPrefix(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var block *types.Block
+	if err := item.Value(func(val []byte) error {
+		if version > 0 {
+			var legacy v1Block
+			if err := rlp.DecodeBytes(val, &legacy); err != nil {
+				return err
+			}
+			block = legacy.toBlock()
+			return nil
+		}
+
+		var legacy legacyBlock
+		if err := gob.NewDecoder(bytes.NewReader(val)).Decode(&legacy); err != nil {
+			return err
+		}
+		block = legacy.toBlock()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// replayLegacyBlock applies legacy block transactions on top of the chain tip
+// and writes it with recomputed transactions hash, state root and block hash
+func (bc *BlockChain) replayLegacyBlock(ctx context.Context, legacy *types.Block) error {
+	block := types.NewBlock(types.BlockHeader{
+		PrevHash:   bc.LastHash,
+		Number:     bc.ChainLength,
+		Timestamp:  legacy.Timestamp,
+		NetherUsed: legacy.NetherUsed,
+		Coinbase:   legacy.Coinbase,
+	}, legacy.Transactions)
+
+	txHash, err := block.HashTransactions()
+	if err != nil {
+		return err
+	}
+	block.TxHash = common.BytesToHash(txHash)
+	block.Bloom = types.CreateBloom(block.Transactions)
+
+	// state root and receipts hash are calculated first to get the block hash, undo journal and receipts are keyed with
+	txn := bc.db.NewTransaction(true)
+	root, receipts, err := bc.executeLegacyBlock(ctx, txn, block)
+	txn.Discard()
+	if err != nil {
+		return err
+	}
+	block.Root = root
+	if block.ReceiptHash, err = types.HashReceipts(receipts); err != nil {
+		return err
+	}
+
+	blockHash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	block.BlockHash = common.BytesToHash(blockHash)
+
+	if err := bc.db.Update(func(txn *badger.Txn) error {
+		if _, _, err := bc.executeLegacyBlock(ctx, txn, block); err != nil {
+			return err
+		}
+
+		td, err := getTotalWeight(txn, block.PrevHash)
+		if err != nil {
+			return err
+		}
+
+		if err := bc.storeBlock(txn, block, td+blockWeight(&block.BlockHeader)); err != nil {
+			return err
+		}
+
+		return bc.setCanonical(txn, block)
+	}); err != nil {
+		return err
+	}
+
+	bc.LastHash = block.BlockHash
+	bc.ChainLength = block.Number + 1
+	return nil
+}
+
+func (bc *BlockChain) executeLegacyBlock(ctx context.Context, txn *badger.Txn, block *types.Block) (common.Hash, []*types.Receipt, error) {
+	state, err := bc.parentState(txn, block)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	state.legacy = true
+
+	return bc.executeBlockState(ctx, txn, state, block)
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"go.uber.org/zap"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// legacyEntry is the key and value of legacy database fixture
+type legacyEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// openLegacyChain opens the chain database filled with the gob encoded fixture entries:
+// genesis and 3 blocks sealed by legacyCoinbase, the last one transfers 10 to legacyRecipient,
+// signed over the legacy transaction encoding of the fixture version.
+// testdata/legacy_chain.gob holds version 0 values, testdata/legacy_chain_v1.gob the same chain of version 1
+func openLegacyChain(t *testing.T, dir, fixture string) *BlockChain {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []legacyEntry
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := NewBlockChain(params.Options{DbFilePath: dir, Logger: zap.NewNop().Sugar()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bc.Shutdown)
+
+	if err := bc.db.Update(func(txn *badger.Txn) error {
+		for _, entry := range entries {
+			if err := txn.Set(entry.Key, entry.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return bc
+}
+
+var (
+	legacyCoinbase  = common.HexToAddress("0xE22d4E7E11176393E83C47c425473589eC7D585c")
+	legacyRecipient = common.HexToAddress("0x55")
+)
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	tests := []struct {
+		fixture string
+		version uint64
+		signing types.TxSigning
+	}{
+		{fixture: "legacy_chain.gob", version: 0, signing: types.SigningGob},
+		{fixture: "legacy_chain_v1.gob", version: 1, signing: types.SigningRLP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			testMigrateLegacyDatabase(t, tt.fixture, tt.version, tt.signing)
+		})
+	}
+}
+
+func testMigrateLegacyDatabase(t *testing.T, fixture string, legacyVersion uint64, signing types.TxSigning) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "chain")
+	bc := openLegacyChain(t, dir, fixture)
+
+	if version, err := bc.GetDatabaseVersion(); err != nil || version != legacyVersion {
+		t.Fatalf("expected legacy version %d, got %d: %v", legacyVersion, version, err)
+	}
+	if err := bc.LoadChainState(ctx); !errors.Is(err, ErrDatabaseVersion) {
+		t.Fatalf("expected %s, got %v", ErrDatabaseVersion, err)
+	}
+
+	if err := bc.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.LoadChainState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := bc.GetDatabaseVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != DatabaseVersion {
+		t.Fatalf("expected version %d, got %d", DatabaseVersion, version)
+	}
+	if bc.ChainLength != 4 {
+		t.Fatalf("expected 4 blocks, got %d", bc.ChainLength)
+	}
+
+	for _, suffix := range []string{migrateDirSuffix, legacyDirSuffix} {
+		if _, err := os.Stat(dir + suffix); !os.IsNotExist(err) {
+			t.Fatalf("%s directory is left: %v", suffix, err)
+		}
+	}
+
+	tests := []struct {
+		addr    common.Address
+		balance *big.Int
+		nonce   uint64
+	}{
+		{addr: legacyCoinbase, balance: big.NewInt(143990), nonce: 1},
+		{addr: legacyRecipient, balance: big.NewInt(10), nonce: 0},
+	}
+	for _, tt := range tests {
+		balance, err := bc.GetBalance(tt.addr)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.addr, err)
+		}
+		if balance.Balance.Cmp(tt.balance) != 0 || balance.Nonce != tt.nonce {
+			t.Errorf("%s: expected balance %s and nonce %d, got %s and %d",
+				tt.addr, tt.balance, tt.nonce, balance.Balance, balance.Nonce)
+		}
+	}
+
+	// migrated chain is extended by new blocks
+	head, err := bc.GetBlock(bc.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.ReceiptHash == (common.Hash{}) || head.Root == (common.Hash{}) {
+		t.Fatalf("migrated head has no state and receipts commitments")
+	}
+
+	// legacy transfer keeps its legacy signature verifiable
+	var transfer *types.SignedTx
+	for _, tx := range head.Transactions {
+		if !tx.IsReward() {
+			transfer = tx
+		}
+	}
+	if transfer == nil || transfer.Signing != signing {
+		t.Fatalf("expected legacy transfer with signing version %d, got %v", signing, transfer)
+	}
+	if ok, err := transfer.IsAuthentic(bc.Signer()); err != nil || !ok {
+		t.Fatalf("migrated transfer is not authentic: %v", err)
+	}
+
+	forged := *transfer
+	forged.Value = big.NewInt(11)
+	if ok, err := forged.IsAuthentic(bc.Signer()); err != nil || ok {
+		t.Fatalf("forged legacy transfer is authentic: %v", err)
+	}
+
+	other := *transfer
+	other.ChainId = new(big.Int).Add(transfer.ChainId, big.NewInt(1))
+	if _, err := other.IsAuthentic(bc.Signer()); !errors.Is(err, types.ErrInvalidChainId) {
+		t.Fatalf("expected %s, got %v", types.ErrInvalidChainId, err)
+	}
+}
+
+func TestRecoverMigration(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirs     []string // directories left by the interrupted migration
+		migrated bool     // whether the chain directory is the migrated one afterwards
+	}{
+		{name: "no migration", dirs: []string{""}},
+		{name: "migration interrupted", dirs: []string{"", migrateDirSuffix}},
+		{name: "swap interrupted", dirs: []string{legacyDirSuffix, migrateDirSuffix}, migrated: true},
+		{name: "legacy removal interrupted", dirs: []string{"", legacyDirSuffix}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "chain")
+			for _, suffix := range tt.dirs {
+				if err := os.MkdirAll(filepath.Join(dir+suffix, suffix+"marker"), 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := recoverMigration(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := os.Stat(filepath.Join(dir, migrateDirSuffix+"marker"))
+			if migrated := err == nil; migrated != tt.migrated {
+				t.Fatalf("expected migrated %t, got %t", tt.migrated, migrated)
+			}
+			if _, err := os.Stat(dir + legacyDirSuffix); !os.IsNotExist(err) {
+				t.Fatalf("legacy directory is left: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
+	"sort"
+)
+
+// VerifyEvidence checks the evidence proves its offender misbehavior
+// and has not been included in the canonical chain yet
+func (bc *BlockChain) VerifyEvidence(ev *types.Evidence) error {
+	return bc.db.View(func(txn *badger.Txn) error {
+		hash, err := ev.Hash()
+		if err != nil {
+			return err
+		}
+
+		if included, err := evidenceIncluded(txn, hash); err != nil {
+			return err
+		} else if included {
+			return fmt.Errorf("%w: %s", ErrEvidenceIncluded, hash)
+		}
+
+		return bc.verifyEvidence(txn, ev)
+	})
+}
+
+// Punished returns accounts punished by the block evidence on top of its parent state,
+// which is the block header Punished value
+func (bc *BlockChain) Punished(ctx context.Context, block *types.Block) ([]common.Address, error) {
+	txn := bc.db.NewTransaction(true)
+	defer txn.Discard()
+
+	state, err := bc.parentState(txn, block)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.applyEvidence(txn, state, block)
+}
+
+// verifyEvidence checks the evidence proof depending on its kind. Only values signed by the offender
+// are accepted as a proof, so nobody is able to blame the account by changing its block or transaction
+func (bc *BlockChain) verifyEvidence(txn *badger.Txn, ev *types.Evidence) error {
+	if ev.Offender == (common.Address{}) {
+		return fmt.Errorf("%w: empty offender", ErrInvalidEvidence)
+	}
+
+	switch ev.Kind {
+	case types.EvidenceDoubleSign:
+		if len(ev.Headers) != 2 || len(ev.Blocks) != 0 || len(ev.Relays) != 0 {
+			return fmt.Errorf("%w: double sign proof is two block headers", ErrInvalidEvidence)
+		}
+		return verifyDoubleSign(ev.Offender, ev.Headers[0], ev.Headers[1])
+	case types.EvidenceInvalidBlock:
+		if len(ev.Headers) != 0 || len(ev.Blocks) != 1 || len(ev.Relays) != 0 {
+			return fmt.Errorf("%w: invalid block proof is the block", ErrInvalidEvidence)
+		}
+		return bc.verifyInvalidBlock(txn, ev.Offender, ev.Blocks[0])
+	case types.EvidenceForgedTx:
+		if len(ev.Headers) != 0 || len(ev.Blocks) != 0 || len(ev.Relays) != 1 {
+			return fmt.Errorf("%w: forged tx proof is the transaction relay", ErrInvalidEvidence)
+		}
+		return bc.verifyForgedTx(ev.Offender, ev.Relays[0])
+	default:
+		return fmt.Errorf("%w: %s", types.ErrUnknownEvidenceKind, ev.Kind)
+	}
+}
+
+// verifyDoubleSign checks both headers of the same number have different seal hashes and are sealed by the offender
+func verifyDoubleSign(offender common.Address, a, b *types.BlockHeader) error {
+	if a.Number != b.Number {
+		return fmt.Errorf("%w: headers numbers %d and %d differ", ErrInvalidEvidence, a.Number, b.Number)
+	}
+
+	// block hash value is not signed, so it must match the header values
+	for _, header := range []*types.BlockHeader{a, b} {
+		hash, err := header.Hash()
+		if err != nil {
+			return err
+		}
+		if common.BytesToHash(hash) != header.BlockHash {
+			return fmt.Errorf("%w: %s; expected: %s", ErrInvalidBlockHash, header.BlockHash, common.BytesToHash(hash))
+		}
+	}
+
+	// headers are ordered by their hashes, so the same offence has the only evidence
+	if bytes.Compare(a.BlockHash.Bytes(), b.BlockHash.Bytes()) >= 0 {
+		return fmt.Errorf("%w: headers are the same or not ordered", ErrInvalidEvidence)
+	}
+
+	// block hash covers the signature, so only different signed values are the offence
+	sealA, err := a.SealHash()
+	if err != nil {
+		return err
+	}
+	sealB, err := b.SealHash()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(sealA, sealB) {
+		return fmt.Errorf("%w: headers seal the same values", ErrInvalidEvidence)
+	}
+
+	for _, header := range []*types.BlockHeader{a, b} {
+		signer, err := consensus.Ecrecover(header)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidEvidence, err)
+		}
+		if signer != offender {
+			return fmt.Errorf("%w: header %d is sealed by %s", ErrInvalidEvidence, header.Number, signer)
+		}
+	}
+
+	return nil
+}
+
+// verifyInvalidBlock checks the block is sealed by the offender and breaks block validation rules on top
+// of its known parent. Block transactions and evidence must match their header hashes, as block body is not
+// signed. State root is not checked, because the parent state may be already gone
+func (bc *BlockChain) verifyInvalidBlock(txn *badger.Txn, offender common.Address, block *types.Block) error {
+	signer, err := consensus.Ecrecover(&block.BlockHeader)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEvidence, err)
+	}
+	if signer != offender {
+		return fmt.Errorf("%w: block is sealed by %s", ErrInvalidEvidence, signer)
+	}
+
+	// unsigned values would make different evidence of the same offence
+	if len(block.TxHashes) > 0 || block.ReceivedAt != 0 {
+		return fmt.Errorf("%w: block has local values", ErrInvalidEvidence)
+	}
+
+	txHash, err := block.HashTransactions()
+	if err != nil {
+		return err
+	}
+	evidenceHash, err := block.HashEvidence()
+	if err != nil {
+		return err
+	}
+	blockHash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if common.BytesToHash(txHash) != block.TxHash || evidenceHash != block.EvidenceHash ||
+		common.BytesToHash(blockHash) != block.BlockHash {
+		return fmt.Errorf("%w: block body does not match its header", ErrInvalidEvidence)
+	}
+
+	parent, err := getBlockHeader(txn, block.PrevHash)
+	if err != nil {
+		if err == ErrBlockNotExists {
+			return fmt.Errorf("%w: unknown block parent %s", ErrInvalidEvidence, block.PrevHash)
+		}
+		return err
+	}
+
+	recipients, err := bc.rewardRecipients(parent)
+	if err != nil {
+		return err
+	}
+
+	if err := validateBlockRules(bc.Signer(), bc.BlockTime(), parent, recipients, block); err == nil {
+		return fmt.Errorf("%w: block %d is valid", ErrInvalidEvidence, block.Number)
+	}
+
+	return nil
+}
+
+// verifyForgedTx checks the transaction is relayed by the offender and its signature does not match its sender.
+// Transaction of the other chain is not forged, it is just invalid there
+func (bc *BlockChain) verifyForgedTx(offender common.Address, relay *types.RelayedTx) error {
+	relayer, err := relay.Relayer()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEvidence, err)
+	}
+	if relayer != offender {
+		return fmt.Errorf("%w: tx is relayed by %s", ErrInvalidEvidence, relayer)
+	}
+
+	tx := relay.Tx
+	if tx.IsReward() {
+		return fmt.Errorf("%w: reward tx", ErrInvalidEvidence)
+	}
+
+	ok, err := tx.IsAuthentic(bc.Signer())
+	if errors.Is(err, types.ErrInvalidChainId) {
+		return fmt.Errorf("%w: %s", ErrInvalidEvidence, err)
+	}
+	if err == nil && ok {
+		return fmt.Errorf("%w: tx is authentic", ErrInvalidEvidence)
+	}
+
+	return nil
+}
+
+// applyEvidence verifies the block evidence and punishes offenders.
+// Returns punished accounts in ascending order
+func (bc *BlockChain) applyEvidence(txn *badger.Txn, state *blockState, block *types.Block) ([]common.Address, error) {
+	seen := make(map[common.Hash]bool)
+	punished := make(map[common.Address]bool)
+	for i, ev := range block.Evidence {
+		hash, err := ev.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		included, err := evidenceIncluded(txn, hash)
+		if err != nil {
+			return nil, err
+		}
+		if included || seen[hash] {
+			return nil, fmt.Errorf("%w: evidence #%d", ErrEvidenceIncluded, i)
+		}
+		seen[hash] = true
+
+		if err := bc.verifyEvidence(txn, ev); err != nil {
+			return nil, fmt.Errorf("%w: evidence #%d", err, i)
+		}
+
+		if err := slashStake(state, ev.Offender, block.Coinbase); err != nil {
+			return nil, err
+		}
+		punished[ev.Offender] = true
+
+		if err := txn.Set(evidenceDbPrefix(hash), block.BlockHash.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	offenders := make([]common.Address, 0, len(punished))
+	for offender := range punished {
+		offenders = append(offenders, offender)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		return bytes.Compare(offenders[i].Bytes(), offenders[j].Bytes()) < 0
+	})
+
+	return offenders, nil
+}
+
+// slashStake takes the offender stake, SlashBurnPercent of it is burnt and the rest is paid to the block author
+func slashStake(state *blockState, offender, author common.Address) error {
+	balance, err := state.getBalanceOrEmpty(offender)
+	if err != nil {
+		return err
+	}
+	if !balance.HasStake() {
+		return nil
+	}
+
+	stake := balance.Stake
+	balance.Stake = nil
+	if err := state.putBalance(balance); err != nil {
+		return err
+	}
+
+	burnt := new(big.Int).Mul(stake, new(big.Int).SetUint64(params.SlashBurnPercent))
+	burnt.Quo(burnt, big.NewInt(100))
+	reward := new(big.Int).Sub(stake, burnt)
+	if reward.Sign() == 0 {
+		return nil
+	}
+
+	// author balance is read after offender update, in case if it is the same account
+	authorBalance, err := state.getBalanceOrEmpty(author)
+	if err != nil {
+		return err
+	}
+	if err := addBalance(authorBalance, reward); err != nil {
+		return err
+	}
+
+	return state.putBalance(authorBalance)
+}
+
+// evidenceIncluded returns whether the evidence is included in the canonical chain
+func evidenceIncluded(txn *badger.Txn, hash common.Hash) (bool, error) {
+	if _, err := txn.Get(evidenceDbPrefix(hash)); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// equalAddresses returns whether both lists contain the same accounts in the same order
+func equalAddresses(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"math/big"
+	"testing"
+)
+
+// doubleSignEvidence returns double sign evidence of headers ordered by their hashes
+func doubleSignEvidence(offender common.Address, a, b *types.BlockHeader) *types.Evidence {
+	if bytes.Compare(a.BlockHash.Bytes(), b.BlockHash.Bytes()) > 0 {
+		a, b = b, a
+	}
+	return &types.Evidence{Kind: types.EvidenceDoubleSign, Offender: offender, Headers: []*types.BlockHeader{a, b}}
+}
+
+// malleateSeal returns header copy sealed by the same signature with high S value
+func malleateSeal(t *testing.T, header types.BlockHeader) *types.BlockHeader {
+	t.Helper()
+
+	sig := make([]byte, len(header.Signature))
+	copy(sig, header.Signature)
+	s := new(big.Int).Sub(crypto.S256().Params().N, new(big.Int).SetBytes(sig[32:64]))
+	s.FillBytes(sig[32:64])
+	sig[64] ^= 1
+	header.Signature = sig
+
+	hash, err := header.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.BlockHash = common.BytesToHash(hash)
+	return &header
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	ctx := context.Background()
+	offenderKey, _ := crypto.GenerateKey()
+	offender := crypto.PubkeyToAddress(offenderKey.PublicKey)
+	otherKey, _ := crypto.GenerateKey()
+	other := crypto.PubkeyToAddress(otherKey.PublicKey)
+
+	bc := newTestChain(t, genesisAlloc{offender: {Balance: big.NewInt(1e18)}})
+
+	// two blocks of the same number with different transactions
+	signed := newTestBlock(t, bc, offenderKey, nil)
+	conflicting := newTestBlock(t, bc, offenderKey, nil, newTestTx(t, bc, offenderKey, other, big.NewInt(1), 1))
+	if err := bc.InsertBlock(ctx, signed); err != nil {
+		t.Fatal(err)
+	}
+	doubleSign := doubleSignEvidence(offender, &signed.BlockHeader, &conflicting.BlockHeader)
+
+	invalid := newTestBlock(t, bc, offenderKey, nil)
+	invalid.NetherUsed = big.NewInt(7)
+	sealTestBlock(t, invalid, offenderKey)
+	valid := newTestBlock(t, bc, offenderKey, nil)
+	tampered := *valid
+	tampered.NetherUsed = big.NewInt(7)
+
+	// transaction of the other account sender, signed by the offender
+	forged := newTestTx(t, bc, offenderKey, common.HexToAddress("0x55"), big.NewInt(1), 1)
+	forged.From = other
+	forgedRelay, err := types.NewRelayedTx(forged, offenderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRelay, err := types.NewRelayedTx(forged, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authentic, err := types.NewRelayedTx(newTestTx(t, bc, offenderKey, other, big.NewInt(1), 2), offenderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ev   *types.Evidence
+		err  error
+	}{
+		{name: "double sign", ev: doubleSign},
+		{
+			name: "double sign of not ordered headers",
+			ev:   &types.Evidence{Kind: types.EvidenceDoubleSign, Offender: offender, Headers: []*types.BlockHeader{doubleSign.Headers[1], doubleSign.Headers[0]}},
+			err:  ErrInvalidEvidence,
+		},
+		{
+			name: "double sign of the same seal",
+			ev:   doubleSignEvidence(offender, &signed.BlockHeader, malleateSeal(t, signed.BlockHeader)),
+			err:  ErrInvalidEvidence,
+		},
+		{name: "double sign of the other account", ev: doubleSignEvidence(other, &signed.BlockHeader, &conflicting.BlockHeader), err: ErrInvalidEvidence},
+		{name: "invalid block", ev: &types.Evidence{Kind: types.EvidenceInvalidBlock, Offender: offender, Blocks: []*types.Block{invalid}}},
+		{name: "valid block", ev: &types.Evidence{Kind: types.EvidenceInvalidBlock, Offender: offender, Blocks: []*types.Block{valid}}, err: ErrInvalidEvidence},
+		{name: "block changed after seal", ev: &types.Evidence{Kind: types.EvidenceInvalidBlock, Offender: offender, Blocks: []*types.Block{&tampered}}, err: ErrInvalidEvidence},
+		{name: "forged tx", ev: &types.Evidence{Kind: types.EvidenceForgedTx, Offender: offender, Relays: []*types.RelayedTx{forgedRelay}}},
+		{name: "forged tx relayed by the other account", ev: &types.Evidence{Kind: types.EvidenceForgedTx, Offender: offender, Relays: []*types.RelayedTx{otherRelay}}, err: ErrInvalidEvidence},
+		{name: "authentic tx", ev: &types.Evidence{Kind: types.EvidenceForgedTx, Offender: offender, Relays: []*types.RelayedTx{authentic}}, err: ErrInvalidEvidence},
+		{name: "empty offender", ev: &types.Evidence{Kind: types.EvidenceForgedTx, Relays: []*types.RelayedTx{forgedRelay}}, err: ErrInvalidEvidence},
+		{name: "unknown kind", ev: &types.Evidence{Kind: 42, Offender: offender}, err: types.ErrUnknownEvidenceKind},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := bc.VerifyEvidence(tt.ev); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestEvidenceSlashing(t *testing.T) {
+	ctx := context.Background()
+	offenderKey, _ := crypto.GenerateKey()
+	offender := crypto.PubkeyToAddress(offenderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	author := crypto.PubkeyToAddress(authorKey.PublicKey)
+
+	// stake does not fit uint64: the half of 2^65+1 is burnt and 2^64+1 is paid to the author
+	stake := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 65), big.NewInt(1))
+	reward := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1))
+
+	bc := newTestChain(t, genesisAlloc{offender: {Balance: big.NewInt(1e18), Stake: stake}})
+	signed := newTestBlock(t, bc, offenderKey, nil)
+	conflicting := newTestBlock(t, bc, offenderKey, nil, newTestTx(t, bc, offenderKey, author, big.NewInt(1), 1))
+	if err := bc.InsertBlock(ctx, signed); err != nil {
+		t.Fatal(err)
+	}
+	ev := doubleSignEvidence(offender, &signed.BlockHeader, &conflicting.BlockHeader)
+
+	// block author may not make up punished accounts
+	forged := newTestBlock(t, bc, authorKey, nil)
+	forged.Punished = []common.Address{offender}
+	sealTestBlock(t, forged, authorKey)
+	if err := bc.InsertBlock(ctx, forged); !errors.Is(err, ErrInvalidPunished) {
+		t.Fatalf("expected %s, got %v", ErrInvalidPunished, err)
+	}
+
+	block := newTestBlock(t, bc, authorKey, []*types.Evidence{ev})
+	if len(block.Punished) != 1 || block.Punished[0] != offender {
+		t.Fatalf("expected %s punished, got %v", offender, block.Punished)
+	}
+	if err := bc.InsertBlock(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr    common.Address
+		balance *big.Int
+	}{
+		{addr: offender, balance: new(big.Int).Add(big.NewInt(1e18), RewardAmount(new(big.Int), 1, true))},
+		{addr: author, balance: new(big.Int).Add(reward, RewardAmount(new(big.Int), 1, true))},
+	}
+	for _, tt := range tests {
+		balance, err := bc.GetBalance(tt.addr)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.addr, err)
+		}
+		if balance.Balance.Cmp(tt.balance) != 0 || balance.HasStake() {
+			t.Errorf("%s: expected balance %s without stake, got %s and stake %s",
+				tt.addr, tt.balance, balance.Balance, balance.Stake)
+		}
+	}
+
+	if err := bc.VerifyEvidence(ev); !errors.Is(err, ErrEvidenceIncluded) {
+		t.Fatalf("expected %s, got %v", ErrEvidenceIncluded, err)
+	}
+	included := newTestBlock(t, bc, authorKey, nil)
+	included.Evidence = []*types.Evidence{ev}
+	if _, err := bc.Punished(ctx, included); !errors.Is(err, ErrEvidenceIncluded) {
+		t.Fatalf("expected %s, got %v", ErrEvidenceIncluded, err)
+	}
+}
+
+func TestStakeOnlyFromGenesis(t *testing.T) {
+	ctx := context.Background()
+	stakerKey, _ := crypto.GenerateKey()
+	staker := crypto.PubkeyToAddress(stakerKey.PublicKey)
+	other := common.HexToAddress("0x55")
+
+	// there is no bond transaction, so genesis alloc is the only source of the stake
+	stake := big.NewInt(1e18)
+	bc := newTestChain(t, genesisAlloc{staker: {Balance: big.NewInt(1e18), Stake: stake}})
+
+	block := newTestBlock(t, bc, stakerKey, nil, newTestTx(t, bc, stakerKey, other, big.NewInt(1), 1))
+	if err := bc.InsertBlock(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+
+	// transfers and rewards neither spend nor bond the stake
+	tests := []struct {
+		addr  common.Address
+		stake *big.Int
+	}{
+		{addr: staker, stake: stake},
+		{addr: other},
+	}
+	for _, tt := range tests {
+		balance, err := bc.GetBalance(tt.addr)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.addr, err)
+		}
+		if tt.stake == nil && balance.HasStake() || tt.stake != nil && balance.Stake.Cmp(tt.stake) != 0 {
+			t.Errorf("%s: expected stake %v, got %v", tt.addr, tt.stake, balance.Stake)
+		}
+	}
+
+	// transfer exceeding the balance is rejected, even if the stake covers it
+	balance, err := bc.GetBalance(staker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overdraft := newTestBlockTxs(t, bc, stakerKey, newTestTx(t, bc, stakerKey, other, balance.Balance, 2))
+	if _, _, err := bc.ExecuteBlock(ctx, overdraft); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected %s, got %v", ErrInsufficientBalance, err)
+	}
+}
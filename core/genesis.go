@@ -2,26 +2,50 @@ package core
 
 import (
 	"bytes"
-	"encoding/gob"
+	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/rovergulf/chain/consensus"
 	"github.com/rovergulf/chain/core/types"
 	"github.com/rovergulf/chain/params"
 	"math/big"
+	"sort"
 )
 
 // Genesis represents BlockChain initialization state
 // and provides its root state for new nodes initialization
 type Genesis struct {
-	ChainId     *big.Int       `json:"chain_id" yaml:"chain_id"`
-	GenesisTime int64          `json:"genesis_time" yaml:"genesis_time"`
-	NetherPrice uint64         `json:"nether_limit" yaml:"nether_limit"`
-	Nonce       uint64         `json:"nonce" yaml:"nonce"`
-	Coinbase    common.Address `json:"coinbase" yaml:"coinbase"`
-	Symbol      string         `json:"symbol" yaml:"symbol"`
-	Units       string         `json:"units" yaml:"units"`
-	ParentHash  common.Hash    `json:"parent_hash" yaml:"parent_hash"`
-	Alloc       genesisAlloc   `json:"alloc" yaml:"alloc"`
-	ExtraData   []byte         `json:"extra_data,omitempty" yaml:"extra_data,omitempty"`
+	ChainId     *big.Int         `json:"chain_id" yaml:"chain_id"`
+	GenesisTime int64            `json:"genesis_time" yaml:"genesis_time"`
+	NetherPrice uint64           `json:"nether_limit" yaml:"nether_limit"`
+	Nonce       uint64           `json:"nonce" yaml:"nonce"`
+	Coinbase    common.Address   `json:"coinbase" yaml:"coinbase"`
+	Symbol      string           `json:"symbol" yaml:"symbol"`
+	Units       string           `json:"units" yaml:"units"`
+	ParentHash  common.Hash      `json:"parent_hash" yaml:"parent_hash"`
+	Alloc       genesisAlloc     `json:"alloc" yaml:"alloc"`
+	ExtraData   []byte           `json:"extra_data,omitempty" yaml:"extra_data,omitempty"`
+	Signers     []common.Address `json:"signers" yaml:"signers"`       // authorized block signers
+	Election    string           `json:"election" yaml:"election"`     // block author election, round robin if empty
+	BlockTime   int64            `json:"block_time" yaml:"block_time"` // minimal seconds between parent and its election winner block
+}
+
+// GenesisExtra is the genesis block header extra data, so the genesis hash commits to the consensus
+// settings and nodes of different signers or election are not able to join the same network
+type GenesisExtra struct {
+	Signers  []common.Address
+	Election string
+}
+
+// DecodeGenesisExtra decodes consensus settings of the genesis block header extra data
+func DecodeGenesisExtra(extra []byte) (*GenesisExtra, error) {
+	var genesisExtra GenesisExtra
+	if err := rlp.DecodeBytes(extra, &genesisExtra); err != nil {
+		return nil, err
+	}
+	return &genesisExtra, nil
 }
 
 // DevNetGenesis returns default Genesis for development and testing network
@@ -37,6 +61,9 @@ func DevNetGenesis() *Genesis {
 		ParentHash:  common.Hash{},
 		Alloc:       developerNetAlloc(),
 		ExtraData:   []byte{},
+		Signers:     treasurerSigners(),
+		Election:    consensus.ElectionRoundRobin,
+		BlockTime:   params.BlockTime,
 	}
 }
 
@@ -52,63 +79,126 @@ func DefaultMainNetGenesis() *Genesis {
 		Units:       "Nether", // is like it powered by atoms or quantum
 		Alloc:       defaultMainNetAlloc(),
 		ExtraData:   []byte{},
+		Signers:     treasurerSigners(),
+		Election:    consensus.ElectionRoundRobin,
+		BlockTime:   params.BlockTime,
 	}
 }
 
-// Serialize binary encodes genesis
-func (g Genesis) Serialize() ([]byte, error) {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-	if err := encoder.Encode(g); err != nil {
-		return nil, err
+// treasurerSigners returns network treasurer accounts, which are the default authorized block signers
+func treasurerSigners() []common.Address {
+	signers := make([]common.Address, 0, len(params.TreasurerAccounts))
+	for _, account := range params.TreasurerAccounts {
+		signers = append(signers, common.HexToAddress(account))
 	}
+	return signers
+}
 
-	return result.Bytes(), nil
+// Serialize encodes genesis to JSON, as allocation map is not supported by canonical encoding
+func (g Genesis) Serialize() ([]byte, error) {
+	return json.Marshal(g)
 }
 
-// Deserialize decodes binary value to genesis
+// Deserialize decodes JSON value to genesis
 func (g *Genesis) Deserialize(data []byte) error {
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	return decoder.Decode(g)
+	return json.Unmarshal(data, g)
+}
+
+// sortedAlloc returns allocation addresses in ascending order,
+// so genesis block transactions order is deterministic
+func (g *Genesis) sortedAlloc() []common.Address {
+	addresses := make([]common.Address, 0, len(g.Alloc))
+	for addr := range g.Alloc {
+		addresses = append(addresses, addr)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+
+	return addresses
 }
 
 func (g *Genesis) ToBlock() (*types.Block, error) {
 	var txs []*types.SignedTx
 
-	for addr := range g.Alloc {
+	for _, addr := range g.sortedAlloc() {
 		alloc := g.Alloc[addr]
 		tx, err := types.NewTransaction(g.Coinbase, addr, alloc.Balance, 0, g.ExtraData)
 		if err != nil {
 			return nil, err
 		}
 		tx.Time = g.GenesisTime
+		tx.ChainId = g.ChainId
 
 		txs = append(txs, &types.SignedTx{Transaction: tx})
 	}
 
+	root, err := g.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := rlp.EncodeToBytes(GenesisExtra{Signers: g.Signers, Election: g.Election})
+	if err != nil {
+		return nil, err
+	}
+
 	header := types.BlockHeader{
+		Root:      root,
 		PrevHash:  g.ParentHash,
 		Number:    g.Nonce,
 		Timestamp: g.GenesisTime,
 		Coinbase:  g.Coinbase,
+		Extra:     extra,
 	}
 
 	b := types.NewBlock(header, txs)
-	hash, err := b.Hash()
+	txHash, err := b.HashTransactions()
 	if err != nil {
 		return nil, err
 	}
-	b.BlockHeader.BlockHash = common.BytesToHash(hash)
+	b.TxHash = common.BytesToHash(txHash)
+	b.Bloom = types.CreateBloom(b.Transactions)
 
-	txHash, err := b.HashTransactions()
+	hash, err := b.Hash()
 	if err != nil {
 		return nil, err
 	}
-	b.TxHash = common.BytesToHash(txHash)
+	b.BlockHeader.BlockHash = common.BytesToHash(hash)
 
 	return b, nil
 }
 
+// StateRoot returns genesis allocation balances state trie root hash
+func (g *Genesis) StateRoot() (common.Hash, error) {
+	stateTrie, err := trie.NewSecure(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	for addr := range g.Alloc {
+		alloc := g.Alloc[addr]
+
+		balance := types.Balance{
+			Address: addr,
+			Balance: alloc.Balance,
+			Stake:   alloc.Stake,
+		}
+
+		data, err := balance.Serialize()
+		if err != nil {
+			return common.Hash{}, err
+		}
+
+		if err := stateTrie.TryUpdate(addr.Bytes(), data); err != nil {
+			return common.Hash{}, err
+		}
+	}
+
+	return stateTrie.Hash(), nil
+}
+
 func genesisByNetworkId(networkId *big.Int) *Genesis {
 	switch networkId.Int64() {
 	case params.OpenDevNetworkId:
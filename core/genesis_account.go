@@ -3,11 +3,13 @@ package core
 import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
+	"math/big"
 )
 
 type GenesisAccount struct {
 	Address common.Address `json:"address" yaml:"address"`
-	Balance uint64         `json:"balance" yaml:"balance"`
+	Balance *big.Int       `json:"balance" yaml:"balance"`
+	Stake   *big.Int       `json:"stake,omitempty" yaml:"stake,omitempty"` // bonded value of the block signer, which is only set by genesis
 	Auth    string         `json:"auth" yaml:"auth"`
-	keystore.Key
+	Key     *keystore.Key  `json:"-" yaml:"-"` // is not embedded, so its JSON methods are not promoted to the account
 }
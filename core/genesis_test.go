@@ -0,0 +1,47 @@
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"reflect"
+	"testing"
+)
+
+func TestGenesisExtra(t *testing.T) {
+	gen := DevNetGenesis()
+	block, err := gen.ToBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extra, err := DecodeGenesisExtra(block.Extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(extra.Signers, gen.Signers) || extra.Election != gen.Election {
+		t.Fatalf("expected signers %v and election %q, got %v and %q", gen.Signers, gen.Election, extra.Signers, extra.Election)
+	}
+
+	// genesis hash commits to the consensus settings
+	tests := []struct {
+		name   string
+		modify func(gen *Genesis)
+	}{
+		{name: "other signers", modify: func(gen *Genesis) { gen.Signers = []common.Address{common.HexToAddress("0x01")} }},
+		{name: "additional signer", modify: func(gen *Genesis) { gen.Signers = append(gen.Signers, common.HexToAddress("0x01")) }},
+		{name: "other election", modify: func(gen *Genesis) { gen.Election = consensus.ElectionRandom }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := DevNetGenesis()
+			tt.modify(other)
+			otherBlock, err := other.ToBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if otherBlock.BlockHash == block.BlockHash {
+				t.Fatalf("genesis hash %s does not depend on %s", block.BlockHash, tt.name)
+			}
+		})
+	}
+}
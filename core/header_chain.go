@@ -0,0 +1,269 @@
+package core
+
+import (
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+)
+
+// CurrentHeader returns the chain tip header. It is the header chain head, if synced headers
+// are heavier than the canonical blocks, or the canonical chain head block header otherwise
+func (bc *BlockChain) CurrentHeader() (*types.BlockHeader, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var header *types.BlockHeader
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		var err error
+		header, _, err = bc.currentHeader(txn)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// GetHeaderByNumber returns the chain tip branch header by its number, it may be stored without block body
+func (bc *BlockChain) GetHeaderByNumber(number uint64) (*types.BlockHeader, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	var header *types.BlockHeader
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		_, ahead, err := bc.currentHeader(txn)
+		if err != nil {
+			return err
+		}
+
+		var hash common.Hash
+		if ahead {
+			hash, err = getCanonicalHeaderHash(txn, number)
+		} else {
+			hash, err = getCanonicalHash(txn, number)
+		}
+		if err != nil {
+			return err
+		}
+
+		header, err = getBlockHeader(txn, hash)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// InsertHeaders verifies and stores linked headers without block bodies, the first header parent
+// must be known. Header chain head is switched to the heaviest stored header.
+// Returns the number of stored headers, which were not known before
+func (bc *BlockChain) InsertHeaders(headers []*types.BlockHeader) (int, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	var stored int
+	for i, header := range headers {
+		if i > 0 && header.PrevHash != headers[i-1].BlockHash {
+			return stored, fmt.Errorf("%w: header #%d is not linked", ErrInvalidPrevHash, i)
+		}
+
+		inserted, err := bc.insertHeader(header)
+		if err != nil {
+			return stored, fmt.Errorf("%w: header #%d", err, i)
+		}
+		if inserted {
+			stored++
+		}
+	}
+
+	if stored > 0 {
+		last := headers[len(headers)-1]
+		bc.logger.Infow("Saved headers", "count", stored, "hash", last.BlockHash, "number", last.Number)
+	}
+
+	return stored, nil
+}
+
+// insertHeader validates the header and stores it, returns false if it is known already.
+// Every header is written separately, as the consensus engine reads the header parents from the chain
+func (bc *BlockChain) insertHeader(header *types.BlockHeader) (bool, error) {
+	hash, err := header.Hash()
+	if err != nil {
+		return false, err
+	}
+	if common.BytesToHash(hash) != header.BlockHash {
+		return false, fmt.Errorf("%w: %s; expected: %s", ErrInvalidBlockHash, header.BlockHash, common.BytesToHash(hash))
+	}
+
+	var known bool
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		if _, err := getBlockHeader(txn, header.BlockHash); err == nil {
+			known = true
+			return nil
+		}
+
+		parent, err := getBlockHeader(txn, header.PrevHash)
+		if err != nil {
+			if err == ErrBlockNotExists {
+				return ErrUnknownParent
+			}
+			return err
+		}
+
+		return validateHeader(parent, header, bc.BlockTime())
+	}); err != nil || known {
+		return false, err
+	}
+
+	if err := bc.verifyHeader(header); err != nil {
+		return false, err
+	}
+
+	var switched bool
+	if err := bc.db.Update(func(txn *badger.Txn) error {
+		// finalized canonical headers may not be replaced by any other branch
+		if header.Number <= bc.finalized {
+			return fmt.Errorf("%w: header %d; finalized: %d", ErrFinalFork, header.Number, bc.finalized)
+		}
+
+		head, _, err := bc.currentHeader(txn)
+		if err != nil {
+			return err
+		}
+
+		parentTd, err := getTotalWeight(txn, header.PrevHash)
+		if err != nil {
+			return err
+		}
+		headTd, err := getTotalWeight(txn, head.BlockHash)
+		if err != nil {
+			return err
+		}
+
+		td := parentTd + blockWeight(header)
+		if err := storeHeader(txn, header, td); err != nil {
+			return err
+		}
+
+		if td <= headTd {
+			return nil
+		}
+		switched = true
+		return setHeadHeader(txn, header)
+	}); err != nil {
+		return false, err
+	}
+
+	if switched {
+		bc.headHeader = header.BlockHash
+	}
+
+	return true, nil
+}
+
+// currentHeader returns the chain tip header and whether it is the header chain head ahead of blocks
+func (bc *BlockChain) currentHeader(txn *badger.Txn) (*types.BlockHeader, bool, error) {
+	head, err := getBlockHeader(txn, bc.LastHash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if bc.headHeader == (common.Hash{}) || bc.headHeader == head.BlockHash {
+		return head, false, nil
+	}
+
+	headerHead, err := getBlockHeader(txn, bc.headHeader)
+	if err != nil {
+		return nil, false, err
+	}
+
+	headTd, err := getTotalWeight(txn, head.BlockHash)
+	if err != nil {
+		return nil, false, err
+	}
+	headerTd, err := getTotalWeight(txn, headerHead.BlockHash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// blocks are preferred, as they are the same branch once synced
+	if headerTd > headTd {
+		return headerHead, true, nil
+	}
+	return head, false, nil
+}
+
+// storeHeader writes header without block body and its chain total weight
+func storeHeader(txn *badger.Txn, header *types.BlockHeader, td uint64) error {
+	headerData, err := header.Serialize()
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(blockHeaderDbPrefix(header.BlockHash), headerData); err != nil {
+		return err
+	}
+
+	return putTotalWeight(txn, header.BlockHash, td)
+}
+
+// setHeadHeader sets the header as the header chain head and rewrites header number index
+// down to the first header, which is indexed already
+func setHeadHeader(txn *badger.Txn, header *types.BlockHeader) error {
+	for current := header; ; {
+		hash, err := getCanonicalHeaderHash(txn, current.Number)
+		if err != nil && err != ErrBlockNotExists {
+			return err
+		}
+		if err == nil && hash == current.BlockHash {
+			break
+		}
+
+		if err := txn.Set(headerNumDbPrefix(current.Number), current.BlockHash.Bytes()); err != nil {
+			return err
+		}
+		if current.Number == 0 {
+			break
+		}
+
+		current, err = getBlockHeader(txn, current.PrevHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	// previous header chain could be longer, than the new one
+	for number := header.Number + 1; ; number++ {
+		if _, err := txn.Get(headerNumDbPrefix(number)); err != nil {
+			if err == badger.ErrKeyNotFound {
+				break
+			}
+			return err
+		}
+		if err := txn.Delete(headerNumDbPrefix(number)); err != nil {
+			return err
+		}
+	}
+
+	return txn.Set(headHeaderKey, header.BlockHash.Bytes())
+}
+
+// getCanonicalHeaderHash returns header chain hash by its number
+func getCanonicalHeaderHash(txn *badger.Txn, number uint64) (common.Hash, error) {
+	item, err := txn.Get(headerNumDbPrefix(number))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return common.Hash{}, ErrBlockNotExists
+		}
+		return common.Hash{}, err
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(value), nil
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rovergulf/chain/core/types"
+)
+
+const accountSyncHeadLen = 8 + common.HashLength // block number and hash
+
+// GetBlockReceipts returns receipts of the stored block transactions in block order
+func (bc *BlockChain) GetBlockReceipts(hash common.Hash) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(blockReceiptsDbPrefix(hash))
+		if err == nil {
+			return item.Value(func(val []byte) error {
+				return rlp.DecodeBytes(val, &receipts)
+			})
+		}
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		// blocks applied before receipts were kept by the block hash are looked up by transactions
+		block, err := getBlock(txn, hash)
+		if err != nil {
+			return err
+		}
+
+		receipts = make([]*types.Receipt, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			txHash, err := tx.Hash()
+			if err != nil {
+				return err
+			}
+
+			item, err := txn.Get(receiptDbPrefix(common.BytesToHash(txHash)))
+			if err != nil {
+				if err == badger.ErrKeyNotFound {
+					return ErrReceiptNotExists
+				}
+				return err
+			}
+
+			var receipt types.Receipt
+			if err := item.Value(func(val []byte) error {
+				return receipt.Deserialize(val)
+			}); err != nil {
+				return err
+			}
+
+			// receipts are keyed by tx hash, so the same transaction of another block replaces it
+			if receipt.BlockHash != hash || receipt.TxIndex != i {
+				return fmt.Errorf("%w: tx #%d of block %s", ErrReceiptNotExists, i, hash)
+			}
+			receipts[i] = &receipt
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// InsertProvenTxs verifies transactions and receipts inclusion proof against the stored block header
+// and writes them with their lookup entries and addresses index, while the block body is not stored.
+// Account sync mode node keeps only its accounts transactions this way
+func (bc *BlockChain) InsertProvenTxs(proof *types.BlockProof, txs []*types.SignedTx, receipts []*types.Receipt) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	return bc.db.Update(func(txn *badger.Txn) error {
+		header, err := getBlockHeader(txn, proof.BlockHash)
+		if err != nil {
+			return err
+		}
+
+		if err := proof.Verify(header, txs, receipts); err != nil {
+			return err
+		}
+
+		for i, tx := range txs {
+			receipt := receipts[i]
+			if err := saveReceipt(txn, receipt); err != nil {
+				return err
+			}
+
+			if err := saveTx(txn, receipt.TxHash, tx, types.TxLookupEntry{
+				BlockHash:   header.BlockHash,
+				BlockNumber: header.Number,
+				TxIndex:     receipt.TxIndex,
+			}); err != nil {
+				return err
+			}
+
+			if err := indexAddressTx(txn, tx, receipt.TxHash, header.Number, receipt.TxIndex); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AccountSyncHead returns the last block number and hash, which transactions of the account are synced up to.
+// Zero values are returned, if the account is not synced yet
+func (bc *BlockChain) AccountSyncHead(addr common.Address) (uint64, common.Hash, error) {
+	var number uint64
+	var hash common.Hash
+
+	if err := bc.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(accountSyncDbPrefix(addr))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return nil
+			}
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			if len(val) != accountSyncHeadLen {
+				return fmt.Errorf("invalid account sync head length: %d", len(val))
+			}
+			number = binary.BigEndian.Uint64(val[:8])
+			hash = common.BytesToHash(val[8:])
+			return nil
+		})
+	}); err != nil {
+		return 0, common.Hash{}, err
+	}
+
+	return number, hash, nil
+}
+
+// SetAccountSyncHead sets the block, which transactions of the accounts are synced up to
+func (bc *BlockChain) SetAccountSyncHead(addrs []common.Address, header *types.BlockHeader) error {
+	value := make([]byte, accountSyncHeadLen)
+	binary.BigEndian.PutUint64(value[:8], header.Number)
+	copy(value[8:], header.BlockHash.Bytes())
+
+	return bc.db.Update(func(txn *badger.Txn) error {
+		for _, addr := range addrs {
+			if err := txn.Set(accountSyncDbPrefix(addr), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -4,19 +4,29 @@ import (
 	"context"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/rovergulf/chain/core/types"
 )
 
-func (bc *BlockChain) SaveReceipt(ctx context.Context, receipt *types.Receipt) error {
+// saveReceipt writes receipt within provided database transaction
+func saveReceipt(txn *badger.Txn, receipt *types.Receipt) error {
 	data, err := receipt.Serialize()
 	if err != nil {
 		return err
 	}
 
-	key := receiptDbPrefix(receipt.TxHash)
-	return bc.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, data)
-	})
+	return txn.Set(receiptDbPrefix(receipt.TxHash), data)
+}
+
+// saveBlockReceipts writes all of the block receipts in block order within provided database transaction.
+// Receipts are kept by the block hash as well, as equal reward transactions of different blocks share the hash
+func saveBlockReceipts(txn *badger.Txn, blockHash common.Hash, receipts []*types.Receipt) error {
+	data, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(blockReceiptsDbPrefix(blockHash), data)
 }
 
 func (bc *BlockChain) GetReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bytes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
+	"sort"
+)
+
+// RewardAmount returns treasurer reward transaction value for one of the block reward recipients.
+// Block transactions fees are separated between all the recipients, and block author receives
+// minimal nether limit on top of it
+func RewardAmount(fees *big.Int, recipients int, coinbase bool) *big.Int {
+	amount := new(big.Int)
+	if recipients == 0 {
+		return amount
+	}
+
+	if fees != nil {
+		peersAward := new(big.Int).Quo(fees, big.NewInt(int64(recipients)))
+		amount.Quo(peersAward, big.NewInt(int64(recipients)))
+	}
+	if coinbase {
+		amount.Add(amount, new(big.Int).SetUint64(params.NetherLimit))
+	}
+
+	return amount
+}
+
+// rewardTxs returns unsigned block reward transactions for the block coinbase and provided recipients,
+// ordered by recipient, so every node computes the same ones. Every account is rewarded once,
+// even if it is listed multiple times
+func rewardTxs(block *types.Block, recipients []common.Address) ([]types.Transaction, error) {
+	unique := map[common.Address]bool{block.Coinbase: true}
+	for _, account := range recipients {
+		unique[account] = true
+	}
+
+	accounts := make([]common.Address, 0, len(unique))
+	for account := range unique {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Bytes(), accounts[j].Bytes()) < 0
+	})
+
+	fees := new(big.Int)
+	for _, tx := range block.Transactions {
+		if !tx.IsReward() {
+			fees.Add(fees, tx.Fee())
+		}
+	}
+
+	txs := make([]types.Transaction, 0, len(accounts))
+	for _, account := range accounts {
+		amount := RewardAmount(fees, len(accounts), account == block.Coinbase)
+
+		tx, err := types.NewTransaction(common.HexToAddress(""), account, amount, 0, types.TxRewardData)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+// AppendRewardTxs appends block reward transactions for the block coinbase and provided recipients,
+// signed by signFn. Recipients are the consensus engine validators of the block parent, as the block
+// reward transactions must match the ones computed by every node
+func AppendRewardTxs(block *types.Block, recipients []common.Address, signFn consensus.SignerFn) error {
+	txs, err := rewardTxs(block, recipients)
+	if err != nil {
+		return err
+	}
+
+	for i := range txs {
+		signedTx, err := signFn(&txs[i])
+		if err != nil {
+			return err
+		}
+
+		block.Transactions = append(block.Transactions, signedTx)
+	}
+
+	return nil
+}
+
+// rewardRecipients returns the consensus engine validators, which share reward of the block
+// on top of provided parent with its author. Only the block author is rewarded if engine is not set
+func (bc *BlockChain) rewardRecipients(parent *types.BlockHeader) ([]common.Address, error) {
+	if bc.engine == nil {
+		return nil, nil
+	}
+
+	return bc.engine.RewardRecipients(parent)
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/rovergulf/chain/core/types"
+	"math/big"
+)
+
+// blockState represents balances state being changed within the block database transaction.
+// Balances are read from the state trie at the parent block root, so block may be executed
+// on top of any stored block. Every balance change is written to both the state trie,
+// which root hash is committed in BlockHeader.Root, and flat balances index of the chain tip
+type blockState struct {
+	txn    *badger.Txn
+	trie   *trie.SecureTrie
+	trieDb *trie.Database
+
+	undo    []balanceUndo
+	touched map[common.Address]bool
+
+	// legacy state skips nonce sequence checks for blocks applied before they were introduced
+	legacy bool
+}
+
+// balanceUndo keeps account balance value before the block has been applied,
+// so chain reorganization is able to roll flat balances back
+type balanceUndo struct {
+	Address common.Address
+	Existed bool
+	Value   []byte
+}
+
+// newBlockState opens balances state trie at specified root
+func newBlockState(txn *badger.Txn, root common.Hash) (*blockState, error) {
+	trieDb := trie.NewDatabase(&trieStore{txn: txn})
+	stateTrie, err := trie.NewSecure(root, trieDb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockState{
+		txn:     txn,
+		trie:    stateTrie,
+		trieDb:  trieDb,
+		touched: make(map[common.Address]bool),
+	}, nil
+}
+
+// getBalance reads address balance from the state trie
+func (s *blockState) getBalance(addr common.Address) (*types.Balance, error) {
+	data, err := s.trie.TryGet(addr.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrBalanceNotExists
+	}
+
+	var balance types.Balance
+	if err := balance.Deserialize(data); err != nil {
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+// getBalanceOrEmpty returns empty balance if address is not in the state trie
+func (s *blockState) getBalanceOrEmpty(addr common.Address) (*types.Balance, error) {
+	balance, err := s.getBalance(addr)
+	if err != nil {
+		if err == ErrBalanceNotExists {
+			return &types.Balance{Address: addr, Balance: new(big.Int)}, nil
+		}
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+func (s *blockState) putBalance(balance *types.Balance) error {
+	if err := s.journal(balance.Address); err != nil {
+		return err
+	}
+
+	data, err := balance.Serialize()
+	if err != nil {
+		return err
+	}
+
+	if err := s.trie.TryUpdate(balance.Address.Bytes(), data); err != nil {
+		return err
+	}
+
+	return s.txn.Set(balanceDbPrefix(balance.Address), data)
+}
+
+// journal remembers balance value before its first change in the block
+func (s *blockState) journal(addr common.Address) error {
+	if s.touched[addr] {
+		return nil
+	}
+
+	entry := balanceUndo{Address: addr}
+	item, err := s.txn.Get(balanceDbPrefix(addr))
+	if err != nil {
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+	} else {
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		entry.Existed = true
+		entry.Value = value
+	}
+
+	s.touched[addr] = true
+	s.undo = append(s.undo, entry)
+	return nil
+}
+
+// Root returns current state root without writing trie nodes
+func (s *blockState) Root() common.Hash {
+	return s.trie.Hash()
+}
+
+// Commit writes changed trie nodes to database transaction and returns state root
+func (s *blockState) Commit() (common.Hash, error) {
+	root, _, err := s.trie.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if err := s.trieDb.Commit(root, false, nil); err != nil {
+		return common.Hash{}, err
+	}
+
+	return root, nil
+}
+
+// writeUndo stores block balances undo journal
+func (s *blockState) writeUndo(blockHash common.Hash) error {
+	data, err := rlp.EncodeToBytes(s.undo)
+	if err != nil {
+		return err
+	}
+
+	return s.txn.Set(stateUndoDbPrefix(blockHash), data)
+}
+
+// revertBalances rolls flat balances back to the state before the block has been applied
+func revertBalances(txn *badger.Txn, blockHash common.Hash) error {
+	item, err := txn.Get(stateUndoDbPrefix(blockHash))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return ErrStateUndoNotExists
+		}
+		return err
+	}
+
+	var undo []balanceUndo
+	if err := item.Value(func(val []byte) error {
+		return rlp.DecodeBytes(val, &undo)
+	}); err != nil {
+		return err
+	}
+
+	for _, entry := range undo {
+		key := balanceDbPrefix(entry.Address)
+		if !entry.Existed {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := txn.Set(key, entry.Value); err != nil {
+			return err
+		}
+	}
+
+	return txn.Delete(stateUndoDbPrefix(blockHash))
+}
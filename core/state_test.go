@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+func TestExecuteBlockOnParentState(t *testing.T) {
+	ctx := context.Background()
+	senderKey, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(senderKey.PublicKey)
+	authorKey, _ := crypto.GenerateKey()
+	recipient := common.HexToAddress("0x55")
+	alloc := genesisAlloc{sender: {Balance: big.NewInt(1e9)}}
+
+	bc := newTestChain(t, alloc)
+	genesis := bc.LastHash
+	tipTx := newTestTx(t, bc, senderKey, recipient, big.NewInt(10), 1)
+	tip := newTestBlock(t, bc, authorKey, nil, tipTx)
+	if err := bc.InsertBlock(ctx, tip); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same sender nonce is spent by the side block on top of genesis
+	other := newTestChain(t, alloc)
+	sideTx := newTestTx(t, other, senderKey, recipient, big.NewInt(20), 1)
+	side := newTestBlock(t, other, authorKey, nil, sideTx)
+	if side.PrevHash != genesis {
+		t.Fatalf("side block parent %s is not genesis %s", side.PrevHash, genesis)
+	}
+	if err := other.InsertBlock(ctx, side); err != nil {
+		t.Fatal(err)
+	}
+
+	root, _, err := bc.ExecuteBlock(ctx, side)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != side.Root {
+		t.Fatalf("expected side block root %s, got %s", side.Root, root)
+	}
+
+	// executed side block does not change the chain tip balances
+	tipBalance := new(big.Int).Sub(big.NewInt(1e9), tipTx.Cost())
+	tests := []struct {
+		chain   *BlockChain
+		addr    common.Address
+		root    common.Hash
+		balance *big.Int
+	}{
+		{chain: bc, addr: sender, root: tip.Root, balance: tipBalance},
+		{chain: bc, addr: recipient, root: tip.Root, balance: big.NewInt(10)},
+		{chain: other, addr: sender, root: side.Root, balance: new(big.Int).Sub(big.NewInt(1e9), sideTx.Cost())},
+		{chain: other, addr: recipient, root: side.Root, balance: big.NewInt(20)},
+	}
+	for _, tt := range tests {
+		balance, err := tt.chain.GetBalanceAt(tt.addr, tt.root)
+		if err != nil {
+			t.Fatalf("%s at %s: %s", tt.addr, tt.root, err)
+		}
+		if balance.Balance.Cmp(tt.balance) != 0 {
+			t.Errorf("%s at %s: expected balance %s, got %s", tt.addr, tt.root, tt.balance, balance.Balance)
+		}
+	}
+	if balance, err := bc.GetBalance(sender); err != nil || balance.Balance.Cmp(tipBalance) != 0 {
+		t.Fatalf("expected tip sender balance %s, got %v, %v", tipBalance, balance, err)
+	}
+}
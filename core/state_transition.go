@@ -7,119 +7,203 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/rovergulf/chain/core/types"
 	"github.com/rovergulf/chain/params"
+	"math/big"
 )
 
-func (bc *BlockChain) ApplyTx(txHash common.Hash, tx *types.SignedTx) (*types.Receipt, error) {
-	fromAddr, err := bc.GetBalance(tx.From)
-	if err != nil {
-		bc.logger.Errorf("Unable to get sender balance: %s", err)
+// BlockTxError is returned when one of block transactions cannot be applied.
+// Block is rejected as a whole, so nothing of it is written to the database
+type BlockTxError struct {
+	Index  int
+	TxHash common.Hash
+	Err    error
+}
+
+func (e *BlockTxError) Error() string {
+	return fmt.Sprintf("unable to apply tx #%d (%s): %s", e.Index, e.TxHash.Hex(), e.Err)
+}
+
+func (e *BlockTxError) Unwrap() error {
+	return e.Err
+}
+
+// applyTx applies transaction value transfer to the block state
+func (bc *BlockChain) applyTx(state *blockState, txHash common.Hash, tx *types.SignedTx) (*types.Receipt, error) {
+	if err := validateTxValues(&tx.Transaction); err != nil {
 		return nil, err
 	}
 
-	toAddr, err := bc.GetBalance(tx.To)
+	fromAddr, err := state.getBalance(tx.From)
 	if err != nil {
-		bc.logger.Errorf("Unable to get recipient balance: %s", err)
+		bc.logger.Errorf("Unable to get sender balance: %s", err)
 		return nil, err
 	}
 
-	if tx.Cost() > fromAddr.Balance {
-		return nil, fmt.Errorf("wrong TX. Sender '%s' balance is %d TBB. Tx cost is %d TBB",
-			tx.From.String(), fromAddr.Balance, tx.Cost())
+	if !state.legacy && tx.Nonce != fromAddr.Nonce+1 {
+		return nil, fmt.Errorf("%w: %d; expected: %d", ErrInvalidTxNonce, tx.Nonce, fromAddr.Nonce+1)
 	}
 
-	fromAddr.Balance -= tx.Cost()
-	toAddr.Balance += tx.Value
+	// legacy chains charged nether fee regardless of its price
+	cost := tx.Cost()
+	if state.legacy {
+		cost = new(big.Int).Add(tx.Value, tx.Nether)
+	}
 
+	if err := subBalance(fromAddr, cost); err != nil {
+		return nil, fmt.Errorf("%w: sender '%s' balance is %s TBB. Tx cost is %s TBB",
+			err, tx.From.String(), fromAddr.Balance, cost)
+	}
 	fromAddr.Nonce = tx.Nonce
-	toAddr.Nonce++
+	if err := state.putBalance(fromAddr); err != nil {
+		return nil, err
+	}
 
-	from, err := fromAddr.Serialize()
+	// recipient balance is read after sender update, in case if it is the same account
+	toAddr, err := state.getBalanceOrEmpty(tx.To)
 	if err != nil {
+		bc.logger.Errorf("Unable to get recipient balance: %s", err)
 		return nil, err
 	}
 
-	to, err := toAddr.Serialize()
-	if err != nil {
+	if err := addBalance(toAddr, tx.Value); err != nil {
+		return nil, err
+	}
+	if err := state.putBalance(toAddr); err != nil {
 		return nil, err
 	}
 
-	receipt := &types.Receipt{
+	return &types.Receipt{
 		Addr:        fromAddr.Address,
 		Balance:     fromAddr.Balance,
 		NetherUsed:  tx.Nether,
 		NetherPrice: tx.NetherPrice,
 		TxHash:      txHash,
-	}
-
-	return receipt, bc.db.Update(func(txn *badger.Txn) error {
-		senderKey := append(balancesPrefix, fromAddr.Address.Bytes()...)
-		if err := txn.Set(senderKey, from); err != nil {
-			return err
-		}
-
-		recipientKey := append(balancesPrefix, toAddr.Address.Bytes()...)
-		return txn.Set(recipientKey, to)
-	})
+	}, nil
 }
 
-func (bc *BlockChain) applyRewardTx(ctx context.Context, tx *types.SignedTx) (*types.Receipt, error) {
+// applyRewardTx applies treasurer reward transaction to the block state
+func (bc *BlockChain) applyRewardTx(state *blockState, tx *types.SignedTx) (*types.Receipt, error) {
 	if !tx.IsReward() {
 		return nil, ErrInvalidRewardData
 	}
 
-	toAddr, err := bc.GetBalance(tx.To)
+	if err := validateTxValues(&tx.Transaction); err != nil {
+		return nil, err
+	}
+
+	toAddr, err := state.getBalanceOrEmpty(tx.To)
 	if err != nil {
 		bc.logger.Errorf("Unable to get recipient balance: %s", err)
 		return nil, err
 	}
 
-	toAddr.Balance += tx.Value
-	toAddr.Nonce++
-
-	to, err := toAddr.Serialize()
-	if err != nil {
+	if err := addBalance(toAddr, tx.Value); err != nil {
+		return nil, err
+	}
+	if err := state.putBalance(toAddr); err != nil {
 		return nil, err
 	}
 
-	receipt := &types.Receipt{
+	return &types.Receipt{
 		Addr:        tx.To,
 		Balance:     toAddr.Balance,
 		NetherUsed:  tx.Nether,
 		NetherPrice: tx.NetherPrice,
+	}, nil
+}
+
+// executeBlock applies the block on top of its parent state within provided database transaction
+// and returns resulting state root. Any tx failure returns BlockTxError
+func (bc *BlockChain) executeBlock(ctx context.Context, txn *badger.Txn, block *types.Block) (common.Hash, []*types.Receipt, error) {
+	state, err := bc.parentState(txn, block)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	return bc.executeBlockState(ctx, txn, state, block)
+}
+
+// parentState opens block parent balances state
+func (bc *BlockChain) parentState(txn *badger.Txn, block *types.Block) (*blockState, error) {
+	parent, err := getBlockHeader(txn, block.PrevHash)
+	if err != nil {
+		bc.logger.Errorf("Unable to get parent block header: %s", err)
+		return nil, err
+	}
+
+	state, err := newBlockState(txn, parent.Root)
+	if err != nil {
+		bc.logger.Errorf("Unable to open parent block state: %s", err)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// executeBlockState applies the block evidence and transactions to provided state,
+// commits it and writes balances undo journal
+func (bc *BlockChain) executeBlockState(ctx context.Context, txn *badger.Txn, state *blockState, block *types.Block) (common.Hash, []*types.Receipt, error) {
+	punished, err := bc.applyEvidence(txn, state, block)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	if !equalAddresses(punished, block.Punished) {
+		return common.Hash{}, nil, fmt.Errorf("%w: %v; expected: %v", ErrInvalidPunished, block.Punished, punished)
+	}
+
+	receipts, err := bc.applyBlock(ctx, txn, state, block)
+	if err != nil {
+		return common.Hash{}, nil, err
 	}
 
-	return receipt, bc.db.Update(func(txn *badger.Txn) error {
-		recipientKey := append(balancesPrefix, toAddr.Address.Bytes()...)
-		return txn.Set(recipientKey, to)
-	})
+	root, err := state.Commit()
+	if err != nil {
+		bc.logger.Errorf("Unable to commit block state: %s", err)
+		return common.Hash{}, nil, err
+	}
+
+	if err := state.writeUndo(block.BlockHash); err != nil {
+		bc.logger.Errorf("Unable to write block state undo journal: %s", err)
+		return common.Hash{}, nil, err
+	}
+
+	return root, receipts, nil
 }
 
-func (bc *BlockChain) ApplyBlock(ctx context.Context, block *types.Block) error {
+// ExecuteBlock applies the block on top of its parent state without writing any changes
+// and returns resulting state root, which is used as BlockHeader.Root value
+func (bc *BlockChain) ExecuteBlock(ctx context.Context, block *types.Block) (common.Hash, []*types.Receipt, error) {
+	txn := bc.db.NewTransaction(true)
+	defer txn.Discard()
 
-	pool := block.NetherUsed
-	bc.logger.Debugf("Nether pool available: ~%.5f", float64(pool/params.Raftel))
+	return bc.executeBlock(ctx, txn, block)
+}
+
+// applyBlock applies all the block transactions to the state and writes its receipts and transactions
+// within provided database transaction. Any tx failure returns BlockTxError
+func (bc *BlockChain) applyBlock(ctx context.Context, txn *badger.Txn, state *blockState, block *types.Block) ([]*types.Receipt, error) {
+	if block.NetherUsed != nil {
+		pool := new(big.Float).Quo(new(big.Float).SetInt(block.NetherUsed), big.NewFloat(params.Raftel))
+		bc.logger.Debugf("Nether pool available: ~%s", pool.Text('f', 5))
+	}
 
-	var txsHashes, receiptsHashes [][]byte
+	var receipts []*types.Receipt
 	for i := range block.Transactions {
 		tx := block.Transactions[i]
 
 		hashValue, err := tx.Hash()
 		if err != nil {
-			return err
+			return nil, &BlockTxError{Index: i, Err: err}
 		}
-
-		txsHashes = append(txsHashes, hashValue)
 		txHash := common.BytesToHash(hashValue)
 
 		var receipt *types.Receipt
 		if tx.IsReward() {
-			if receipt, err = bc.applyRewardTx(ctx, tx); err != nil {
-				return err
-			}
+			receipt, err = bc.applyRewardTx(state, tx)
 		} else {
-			if receipt, err = bc.ApplyTx(txHash, tx); err != nil {
-				return err
-			}
+			receipt, err = bc.applyTx(state, txHash, tx)
+		}
+		if err != nil {
+			return nil, &BlockTxError{Index: i, TxHash: txHash, Err: err}
 		}
 
 		receipt.BlockHash = block.BlockHeader.BlockHash
@@ -127,17 +211,28 @@ func (bc *BlockChain) ApplyBlock(ctx context.Context, block *types.Block) error
 		receipt.TxIndex = i
 		receipt.TxHash = txHash
 
-		if err := bc.SaveReceipt(ctx, receipt); err != nil {
-			return err
+		if err := saveReceipt(txn, receipt); err != nil {
+			return nil, err
 		}
 
-		rcptHash, err := receipt.Hash()
-		if err != nil {
-			return err
+		if err := saveTx(txn, txHash, tx, types.TxLookupEntry{
+			BlockHash:   block.BlockHeader.BlockHash,
+			BlockNumber: block.Number,
+			TxIndex:     i,
+		}); err != nil {
+			return nil, err
+		}
+
+		if err := indexAddressTx(txn, tx, txHash, block.Number, i); err != nil {
+			return nil, err
 		}
 
-		receiptsHashes = append(receiptsHashes, rcptHash)
+		receipts = append(receipts, receipt)
+	}
+
+	if err := saveBlockReceipts(txn, block.BlockHeader.BlockHash, receipts); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return receipts, nil
 }
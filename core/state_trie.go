@@ -0,0 +1,133 @@
+package core
+
+import (
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var stateTriePrefix = []byte("trie/")
+
+func stateTrieDbPrefix(key []byte) []byte {
+	return append(append([]byte{}, stateTriePrefix...), key...)
+}
+
+// trieStore adapts badger transaction to ethdb.KeyValueStore interface,
+// so state trie nodes are read and written within the block database transaction
+type trieStore struct {
+	txn *badger.Txn
+}
+
+func (s *trieStore) Has(key []byte) (bool, error) {
+	if _, err := s.txn.Get(stateTrieDbPrefix(key)); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *trieStore) Get(key []byte) ([]byte, error) {
+	item, err := s.txn.Get(stateTrieDbPrefix(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func (s *trieStore) Put(key []byte, value []byte) error {
+	return s.txn.Set(stateTrieDbPrefix(key), append([]byte{}, value...))
+}
+
+func (s *trieStore) Delete(key []byte) error {
+	return s.txn.Delete(stateTrieDbPrefix(key))
+}
+
+func (s *trieStore) NewBatch() ethdb.Batch {
+	return &trieBatch{store: s}
+}
+
+// NewIterator is not used by state trie, so it returns empty iterator
+func (s *trieStore) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return emptyIterator{}
+}
+
+func (s *trieStore) Stat(property string) (string, error) {
+	return "", nil
+}
+
+func (s *trieStore) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+func (s *trieStore) Close() error {
+	return nil
+}
+
+type trieBatchWrite struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// trieBatch collects trie nodes until it is written to trieStore
+type trieBatch struct {
+	store  *trieStore
+	writes []trieBatchWrite
+	size   int
+}
+
+func (b *trieBatch) Put(key []byte, value []byte) error {
+	b.writes = append(b.writes, trieBatchWrite{
+		key:   append([]byte{}, key...),
+		value: append([]byte{}, value...),
+	})
+	b.size += len(key) + len(value)
+	return nil
+}
+
+func (b *trieBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, trieBatchWrite{key: append([]byte{}, key...), delete: true})
+	b.size += len(key)
+	return nil
+}
+
+func (b *trieBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *trieBatch) Write() error {
+	return b.Replay(b.store)
+}
+
+func (b *trieBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+func (b *trieBatch) Replay(w ethdb.KeyValueWriter) error {
+	for _, write := range b.writes {
+		if write.delete {
+			if err := w.Delete(write.key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := w.Put(write.key, write.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type emptyIterator struct{}
+
+func (emptyIterator) Next() bool    { return false }
+func (emptyIterator) Error() error  { return nil }
+func (emptyIterator) Key() []byte   { return nil }
+func (emptyIterator) Value() []byte { return nil }
+func (emptyIterator) Release()      {}
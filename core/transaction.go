@@ -35,12 +35,19 @@ func (bc *BlockChain) ListTransactions() ([]types.SignedTx, error) {
 	return txs, nil
 }
 
-func (bc *BlockChain) FindTransaction(txHash common.Hash) (*types.SignedTx, error) {
-	var tx types.SignedTx
+// FindTransaction returns mined transaction with its location in the chain
+func (bc *BlockChain) FindTransaction(txHash common.Hash) (*types.SignedTx, *types.TxLookupEntry, error) {
+	var tx *types.SignedTx
+	var lookup types.TxLookupEntry
 
 	if err := bc.db.View(func(txn *badger.Txn) error {
-		key := txDbPrefix(txHash)
-		item, err := txn.Get(key)
+		foundTx, err := getTx(txn, txHash)
+		if err != nil {
+			return err
+		}
+		tx = foundTx
+
+		lookupItem, err := txn.Get(txLookupDbPrefix(txHash))
 		if err != nil {
 			if err == badger.ErrKeyNotFound {
 				return ErrTxNotExists
@@ -48,9 +55,30 @@ func (bc *BlockChain) FindTransaction(txHash common.Hash) (*types.SignedTx, erro
 			return err
 		}
 
-		return item.Value(func(val []byte) error {
-			return tx.Deserialize(val)
+		return lookupItem.Value(func(val []byte) error {
+			return lookup.Deserialize(val)
 		})
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return tx, &lookup, nil
+}
+
+// getTx reads signed transaction within provided database transaction
+func getTx(txn *badger.Txn, txHash common.Hash) (*types.SignedTx, error) {
+	var tx types.SignedTx
+
+	item, err := txn.Get(txDbPrefix(txHash))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrTxNotExists
+		}
+		return nil, err
+	}
+
+	if err := item.Value(func(val []byte) error {
+		return tx.Deserialize(val)
 	}); err != nil {
 		return nil, err
 	}
@@ -58,14 +86,21 @@ func (bc *BlockChain) FindTransaction(txHash common.Hash) (*types.SignedTx, erro
 	return &tx, nil
 }
 
-func (bc *BlockChain) SaveTx(txHash common.Hash, tx types.SignedTx) error {
+// saveTx writes signed transaction and its chain location within provided database transaction
+func saveTx(txn *badger.Txn, txHash common.Hash, tx *types.SignedTx, lookup types.TxLookupEntry) error {
 	encodedTx, err := tx.Serialize()
 	if err != nil {
 		return err
 	}
 
-	key := txDbPrefix(txHash)
-	return bc.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, encodedTx)
-	})
+	encodedLookup, err := lookup.Serialize()
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(txDbPrefix(txHash), encodedTx); err != nil {
+		return err
+	}
+
+	return txn.Set(txLookupDbPrefix(txHash), encodedLookup)
 }
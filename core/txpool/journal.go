@@ -0,0 +1,103 @@
+package txpool
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rovergulf/chain/core/types"
+	"io"
+	"os"
+)
+
+var (
+	ErrNoActiveJournal = errors.New("no active journal")
+)
+
+// journal is an append only file of locally submitted transactions
+// in canonical encoding, so they survive node restarts
+type journal struct {
+	path   string
+	writer io.WriteCloser
+}
+
+func newJournal(path string) *journal {
+	return &journal{path: path}
+}
+
+// load reads journal transactions. Journal may be truncated by unclean shutdown,
+// so transactions read before decoding failure are returned as well
+func (j *journal) load() ([]*types.SignedTx, error) {
+	file, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var txs []*types.SignedTx
+	stream := rlp.NewStream(file, 0)
+	for {
+		tx := new(types.SignedTx)
+		if err := stream.Decode(tx); err != nil {
+			if err == io.EOF {
+				return txs, nil
+			}
+			return txs, err
+		}
+		txs = append(txs, tx)
+	}
+}
+
+// insert appends transaction to the journal
+func (j *journal) insert(tx *types.SignedTx) error {
+	if j.writer == nil {
+		return ErrNoActiveJournal
+	}
+	return rlp.Encode(j.writer, tx)
+}
+
+// rotate replaces journal with provided transactions and opens it for appending
+func (j *journal) rotate(txs []*types.SignedTx) error {
+	if j.writer != nil {
+		if err := j.writer.Close(); err != nil {
+			return err
+		}
+		j.writer = nil
+	}
+
+	replacement, err := os.OpenFile(j.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err := rlp.Encode(replacement, tx); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	if err := replacement.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(j.path+".new", j.path); err != nil {
+		return err
+	}
+
+	sink, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.writer = sink
+	return nil
+}
+
+func (j *journal) close() error {
+	if j.writer == nil {
+		return nil
+	}
+
+	err := j.writer.Close()
+	j.writer = nil
+	return err
+}
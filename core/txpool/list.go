@@ -0,0 +1,65 @@
+package txpool
+
+import (
+	"github.com/rovergulf/chain/core/types"
+	"sort"
+)
+
+// txList is a single sender transactions list keyed by nonce
+type txList struct {
+	txs map[uint64]*types.SignedTx
+}
+
+func newTxList() *txList {
+	return &txList{txs: make(map[uint64]*types.SignedTx)}
+}
+
+func (l *txList) Len() int {
+	return len(l.txs)
+}
+
+func (l *txList) Get(nonce uint64) *types.SignedTx {
+	return l.txs[nonce]
+}
+
+func (l *txList) Put(tx *types.SignedTx) {
+	l.txs[tx.Nonce] = tx
+}
+
+func (l *txList) Remove(nonce uint64) {
+	delete(l.txs, nonce)
+}
+
+// Forward removes and returns transactions with nonce lower than provided one
+func (l *txList) Forward(nonce uint64) []*types.SignedTx {
+	var removed []*types.SignedTx
+	for n, tx := range l.txs {
+		if n < nonce {
+			removed = append(removed, tx)
+			delete(l.txs, n)
+		}
+	}
+	return removed
+}
+
+// Cap removes and returns transactions with nonce higher than provided one
+func (l *txList) Cap(nonce uint64) []*types.SignedTx {
+	var removed []*types.SignedTx
+	for n, tx := range l.txs {
+		if n > nonce {
+			removed = append(removed, tx)
+			delete(l.txs, n)
+		}
+	}
+	return removed
+}
+
+// Flatten returns transactions sorted by nonce
+func (l *txList) Flatten() []*types.SignedTx {
+	txs := make([]*types.SignedTx, 0, len(l.txs))
+	for _, tx := range l.txs {
+		txs = append(txs, tx)
+	}
+	sort.Sort(types.TxByNonce(txs))
+	return txs
+}
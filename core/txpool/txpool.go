@@ -0,0 +1,601 @@
+package txpool
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"go.uber.org/zap"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAlreadyKnown       = errors.New("transaction is already known")
+	ErrNonceTooLow        = errors.New("transaction nonce is too low")
+	ErrReplaceUnderpriced = errors.New("replacement transaction is underpriced")
+	ErrAccountLimit       = errors.New("account transactions limit exceeded")
+	ErrUnderpriced        = errors.New("transaction pool is full, transaction is underpriced")
+	ErrNonceTooHigh       = errors.New("transaction nonce is too far from the pending one")
+	ErrInsufficientFunds  = errors.New("insufficient funds for pending transactions cost")
+	ErrNegativeValue      = errors.New("transaction value is negative")
+	ErrZeroNether         = errors.New("transaction nether is zero")
+	ErrOversizedTx        = errors.New("transaction is oversized")
+)
+
+// Config is transaction pool limits configuration
+type Config struct {
+	GlobalSlots  int // maximum amount of pending and queued transactions in the pool
+	AccountSlots int // maximum amount of pending and queued transactions of a single account
+
+	PriceBump   uint64 // minimal nether price increase percentage to replace transaction with the same nonce
+	MaxNonceGap uint64 // maximum distance of queued transaction nonce from the pending one
+
+	Journal   string        // local transactions journal file path, journal is disabled if empty
+	Rejournal time.Duration // local transactions journal rotation interval
+}
+
+var DefaultConfig = Config{
+	GlobalSlots:  params.TxPoolGlobalSlots,
+	AccountSlots: params.TxPoolAccountSlots,
+	PriceBump:    params.TxPoolPriceBump,
+	MaxNonceGap:  params.TxPoolMaxNonceGap,
+	Rejournal:    time.Hour,
+}
+
+// ChainState provides canonical chain accounts state to the pool
+type ChainState interface {
+	// GetNextAccountNonce returns nonce of the next account transaction to be included in block
+	GetNextAccountNonce(addr common.Address) uint64
+	// GetBalanceOrEmpty returns account balance, or empty one if account has not been registered yet
+	GetBalanceOrEmpty(addr common.Address) (*types.Balance, error)
+}
+
+// TxPool keeps transactions, which are not included in canonical chain yet.
+// Pending transactions of every sender are contiguous by nonce, starting from the next
+// account nonce of the chain state, so they may be included in the next block.
+// Queued transactions have future nonces and are promoted once the nonce gap is filled
+type TxPool struct {
+	config Config
+	chain  ChainState
+
+	mu      sync.RWMutex
+	all     map[common.Hash]*types.SignedTx
+	pending map[common.Address]*txList
+	queue   map[common.Address]*txList
+
+	journal *journal
+	locals  map[common.Address]bool // accounts of transactions submitted to this node
+
+	logger *zap.SugaredLogger
+}
+
+// New creates transaction pool on top of provided chain state
+func New(config Config, chain ChainState, logger *zap.SugaredLogger) *TxPool {
+	p := &TxPool{
+		config:  config,
+		chain:   chain,
+		all:     make(map[common.Hash]*types.SignedTx),
+		pending: make(map[common.Address]*txList),
+		queue:   make(map[common.Address]*txList),
+		locals:  make(map[common.Address]bool),
+		logger:  logger,
+	}
+
+	if config.Journal != "" {
+		p.journal = newJournal(config.Journal)
+	}
+
+	return p
+}
+
+func txHash(tx *types.SignedTx) (common.Hash, error) {
+	hash, err := tx.Transaction.Hash()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(hash), nil
+}
+
+// Add puts transaction to the pending list, if it has the next sender nonce, or to the queue.
+// Transaction with the same sender nonce replaces the pool one, if its nether price is higher
+// by configured price bump, replaced transaction is returned.
+// If the pool is full, the lowest priced transaction is evicted to make a room for more expensive one
+func (p *TxPool) Add(tx *types.SignedTx) (*types.SignedTx, error) {
+	if err := validateTx(tx); err != nil {
+		return nil, err
+	}
+
+	hash, err := txHash(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.all[hash]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyKnown, hash)
+	}
+
+	if err := p.validateState(tx); err != nil {
+		return nil, err
+	}
+
+	for _, list := range []*txList{p.pending[tx.From], p.queue[tx.From]} {
+		if list == nil || list.Get(tx.Nonce) == nil {
+			continue
+		}
+
+		old := list.Get(tx.Nonce)
+		price, err := p.ReplacementPrice(old.NetherPrice)
+		if err != nil {
+			return nil, err
+		}
+		if tx.NetherPrice < price {
+			return nil, fmt.Errorf("%w: nether price %d; expected at least: %d", ErrReplaceUnderpriced, tx.NetherPrice, price)
+		}
+
+		oldHash, err := txHash(old)
+		if err != nil {
+			return nil, err
+		}
+		delete(p.all, oldHash)
+
+		p.all[hash] = tx
+		list.Put(tx)
+		p.logger.Debugw("Replaced pool tx", "old", oldHash, "new", hash, "from", tx.From, "nonce", tx.Nonce)
+		return old, nil
+	}
+
+	if p.accountLen(tx.From) >= p.config.AccountSlots {
+		return nil, fmt.Errorf("%w: %s", ErrAccountLimit, tx.From)
+	}
+
+	if len(p.all) >= p.config.GlobalSlots {
+		victim := p.lowestPriced()
+		if victim == nil || !types.TxByPriceAndTime([]*types.SignedTx{tx, victim}).Less(0, 1) {
+			return nil, ErrUnderpriced
+		}
+
+		victimHash, err := txHash(victim)
+		if err != nil {
+			return nil, err
+		}
+		p.removeTx(victimHash)
+		p.logger.Debugw("Evicted underpriced tx", "hash", victimHash, "from", victim.From, "nonce", victim.Nonce)
+	}
+
+	p.all[hash] = tx
+	if tx.Nonce == p.pendingNonce(tx.From) {
+		p.list(p.pending, tx.From).Put(tx)
+		p.promote(tx.From)
+	} else {
+		p.list(p.queue, tx.From).Put(tx)
+	}
+
+	return nil, nil
+}
+
+// validateTx checks transaction fields that do not depend on the chain state
+func validateTx(tx *types.SignedTx) error {
+	if tx.Value == nil || tx.Value.Sign() < 0 {
+		return fmt.Errorf("%w: %s", ErrNegativeValue, tx.Value)
+	}
+
+	if tx.Nether == nil || tx.Nether.Sign() <= 0 {
+		return ErrZeroNether
+	}
+
+	data, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+	if len(data) > params.TxMaxSize {
+		return fmt.Errorf("%w: %d bytes; limit: %d", ErrOversizedTx, len(data), params.TxMaxSize)
+	}
+
+	return nil
+}
+
+// validateState checks transaction against sender chain state and its pool transactions spends
+func (p *TxPool) validateState(tx *types.SignedTx) error {
+	account, err := p.chain.GetBalanceOrEmpty(tx.From)
+	if err != nil {
+		return err
+	}
+
+	if next := account.Nonce + 1; tx.Nonce < next {
+		return fmt.Errorf("%w: %d; expected at least: %d", ErrNonceTooLow, tx.Nonce, next)
+	}
+
+	if pending := p.pendingNonce(tx.From); tx.Nonce > pending+p.config.MaxNonceGap {
+		return fmt.Errorf("%w: %d; pending: %d", ErrNonceTooHigh, tx.Nonce, pending)
+	}
+
+	// transaction with the same nonce is going to be replaced, so its cost is not spent
+	spends := tx.Cost()
+	for _, list := range []*txList{p.pending[tx.From], p.queue[tx.From]} {
+		if list == nil {
+			continue
+		}
+		for nonce, poolTx := range list.txs {
+			if nonce != tx.Nonce {
+				spends.Add(spends, poolTx.Cost())
+			}
+		}
+	}
+
+	if spends.Cmp(account.Balance) > 0 {
+		return fmt.Errorf("%w: balance %s; required: %s", ErrInsufficientFunds, account.Balance, spends)
+	}
+
+	return nil
+}
+
+// AddLocal adds transaction submitted to this node and appends it to the local transactions journal
+func (p *TxPool) AddLocal(tx *types.SignedTx) (*types.SignedTx, error) {
+	replaced, err := p.Add(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.locals[tx.From] = true
+	if p.journal != nil {
+		if err := p.journal.insert(tx); err != nil && err != ErrNoActiveJournal {
+			p.logger.Warnf("Unable to journal local tx: %s", err)
+		}
+	}
+
+	return replaced, nil
+}
+
+// LoadJournal replays journal transactions with provided function, which is expected to validate
+// and add them to the pool by AddLocal, and rotates the journal to keep the accepted ones only
+func (p *TxPool) LoadJournal(replay func(tx *types.SignedTx) error) error {
+	if p.journal == nil {
+		return nil
+	}
+
+	txs, err := p.journal.load()
+	if err != nil {
+		p.logger.Warnf("Local transactions journal is loaded partially: %s", err)
+	}
+
+	var dropped int
+	for _, tx := range txs {
+		if err := replay(tx); err != nil {
+			p.logger.Debugf("Dropped journal tx: %s", err)
+			dropped++
+		}
+	}
+	p.logger.Infow("Loaded local transactions journal", "txs", len(txs), "dropped", dropped)
+
+	return p.Rotate()
+}
+
+// Rotate rewrites the journal with local transactions, which are still in the pool,
+// so mined and dropped transactions are removed from it
+func (p *TxPool) Rotate() error {
+	if p.journal == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var txs []*types.SignedTx
+	for addr := range p.locals {
+		var count int
+		for _, list := range []*txList{p.pending[addr], p.queue[addr]} {
+			if list != nil {
+				txs = append(txs, list.Flatten()...)
+				count += list.Len()
+			}
+		}
+
+		if count == 0 {
+			delete(p.locals, addr)
+		}
+	}
+
+	if err := p.journal.rotate(txs); err != nil {
+		return err
+	}
+
+	p.logger.Debugw("Rotated local transactions journal", "txs", len(txs), "accounts", len(p.locals))
+	return nil
+}
+
+// Close closes local transactions journal
+func (p *TxPool) Close() error {
+	if p.journal == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.journal.close()
+}
+
+// ReplacementPrice returns minimal nether price of transaction to replace
+// the pool one with the same nonce and provided price. Price, which cannot be bumped
+// within 64 bits, cannot be replaced
+func (p *TxPool) ReplacementPrice(price uint64) (uint64, error) {
+	bumped := new(big.Int).SetUint64(price)
+	bumped.Mul(bumped, new(big.Int).Add(big.NewInt(100), new(big.Int).SetUint64(p.config.PriceBump)))
+	bumped.Quo(bumped, big.NewInt(100))
+
+	if min := new(big.Int).Add(new(big.Int).SetUint64(price), big.NewInt(1)); bumped.Cmp(min) < 0 {
+		bumped = min
+	}
+	if !bumped.IsUint64() {
+		return 0, fmt.Errorf("%w: nether price %d cannot be bumped by %d%%", ErrReplaceUnderpriced, price, p.config.PriceBump)
+	}
+
+	return bumped.Uint64(), nil
+}
+
+// Find returns pending or queued sender transaction with specified nonce
+func (p *TxPool) Find(addr common.Address, nonce uint64) (*types.SignedTx, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, list := range []*txList{p.pending[addr], p.queue[addr]} {
+		if list != nil && list.Get(nonce) != nil {
+			return list.Get(nonce), true
+		}
+	}
+
+	return nil, false
+}
+
+// Remove removes transaction from the pool. Pending transactions of the same sender
+// with higher nonces are moved back to the queue
+func (p *TxPool) Remove(hash common.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.removeTx(hash)
+}
+
+// Reset re-validates the pool against the new chain head state: drops transactions included
+// to the canonical chain and the ones senders cannot afford anymore, and rebuilds pending lists
+// starting from the next accounts nonces. It has to be called once chain head is changed
+func (p *TxPool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for addr, list := range p.pending {
+		queue := p.list(p.queue, addr)
+		for _, tx := range list.Flatten() {
+			queue.Put(tx)
+		}
+		delete(p.pending, addr)
+	}
+
+	var dropped []*types.SignedTx
+	for addr, queue := range p.queue {
+		account, err := p.chain.GetBalanceOrEmpty(addr)
+		if err != nil {
+			p.logger.Errorf("Unable to get pool sender balance: %s", err)
+			continue
+		}
+
+		dropped = append(dropped, queue.Forward(account.Nonce+1)...)
+
+		// transactions following the first unaffordable one cannot be applied as well
+		spends := new(big.Int)
+		for _, tx := range queue.Flatten() {
+			spends.Add(spends, tx.Cost())
+			if spends.Cmp(account.Balance) > 0 {
+				dropped = append(dropped, queue.Cap(tx.Nonce-1)...)
+				break
+			}
+		}
+
+		p.promote(addr)
+	}
+
+	for _, tx := range dropped {
+		if hash, err := txHash(tx); err == nil {
+			delete(p.all, hash)
+		}
+	}
+}
+
+// Get returns pool transaction by its hash
+func (p *TxPool) Get(hash common.Hash) (*types.SignedTx, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	tx, ok := p.all[hash]
+	return tx, ok
+}
+
+// Stats returns amount of pending and queued transactions
+func (p *TxPool) Stats() (int, int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var pending int
+	for _, list := range p.pending {
+		pending += list.Len()
+	}
+
+	return pending, len(p.all) - pending
+}
+
+// Nonce returns the next sender nonce, including pending transactions
+func (p *TxPool) Nonce(addr common.Address) uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.pendingNonce(addr)
+}
+
+// Pending returns pending transactions of every sender sorted by nonce
+func (p *TxPool) Pending() map[common.Address][]*types.SignedTx {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return flatten(p.pending)
+}
+
+// Queued returns queued transactions of every sender sorted by nonce
+func (p *TxPool) Queued() map[common.Address][]*types.SignedTx {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return flatten(p.queue)
+}
+
+// Content returns pending and queued transactions of specified sender sorted by nonce
+func (p *TxPool) Content(addr common.Address) ([]*types.SignedTx, []*types.SignedTx) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var pending, queued []*types.SignedTx
+	if list, ok := p.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	if list, ok := p.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+
+	return pending, queued
+}
+
+// Select returns up to limit pending transactions for the next block. Senders transactions
+// are ordered by price and time, but every sender transactions keep nonce sequence
+func (p *TxPool) Select(limit int) []*types.SignedTx {
+	pending := p.Pending()
+
+	heads := make(types.TxByPriceAndTime, 0, len(pending))
+	for addr, txs := range pending {
+		heads = append(heads, txs[0])
+		pending[addr] = txs[1:]
+	}
+	heap.Init(&heads)
+
+	var txs []*types.SignedTx
+	for len(heads) > 0 && len(txs) < limit {
+		tx := heads[0]
+		txs = append(txs, tx)
+
+		if next := pending[tx.From]; len(next) > 0 {
+			heads[0] = next[0]
+			pending[tx.From] = next[1:]
+			heap.Fix(&heads, 0)
+		} else {
+			heap.Pop(&heads)
+		}
+	}
+
+	return txs
+}
+
+// list returns sender transactions list, creating an empty one if it does not exist
+func (p *TxPool) list(lists map[common.Address]*txList, addr common.Address) *txList {
+	list, ok := lists[addr]
+	if !ok {
+		list = newTxList()
+		lists[addr] = list
+	}
+	return list
+}
+
+func (p *TxPool) accountLen(addr common.Address) int {
+	var count int
+	if list, ok := p.pending[addr]; ok {
+		count += list.Len()
+	}
+	if list, ok := p.queue[addr]; ok {
+		count += list.Len()
+	}
+	return count
+}
+
+// pendingNonce returns the nonce following sender pending transactions
+func (p *TxPool) pendingNonce(addr common.Address) uint64 {
+	nonce := p.chain.GetNextAccountNonce(addr)
+	if list, ok := p.pending[addr]; ok {
+		for list.Get(nonce) != nil {
+			nonce++
+		}
+	}
+	return nonce
+}
+
+// promote moves sender queued transactions to pending list, while their nonces are contiguous
+func (p *TxPool) promote(addr common.Address) {
+	queue, ok := p.queue[addr]
+	if !ok {
+		return
+	}
+
+	for nonce := p.pendingNonce(addr); queue.Get(nonce) != nil; nonce++ {
+		p.list(p.pending, addr).Put(queue.Get(nonce))
+		queue.Remove(nonce)
+	}
+
+	p.cleanup(addr)
+}
+
+// removeTx removes transaction from the pool and moves pending transactions
+// following its nonce back to the queue
+func (p *TxPool) removeTx(hash common.Hash) {
+	tx, ok := p.all[hash]
+	if !ok {
+		return
+	}
+	delete(p.all, hash)
+
+	if list, ok := p.pending[tx.From]; ok && list.Get(tx.Nonce) == tx {
+		list.Remove(tx.Nonce)
+		queue := p.list(p.queue, tx.From)
+		for _, demoted := range list.Cap(tx.Nonce) {
+			queue.Put(demoted)
+		}
+	} else if list, ok := p.queue[tx.From]; ok {
+		list.Remove(tx.Nonce)
+	}
+
+	p.cleanup(tx.From)
+}
+
+// cleanup removes empty sender lists
+func (p *TxPool) cleanup(addr common.Address) {
+	if list, ok := p.pending[addr]; ok && list.Len() == 0 {
+		delete(p.pending, addr)
+	}
+	if list, ok := p.queue[addr]; ok && list.Len() == 0 {
+		delete(p.queue, addr)
+	}
+}
+
+// lowestPriced returns the cheapest pool transaction, the latest one of equally priced
+func (p *TxPool) lowestPriced() *types.SignedTx {
+	var lowest *types.SignedTx
+	for _, tx := range p.all {
+		if lowest == nil || types.TxByPriceAndTime([]*types.SignedTx{lowest, tx}).Less(0, 1) {
+			lowest = tx
+		}
+	}
+	return lowest
+}
+
+func flatten(lists map[common.Address]*txList) map[common.Address][]*types.SignedTx {
+	txs := make(map[common.Address][]*types.SignedTx, len(lists))
+	for addr, list := range lists {
+		txs[addr] = list.Flatten()
+	}
+	return txs
+}
@@ -0,0 +1,380 @@
+package txpool
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+	"go.uber.org/zap"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// testChain is the pool chain state of accounts nonces and balances,
+// unknown accounts have no transactions and enough balance for any of the test ones
+type testChain struct {
+	nonces   map[common.Address]uint64
+	balances map[common.Address]*big.Int
+}
+
+func newTestChain() *testChain {
+	return &testChain{
+		nonces:   make(map[common.Address]uint64),
+		balances: make(map[common.Address]*big.Int),
+	}
+}
+
+func (c *testChain) GetNextAccountNonce(addr common.Address) uint64 {
+	return c.nonces[addr] + 1
+}
+
+func (c *testChain) GetBalanceOrEmpty(addr common.Address) (*types.Balance, error) {
+	balance, ok := c.balances[addr]
+	if !ok {
+		balance = new(big.Int).Lsh(big.NewInt(1), 100)
+	}
+	return &types.Balance{Address: addr, Balance: new(big.Int).Set(balance), Nonce: c.nonces[addr]}, nil
+}
+
+var (
+	alice = common.HexToAddress("0xa1")
+	bob   = common.HexToAddress("0xb0b")
+)
+
+// newTestTx returns transaction of the value 1, so it costs nether * price + 1. Pool does not check signatures
+func newTestTx(from common.Address, nonce uint64, nether int64, price uint64) *types.SignedTx {
+	return &types.SignedTx{Transaction: types.Transaction{
+		From:        from,
+		To:          common.HexToAddress("0x55"),
+		Nonce:       nonce,
+		Value:       big.NewInt(1),
+		Nether:      big.NewInt(nether),
+		NetherPrice: price,
+		Time:        int64(nonce),
+	}}
+}
+
+func TestTxPoolAdd(t *testing.T) {
+	type step struct {
+		tx  *types.SignedTx
+		err error
+	}
+
+	tests := []struct {
+		name      string
+		config    Config
+		nonce     uint64   // alice chain nonce
+		balance   *big.Int // alice chain balance
+		steps     []step
+		pending   int
+		queued    int
+		nextNonce uint64 // alice next pool nonce
+	}{
+		{
+			name:      "nonce sequence is pending",
+			steps:     []step{{tx: newTestTx(alice, 1, 10, 1)}, {tx: newTestTx(alice, 2, 10, 1)}, {tx: newTestTx(alice, 3, 10, 1)}},
+			pending:   3,
+			nextNonce: 4,
+		},
+		{
+			name:      "future nonce is queued",
+			steps:     []step{{tx: newTestTx(alice, 1, 10, 1)}, {tx: newTestTx(alice, 3, 10, 1)}},
+			pending:   1,
+			queued:    1,
+			nextNonce: 2,
+		},
+		{
+			name: "filled nonce gap promotes queued transactions",
+			steps: []step{
+				{tx: newTestTx(alice, 1, 10, 1)}, {tx: newTestTx(alice, 3, 10, 1)},
+				{tx: newTestTx(alice, 4, 10, 1)}, {tx: newTestTx(alice, 2, 10, 1)},
+			},
+			pending:   4,
+			nextNonce: 5,
+		},
+		{
+			name:      "pending sequence starts from the chain nonce",
+			nonce:     5,
+			steps:     []step{{tx: newTestTx(alice, 5, 10, 1), err: ErrNonceTooLow}, {tx: newTestTx(alice, 6, 10, 1)}},
+			pending:   1,
+			nextNonce: 7,
+		},
+		{
+			name:      "nonce too far from the pending one",
+			config:    Config{MaxNonceGap: 2},
+			steps:     []step{{tx: newTestTx(alice, 4, 10, 1), err: ErrNonceTooHigh}, {tx: newTestTx(alice, 3, 10, 1)}},
+			queued:    1,
+			nextNonce: 1,
+		},
+		{
+			name:      "known transaction",
+			steps:     []step{{tx: newTestTx(alice, 1, 10, 1)}, {tx: newTestTx(alice, 1, 10, 1), err: ErrAlreadyKnown}},
+			pending:   1,
+			nextNonce: 2,
+		},
+		{
+			name:      "account limit",
+			config:    Config{AccountSlots: 2},
+			steps:     []step{{tx: newTestTx(alice, 1, 10, 1)}, {tx: newTestTx(alice, 3, 10, 1)}, {tx: newTestTx(alice, 4, 10, 1), err: ErrAccountLimit}},
+			pending:   1,
+			queued:    1,
+			nextNonce: 2,
+		},
+		{
+			name: "pending spends exceed balance",
+			// balance does not fit uint64, so it is not truncated by the pool checks
+			balance: new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(10)),
+			steps: []step{
+				{tx: newTestTx(alice, 1, 10, 1)},
+				{tx: newTestTx(alice, 2, 1<<62, 1)}, {tx: newTestTx(alice, 3, 1<<62, 1)}, {tx: newTestTx(alice, 4, 1<<62, 1)},
+				{tx: newTestTx(alice, 5, 1<<62, 1), err: ErrInsufficientFunds},
+			},
+			pending:   4,
+			nextNonce: 5,
+		},
+		{
+			name:   "full pool rejects the cheapest transaction",
+			config: Config{GlobalSlots: 2},
+			steps: []step{
+				{tx: newTestTx(alice, 1, 10, 20)}, {tx: newTestTx(alice, 2, 10, 20)},
+				{tx: newTestTx(bob, 1, 100, 10), err: ErrUnderpriced},
+			},
+			pending:   2,
+			nextNonce: 3,
+		},
+		{
+			name:   "full pool evicts the cheapest transaction",
+			config: Config{GlobalSlots: 2},
+			steps: []step{
+				{tx: newTestTx(alice, 1, 10, 20)}, {tx: newTestTx(alice, 2, 10, 10)},
+				{tx: newTestTx(bob, 1, 10, 30)},
+			},
+			pending:   2,
+			nextNonce: 2,
+		},
+		{name: "zero nether", steps: []step{{tx: newTestTx(alice, 1, 0, 1), err: ErrZeroNether}}, nextNonce: 1},
+		{
+			name: "negative value",
+			steps: []step{{tx: func() *types.SignedTx {
+				tx := newTestTx(alice, 1, 10, 1)
+				tx.Value = big.NewInt(-1)
+				return tx
+			}(), err: ErrNegativeValue}},
+			nextNonce: 1,
+		},
+		{
+			name: "oversized transaction",
+			steps: []step{{tx: func() *types.SignedTx {
+				tx := newTestTx(alice, 1, 10, 1)
+				tx.Data = make([]byte, 1<<20)
+				return tx
+			}(), err: ErrOversizedTx}},
+			nextNonce: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig
+			if tt.config.GlobalSlots != 0 {
+				config.GlobalSlots = tt.config.GlobalSlots
+			}
+			if tt.config.AccountSlots != 0 {
+				config.AccountSlots = tt.config.AccountSlots
+			}
+			if tt.config.MaxNonceGap != 0 {
+				config.MaxNonceGap = tt.config.MaxNonceGap
+			}
+
+			chain := newTestChain()
+			chain.nonces[alice] = tt.nonce
+			if tt.balance != nil {
+				chain.balances[alice] = tt.balance
+			}
+			pool := New(config, chain, zap.NewNop().Sugar())
+
+			for i, step := range tt.steps {
+				if _, err := pool.Add(step.tx); !errors.Is(err, step.err) {
+					t.Fatalf("step %d: expected %v, got %v", i, step.err, err)
+				}
+			}
+
+			if pending, queued := pool.Stats(); pending != tt.pending || queued != tt.queued {
+				t.Fatalf("expected %d pending and %d queued, got %d and %d", tt.pending, tt.queued, pending, queued)
+			}
+			if nonce := pool.Nonce(alice); nonce != tt.nextNonce {
+				t.Fatalf("expected next nonce %d, got %d", tt.nextNonce, nonce)
+			}
+		})
+	}
+}
+
+func TestTxPoolSelect(t *testing.T) {
+	pool := New(DefaultConfig, newTestChain(), zap.NewNop().Sugar())
+
+	// alice second transaction is the highest priced one, but it may not precede her first one
+	txs := []*types.SignedTx{
+		newTestTx(alice, 1, 10, 10),
+		newTestTx(alice, 2, 10, 30),
+		newTestTx(bob, 1, 10, 20),
+		newTestTx(bob, 2, 100, 5),
+	}
+	for _, tx := range txs {
+		if _, err := pool.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		limit int
+		want  []*types.SignedTx
+	}{
+		{limit: 1, want: []*types.SignedTx{txs[2]}},
+		{limit: 3, want: []*types.SignedTx{txs[2], txs[0], txs[1]}},
+		{limit: 10, want: []*types.SignedTx{txs[2], txs[0], txs[1], txs[3]}},
+	}
+
+	for _, tt := range tests {
+		selected := pool.Select(tt.limit)
+		if len(selected) != len(tt.want) {
+			t.Fatalf("limit %d: expected %d txs, got %d", tt.limit, len(tt.want), len(selected))
+		}
+		for i, tx := range selected {
+			if tx != tt.want[i] {
+				t.Errorf("limit %d: tx %d is %s nonce %d; expected %s nonce %d",
+					tt.limit, i, tx.From, tx.Nonce, tt.want[i].From, tt.want[i].Nonce)
+			}
+		}
+	}
+}
+
+func TestTxPoolReplace(t *testing.T) {
+	tests := []struct {
+		name   string
+		nonce  uint64 // nonce of the pool transaction, 1 is pending and 3 is queued one
+		price  uint64 // replacement nether price, pool transaction price is 100
+		err    error
+		queued bool
+	}{
+		{name: "pending replacement", nonce: 1, price: 110},
+		{name: "underpriced pending replacement", nonce: 1, price: 109, err: ErrReplaceUnderpriced},
+		{name: "queued replacement", nonce: 3, price: 200, queued: true},
+		{name: "underpriced queued replacement", nonce: 3, price: 100, err: ErrReplaceUnderpriced, queued: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig
+			config.PriceBump = 10
+			pool := New(config, newTestChain(), zap.NewNop().Sugar())
+
+			old := newTestTx(alice, tt.nonce, 10, 100)
+			if _, err := pool.Add(old); err != nil {
+				t.Fatal(err)
+			}
+
+			replacement := newTestTx(alice, tt.nonce, 10, tt.price)
+			replacement.Time = 42
+			replaced, err := pool.Add(replacement)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			want := replacement
+			if tt.err != nil {
+				want = old
+			} else if replaced != old {
+				t.Fatalf("expected replaced %+v, got %+v", old, replaced)
+			}
+			if tx, ok := pool.Find(alice, tt.nonce); !ok || tx != want {
+				t.Fatalf("expected pool tx %+v, got %+v", want, tx)
+			}
+
+			pending, queued := pool.Content(alice)
+			if len(pending)+len(queued) != 1 || (len(queued) == 1) != tt.queued {
+				t.Fatalf("expected queued %t, got %d pending and %d queued", tt.queued, len(pending), len(queued))
+			}
+		})
+	}
+}
+
+func TestTxPoolReplacementPrice(t *testing.T) {
+	tests := []struct {
+		name  string
+		bump  uint64
+		price uint64
+		want  uint64
+		err   error
+	}{
+		{name: "bumped price", bump: 10, price: 100, want: 110},
+		{name: "bump below one is raised to the next price", bump: 10, price: 5, want: 6},
+		{name: "zero bump", bump: 0, price: 100, want: 101},
+		{name: "bumped price does not overflow", bump: 10, price: math.MaxUint64 / 2, want: math.MaxUint64 / 2 * 11 / 10},
+		{name: "bumped price overflows 64 bits", bump: 10, price: math.MaxUint64 / 10 * 10, err: ErrReplaceUnderpriced},
+		{name: "max price", bump: 0, price: math.MaxUint64, err: ErrReplaceUnderpriced},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig
+			config.PriceBump = tt.bump
+			pool := New(config, newTestChain(), zap.NewNop().Sugar())
+
+			price, err := pool.ReplacementPrice(tt.price)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if price != tt.want {
+				t.Fatalf("expected price %d, got %d", tt.want, price)
+			}
+		})
+	}
+}
+
+func TestTxPoolReset(t *testing.T) {
+	chain := newTestChain()
+	pool := New(DefaultConfig, chain, zap.NewNop().Sugar())
+
+	for _, tx := range []*types.SignedTx{
+		newTestTx(alice, 1, 10, 1), newTestTx(alice, 2, 10, 1), newTestTx(alice, 4, 10, 1),
+		newTestTx(bob, 1, 10, 1), newTestTx(bob, 2, 10, 1),
+	} {
+		if _, err := pool.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// new chain head includes alice transactions up to nonce 3, and bob cannot afford his second one
+	chain.nonces[alice] = 3
+	chain.balances[bob] = big.NewInt(11)
+	pool.Reset()
+
+	tests := []struct {
+		addr    common.Address
+		pending []uint64
+		queued  []uint64
+	}{
+		{addr: alice, pending: []uint64{4}},
+		{addr: bob, pending: []uint64{1}},
+	}
+	for _, tt := range tests {
+		pending, queued := pool.Content(tt.addr)
+		for _, list := range []struct {
+			txs  []*types.SignedTx
+			want []uint64
+		}{{txs: pending, want: tt.pending}, {txs: queued, want: tt.queued}} {
+			if len(list.txs) != len(list.want) {
+				t.Fatalf("%s: expected nonces %v, got %d txs", tt.addr, list.want, len(list.txs))
+			}
+			for i, tx := range list.txs {
+				if tx.Nonce != list.want[i] {
+					t.Fatalf("%s: expected nonces %v, got %d at %d", tt.addr, list.want, tx.Nonce, i)
+				}
+			}
+		}
+	}
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("expected 2 pending and 0 queued, got %d and %d", pending, queued)
+	}
+}
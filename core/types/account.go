@@ -1,9 +1,12 @@
 package types
 
 import (
-	"bytes"
-	"encoding/gob"
+	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/rlp"
+	"io"
+	"math/big"
 )
 
 //type Account struct {
@@ -16,21 +19,96 @@ import (
 // Balance represents registered account balance response
 type Balance struct {
 	Address common.Address `json:"address" yaml:"address"`
-	Balance uint64         `json:"balance" yaml:"balance"`
+	Balance *big.Int       `json:"balance" yaml:"balance"`
 	Nonce   uint64         `json:"nonce" yaml:"nonce"`
+
+	Stake *big.Int `json:"stake" yaml:"stake"` // bonded value, which is slashed if the account misbehaves
 }
 
-func (b *Balance) Serialize() ([]byte, error) {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-	if err := encoder.Encode(*b); err != nil {
-		return nil, err
+// balanceRLP is Balance canonical encoding layout
+type balanceRLP struct {
+	Address common.Address
+	Balance *big.Int
+	Nonce   uint64
+
+	Stake *big.Int `rlp:"optional"` // staking value is optional, so regular balances keep their encoding
+}
+
+// EncodeRLP implements rlp.Encoder
+func (b *Balance) EncodeRLP(w io.Writer) error {
+	enc := balanceRLP{
+		Address: b.Address,
+		Balance: b.Balance,
+		Nonce:   b.Nonce,
+	}
+	if b.Stake != nil && b.Stake.Sign() != 0 {
+		enc.Stake = b.Stake
 	}
 
-	return result.Bytes(), nil
+	return rlp.Encode(w, enc)
+}
+
+// DecodeRLP implements rlp.Decoder
+func (b *Balance) DecodeRLP(s *rlp.Stream) error {
+	var dec balanceRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+
+	*b = Balance{
+		Address: dec.Address,
+		Balance: dec.Balance,
+		Nonce:   dec.Nonce,
+		Stake:   dec.Stake,
+	}
+	return nil
+}
+
+// HasStake returns whether account has bonded value
+func (b *Balance) HasStake() bool {
+	return b.Stake != nil && b.Stake.Sign() > 0
+}
+
+// balanceJSON is Balance JSON layout, balance value is encoded as decimal string
+type balanceJSON struct {
+	Address common.Address   `json:"address"`
+	Balance *math.Decimal256 `json:"balance"`
+	Nonce   uint64           `json:"nonce"`
+	Stake   *math.Decimal256 `json:"stake,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (b Balance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(balanceJSON{
+		Address: b.Address,
+		Balance: (*math.Decimal256)(b.Balance),
+		Nonce:   b.Nonce,
+		Stake:   (*math.Decimal256)(b.Stake),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Balance) UnmarshalJSON(input []byte) error {
+	var dec balanceJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	*b = Balance{
+		Address: dec.Address,
+		Balance: (*big.Int)(dec.Balance),
+		Nonce:   dec.Nonce,
+		Stake:   (*big.Int)(dec.Stake),
+	}
+	return nil
+}
+
+// Serialize serializes balance with canonical encoding
+func (b *Balance) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(b)
 }
 
+// Deserialize deserializes binary data to balance
 func (b *Balance) Deserialize(data []byte) error {
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	return decoder.Decode(b)
+	return rlp.DecodeBytes(data, b)
 }
@@ -0,0 +1,11 @@
+package types
+
+import "github.com/ethereum/go-ethereum/common"
+
+// AddressTx represents transaction sent or received by address with its chain location
+type AddressTx struct {
+	TxHash      common.Hash `json:"tx_hash" yaml:"tx_hash"`
+	BlockNumber uint64      `json:"block_number" yaml:"block_number"`
+	TxIndex     int         `json:"tx_index" yaml:"tx_index"`
+	Tx          *SignedTx   `json:"tx" yaml:"tx"`
+}
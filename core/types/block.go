@@ -3,8 +3,13 @@ package types
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
+	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/rlp"
+	"io"
+	"math/big"
 )
 
 // BlockHeader represents header part of chain block
@@ -17,29 +22,202 @@ type BlockHeader struct {
 	Timestamp   int64          `json:"timestamp" yaml:"timestamp"`
 	ReceiptHash common.Hash    `json:"receipts_hash" yaml:"receipts_hash"`
 	TxHash      common.Hash    `json:"txs_hash" yaml:"txs_hash"`
-	NetherUsed  uint64         `json:"nether_used" yaml:"nether_used"`
-	Coinbase    common.Address `json:"coinbase" yaml:"coinbase"` // author node address
+	NetherUsed  *big.Int       `json:"nether_used" yaml:"nether_used"`
+	Coinbase    common.Address `json:"coinbase" yaml:"coinbase"`     // author node address
+	Difficulty  uint64         `json:"difficulty" yaml:"difficulty"` // consensus engine block weight
+	Extra       []byte         `json:"extra_data" yaml:"extra_data"` // consensus engine data, e.g. signers votes
+	Signature   []byte         `json:"signature" yaml:"signature"`   // author signature of the header seal hash
+
+	EvidenceHash common.Hash      `json:"evidence_hash" yaml:"evidence_hash"` // hash of the block misbehavior evidence
+	Punished     []common.Address `json:"punished" yaml:"punished"`           // offenders punished by the block evidence
+	Bloom        Bloom            `json:"bloom" yaml:"bloom"`                 // filter of the block transactions addresses
 }
 
-// Serialize serializes block header with god encodig
-func (bh BlockHeader) Serialize() ([]byte, error) {
-	var buf bytes.Buffer
-	encoder := gob.NewEncoder(&buf)
-	if err := encoder.Encode(bh); err != nil {
-		return nil, err
+// headerRLP is BlockHeader canonical encoding layout
+type headerRLP struct {
+	Root        common.Hash
+	PrevHash    common.Hash
+	BlockHash   common.Hash
+	Number      uint64
+	Timestamp   uint64
+	ReceiptHash common.Hash
+	TxHash      common.Hash
+	NetherUsed  *big.Int
+	Coinbase    common.Address
+	Difficulty  uint64 `rlp:"optional"` // consensus values are optional, so unsealed headers keep their encoding
+	Extra       []byte `rlp:"optional"`
+	Signature   []byte `rlp:"optional"`
+
+	EvidenceHash common.Hash      `rlp:"optional"`
+	Punished     []common.Address `rlp:"optional"`
+	Bloom        []byte           `rlp:"optional"`
+}
+
+// EncodeRLP implements rlp.Encoder
+func (bh *BlockHeader) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, headerRLP{
+		Root:        bh.Root,
+		PrevHash:    bh.PrevHash,
+		BlockHash:   bh.BlockHash,
+		Number:      bh.Number,
+		Timestamp:   uint64(bh.Timestamp),
+		ReceiptHash: bh.ReceiptHash,
+		TxHash:      bh.TxHash,
+		NetherUsed:  bh.NetherUsed,
+		Coinbase:    bh.Coinbase,
+		Difficulty:  bh.Difficulty,
+		Extra:       nilIfEmpty(bh.Extra),
+		Signature:   nilIfEmpty(bh.Signature),
+
+		EvidenceHash: bh.EvidenceHash,
+		Punished:     nilIfNoAddresses(bh.Punished),
+		Bloom:        nilIfEmptyBloom(bh.Bloom),
+	})
+}
+
+// nilIfEmpty returns nil for empty value, as trailing optional values are omitted only if nil
+func nilIfEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func nilIfNoAddresses(addrs []common.Address) []common.Address {
+	if len(addrs) == 0 {
+		return nil
+	}
+	return addrs
+}
+
+func nilIfEmptyBloom(b Bloom) []byte {
+	if b.IsEmpty() {
+		return nil
+	}
+	return b[:]
+}
+
+// DecodeRLP implements rlp.Decoder
+func (bh *BlockHeader) DecodeRLP(s *rlp.Stream) error {
+	var dec headerRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+
+	*bh = BlockHeader{
+		Root:        dec.Root,
+		PrevHash:    dec.PrevHash,
+		BlockHash:   dec.BlockHash,
+		Number:      dec.Number,
+		Timestamp:   int64(dec.Timestamp),
+		ReceiptHash: dec.ReceiptHash,
+		TxHash:      dec.TxHash,
+		NetherUsed:  dec.NetherUsed,
+		Coinbase:    dec.Coinbase,
+		Difficulty:  dec.Difficulty,
+		Extra:       dec.Extra,
+		Signature:   dec.Signature,
+
+		EvidenceHash: dec.EvidenceHash,
+		Punished:     dec.Punished,
 	}
-	return buf.Bytes(), nil
+	return bh.Bloom.setBytes(dec.Bloom)
+}
+
+// headerJSON is BlockHeader JSON layout, big values are encoded as decimal strings
+type headerJSON struct {
+	Root        common.Hash      `json:"root"`
+	PrevHash    common.Hash      `json:"prev_hash"`
+	BlockHash   common.Hash      `json:"block_hash"`
+	Number      uint64           `json:"number"`
+	Timestamp   int64            `json:"timestamp"`
+	ReceiptHash common.Hash      `json:"receipts_hash"`
+	TxHash      common.Hash      `json:"txs_hash"`
+	NetherUsed  *math.Decimal256 `json:"nether_used"`
+	Coinbase    common.Address   `json:"coinbase"`
+	Difficulty  uint64           `json:"difficulty"`
+	Extra       hexutil.Bytes    `json:"extra_data"`
+	Signature   hexutil.Bytes    `json:"signature"`
+
+	EvidenceHash common.Hash      `json:"evidence_hash"`
+	Punished     []common.Address `json:"punished,omitempty"`
+	Bloom        Bloom            `json:"bloom"`
+}
+
+func (bh *BlockHeader) toJSON() headerJSON {
+	return headerJSON{
+		Root:        bh.Root,
+		PrevHash:    bh.PrevHash,
+		BlockHash:   bh.BlockHash,
+		Number:      bh.Number,
+		Timestamp:   bh.Timestamp,
+		ReceiptHash: bh.ReceiptHash,
+		TxHash:      bh.TxHash,
+		NetherUsed:  (*math.Decimal256)(bh.NetherUsed),
+		Coinbase:    bh.Coinbase,
+		Difficulty:  bh.Difficulty,
+		Extra:       bh.Extra,
+		Signature:   bh.Signature,
+
+		EvidenceHash: bh.EvidenceHash,
+		Punished:     bh.Punished,
+		Bloom:        bh.Bloom,
+	}
+}
+
+func (bh *BlockHeader) fromJSON(dec headerJSON) {
+	*bh = BlockHeader{
+		Root:        dec.Root,
+		PrevHash:    dec.PrevHash,
+		BlockHash:   dec.BlockHash,
+		Number:      dec.Number,
+		Timestamp:   dec.Timestamp,
+		ReceiptHash: dec.ReceiptHash,
+		TxHash:      dec.TxHash,
+		NetherUsed:  (*big.Int)(dec.NetherUsed),
+		Coinbase:    dec.Coinbase,
+		Difficulty:  dec.Difficulty,
+		Extra:       dec.Extra,
+		Signature:   dec.Signature,
+
+		EvidenceHash: dec.EvidenceHash,
+		Punished:     dec.Punished,
+		Bloom:        dec.Bloom,
+	}
+}
+
+// MarshalJSON implements json.Marshaler
+func (bh BlockHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bh.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (bh *BlockHeader) UnmarshalJSON(input []byte) error {
+	var dec headerJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	bh.fromJSON(dec)
+	return nil
+}
+
+// Serialize serializes block header with canonical encoding
+func (bh BlockHeader) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(&bh)
 }
 
 // Deserialize deserializes binary data to BlockHeader
 func (bh *BlockHeader) Deserialize(d []byte) error {
-	decoder := gob.NewDecoder(bytes.NewReader(d))
-	return decoder.Decode(bh)
+	return rlp.DecodeBytes(d, bh)
 }
 
-// Hash returns a hash of the block header
+// Hash returns a hash of the block header values, except the BlockHash itself
 func (bh *BlockHeader) Hash() ([]byte, error) {
-	enc, err := bh.Serialize()
+	headerCopy := *bh
+	headerCopy.BlockHash = common.Hash{}
+
+	enc, err := headerCopy.Serialize()
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +226,15 @@ func (bh *BlockHeader) Hash() ([]byte, error) {
 	return hash[:], nil
 }
 
+// SealHash returns a hash of the block header values signed by its author,
+// which are all of them except the BlockHash and the Signature
+func (bh *BlockHeader) SealHash() ([]byte, error) {
+	headerCopy := *bh
+	headerCopy.Signature = nil
+
+	return headerCopy.Hash()
+}
+
 // NewBlock creates and returns Block
 func NewBlock(header BlockHeader, txs []*SignedTx) *Block {
 	return &Block{
@@ -60,21 +247,17 @@ func NewBlock(header BlockHeader, txs []*SignedTx) *Block {
 type Block struct {
 	BlockHeader
 	Transactions []*SignedTx `json:"transactions" yaml:"transactions"`
+	Evidence     []*Evidence `json:"evidence" yaml:"evidence"` // misbehavior proofs, committed to the header by EvidenceHash
 
 	TxHashes []common.Hash `json:"tx_hashes" yaml:"tx_hashes"`
 
 	ReceivedAt int64 `json:"received_at" yaml:"received_at"`
 }
 
-// Hash returns a hash of the block
+// Hash returns a hash of the block, which is its header hash.
+// Block transactions are committed to the header by TxHash value
 func (b *Block) Hash() ([]byte, error) {
-	enc, err := b.Serialize()
-	if err != nil {
-		return nil, err
-	}
-
-	hash := sha256.Sum256(enc)
-	return hash[:], nil
+	return b.BlockHeader.Hash()
 }
 
 // Size returns encoded block value byte length
@@ -87,36 +270,125 @@ func (b *Block) Size() (int, error) {
 	return len(enc), nil
 }
 
-// HashTransactions returns a hash of the transactions in the block
+// HashTransactions returns Merkle root of the block transactions hashes,
+// so every transaction inclusion is proven by the branch of the tree
 func (b *Block) HashTransactions() ([]byte, error) {
-	var txHashes [][]byte
-	var txHash [32]byte
-
-	for _, tx := range b.Transactions {
+	txHashes := make([]common.Hash, len(b.Transactions))
+	for i, tx := range b.Transactions {
 		hash, err := tx.Hash()
 		if err != nil {
 			return nil, err
 		}
-		txHashes = append(txHashes, hash)
+		txHashes[i] = common.BytesToHash(hash)
 	}
-	txHash = sha256.Sum256(bytes.Join(txHashes, []byte{}))
 
-	return txHash[:], nil
+	return MerkleRoot(txHashes).Bytes(), nil
 }
 
-// Serialize serializes the block
-func (b *Block) Serialize() ([]byte, error) {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-	if err := encoder.Encode(b); err != nil {
-		return nil, err
+// HashEvidence returns a hash of the block evidence, which is empty if there is no evidence,
+// so blocks without evidence keep their header encoding
+func (b *Block) HashEvidence() (common.Hash, error) {
+	if len(b.Evidence) == 0 {
+		return common.Hash{}, nil
+	}
+
+	var hashes [][]byte
+	for _, ev := range b.Evidence {
+		hash, err := ev.Hash()
+		if err != nil {
+			return common.Hash{}, err
+		}
+		hashes = append(hashes, hash.Bytes())
 	}
 
-	return result.Bytes(), nil
+	return sha256.Sum256(bytes.Join(hashes, []byte{})), nil
+}
+
+// blockJSON is Block JSON layout, which keeps header fields flat
+type blockJSON struct {
+	headerJSON
+	Transactions []*SignedTx   `json:"transactions"`
+	Evidence     []*Evidence   `json:"evidence,omitempty"`
+	TxHashes     []common.Hash `json:"tx_hashes"`
+	ReceivedAt   int64         `json:"received_at"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (b Block) MarshalJSON() ([]byte, error) {
+	return json.Marshal(blockJSON{
+		headerJSON:   b.BlockHeader.toJSON(),
+		Transactions: b.Transactions,
+		Evidence:     b.Evidence,
+		TxHashes:     b.TxHashes,
+		ReceivedAt:   b.ReceivedAt,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Block) UnmarshalJSON(input []byte) error {
+	var dec blockJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	b.BlockHeader.fromJSON(dec.headerJSON)
+	b.Transactions = dec.Transactions
+	b.Evidence = dec.Evidence
+	b.TxHashes = dec.TxHashes
+	b.ReceivedAt = dec.ReceivedAt
+	return nil
+}
+
+// blockRLP is Block canonical encoding layout
+type blockRLP struct {
+	Header       *BlockHeader
+	Transactions []*SignedTx
+	TxHashes     []common.Hash
+	ReceivedAt   uint64
+	Evidence     []*Evidence `rlp:"optional"` // omitted if empty, so blocks without evidence keep their encoding
+}
+
+// EncodeRLP implements rlp.Encoder
+func (b *Block) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, blockRLP{
+		Header:       &b.BlockHeader,
+		Transactions: b.Transactions,
+		TxHashes:     b.TxHashes,
+		ReceivedAt:   uint64(b.ReceivedAt),
+		Evidence:     nilIfNoEvidence(b.Evidence),
+	})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (b *Block) DecodeRLP(s *rlp.Stream) error {
+	var dec blockRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+
+	*b = Block{
+		BlockHeader:  *dec.Header,
+		Transactions: dec.Transactions,
+		TxHashes:     dec.TxHashes,
+		ReceivedAt:   int64(dec.ReceivedAt),
+		Evidence:     dec.Evidence,
+	}
+	return nil
+}
+
+func nilIfNoEvidence(evidence []*Evidence) []*Evidence {
+	if len(evidence) == 0 {
+		return nil
+	}
+	return evidence
+}
+
+// Serialize serializes the block with canonical encoding
+func (b *Block) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(b)
 }
 
 // Deserialize deserializes binary data to block
 func (b *Block) Deserialize(d []byte) error {
-	decoder := gob.NewDecoder(bytes.NewReader(d))
-	return decoder.Decode(b)
+	return rlp.DecodeBytes(d, b)
 }
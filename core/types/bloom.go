@@ -0,0 +1,100 @@
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	BloomByteLength = 256 // block header bloom filter size
+	bloomBitsPerKey = 3   // bits set by every address
+)
+
+// Bloom is the filter of block transactions addresses: recipients and senders of the transfers.
+// Reward senders are not added, as reward transactions are not queried by their sender
+type Bloom [BloomByteLength]byte
+
+// CreateBloom returns bloom filter of the transactions addresses
+func CreateBloom(txs []*SignedTx) Bloom {
+	var b Bloom
+	for _, tx := range txs {
+		b.Add(tx.To)
+		if !tx.IsReward() {
+			b.Add(tx.From)
+		}
+	}
+	return b
+}
+
+// bloomBits returns bit positions of the address, taken from its hash pairs of bytes
+func bloomBits(addr common.Address) [bloomBitsPerKey]uint {
+	hash := sha256.Sum256(addr.Bytes())
+
+	var bits [bloomBitsPerKey]uint
+	for i := range bits {
+		bits[i] = (uint(hash[2*i])<<8 | uint(hash[2*i+1])) % (BloomByteLength * 8)
+	}
+	return bits
+}
+
+// Add sets the address bits
+func (b *Bloom) Add(addr common.Address) {
+	for _, bit := range bloomBits(addr) {
+		b[BloomByteLength-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test returns whether the address may be added to the filter. False positives are possible, but false negatives are not
+func (b Bloom) Test(addr common.Address) bool {
+	for _, bit := range bloomBits(addr) {
+		if b[BloomByteLength-1-bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestAny returns whether any of addresses may be added to the filter
+func (b Bloom) TestAny(addrs []common.Address) bool {
+	for _, addr := range addrs {
+		if b.Test(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty returns whether no address is added to the filter
+func (b Bloom) IsEmpty() bool {
+	return b == Bloom{}
+}
+
+// setBytes sets the filter from its encoded value, which is empty for the empty filter
+func (b *Bloom) setBytes(data []byte) error {
+	if len(data) == 0 {
+		*b = Bloom{}
+		return nil
+	}
+	if len(data) != BloomByteLength {
+		return fmt.Errorf("invalid bloom length: %d", len(data))
+	}
+
+	copy(b[:], data)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (b Bloom) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(b[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (b *Bloom) UnmarshalText(input []byte) error {
+	var data hexutil.Bytes
+	if err := data.UnmarshalText(input); err != nil {
+		return err
+	}
+	return b.setBytes(data)
+}
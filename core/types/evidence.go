@@ -0,0 +1,85 @@
+package types
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EvidenceKind is the kind of block author or peer misbehavior
+type EvidenceKind uint8
+
+const (
+	EvidenceDoubleSign   EvidenceKind = iota + 1 // two different blocks of the same number sealed by one author
+	EvidenceInvalidBlock                         // sealed block, which breaks block validation rules
+	EvidenceForgedTx                             // transaction with forged sender relayed by the peer
+)
+
+var (
+	ErrUnknownEvidenceKind = errors.New("unknown evidence kind")
+)
+
+var evidenceKindNames = map[EvidenceKind]string{
+	EvidenceDoubleSign:   "double_sign",
+	EvidenceInvalidBlock: "invalid_block",
+	EvidenceForgedTx:     "forged_tx",
+}
+
+func (k EvidenceKind) String() string {
+	if name, ok := evidenceKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(k))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (k EvidenceKind) MarshalText() ([]byte, error) {
+	if _, ok := evidenceKindNames[k]; !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownEvidenceKind, k)
+	}
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (k *EvidenceKind) UnmarshalText(input []byte) error {
+	for kind, name := range evidenceKindNames {
+		if name == string(input) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownEvidenceKind, input)
+}
+
+// Evidence is the proof of the offender account misbehavior, which is included in the block
+// and punished once the block is applied. Proof values depend on the evidence kind:
+// two headers for double signing, the block for invalid block and the offender relay of the forged transaction
+type Evidence struct {
+	Kind     EvidenceKind   `json:"kind" yaml:"kind"`
+	Offender common.Address `json:"offender" yaml:"offender"`
+	Headers  []*BlockHeader `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Blocks   []*Block       `json:"blocks,omitempty" yaml:"blocks,omitempty"`
+	Relays   []*RelayedTx   `json:"relays,omitempty" yaml:"relays,omitempty"`
+}
+
+// Serialize serializes evidence with canonical encoding
+func (e *Evidence) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(e)
+}
+
+// Deserialize deserializes binary data to evidence
+func (e *Evidence) Deserialize(data []byte) error {
+	return rlp.DecodeBytes(data, e)
+}
+
+// Hash returns a hash of the evidence values, which identifies it
+func (e *Evidence) Hash() (common.Hash, error) {
+	enc, err := e.Serialize()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return sha256.Sum256(enc), nil
+}
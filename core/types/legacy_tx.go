@@ -0,0 +1,164 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TxSigning is the transaction encoding, which hash is signed by the transaction sender
+type TxSigning uint64
+
+const (
+	SigningChain TxSigning = iota // canonical RLP encoding bound to chain id
+	SigningGob                    // gob encoding of version 0 chain database, not bound to chain
+	SigningRLP                    // RLP encoding of version 1 chain database, not bound to chain
+)
+
+var (
+	ErrInvalidSigning = errors.New("invalid transaction signing version")
+)
+
+// legacyGobTypes are gob type definitions of version 0 Transaction and its Address fields, sent before
+// the Transaction value with legacyGobTypeId. Gob assigns type ids in the order the process encodes types,
+// so version 0 transactions are hashed with the ids of a process, which encodes Transaction first
+var legacyGobTypes = common.FromHex("6b7f0301010b5472616e73616374696f6e01ff80000108010446726f6d01ff82000102546f01ff82" +
+	"0001054e6f6e6365010600010556616c756501060001064e6574686572010600010b4e65746865725072696365010600010444617461" +
+	"010a00010454696d65010400000017ff81010101074164647265737301ff8200010601280000")
+
+var legacyGobTypeId = []byte{0xff, 0x80}
+
+// legacyGobEncoding returns version 0 Transaction gob encoding with legacyGobTypes
+func legacyGobEncoding(tx *Transaction) ([]byte, error) {
+	// Transaction is the version 0 layout, its type name is a part of gob encoding
+	type Transaction struct {
+		From        common.Address
+		To          common.Address
+		Nonce       uint64
+		Value       uint64
+		Nether      uint64
+		NetherPrice uint64
+		Data        []byte
+		Time        int64
+	}
+	legacy := Transaction{
+		From:        tx.From,
+		To:          tx.To,
+		Nonce:       tx.Nonce,
+		Value:       tx.Value.Uint64(),
+		Nether:      tx.Nether.Uint64(),
+		NetherPrice: tx.NetherPrice,
+		Data:        tx.Data,
+		Time:        tx.Time,
+	}
+
+	// encoder sends type definitions once, so the second message is the value only:
+	// its length, this process type id and the encoded fields
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(legacy); err != nil {
+		return nil, err
+	}
+	buf.Reset()
+	if err := enc.Encode(legacy); err != nil {
+		return nil, err
+	}
+
+	msg := buf.Bytes()
+	_, n := gobUint(msg)
+	_, m := gobUint(msg[n:])
+	if n == 0 || m == 0 {
+		return nil, fmt.Errorf("%w: unexpected gob message", ErrInvalidSigning)
+	}
+	fields := msg[n+m:]
+
+	data := append([]byte{}, legacyGobTypes...)
+	data = append(data, gobUintBytes(uint64(len(legacyGobTypeId)+len(fields)))...)
+	data = append(data, legacyGobTypeId...)
+	return append(data, fields...), nil
+}
+
+// gobUint decodes gob unsigned integer and returns its size, or zero size if data is too short
+func gobUint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	if data[0] < 0x80 {
+		return uint64(data[0]), 1
+	}
+
+	size := int(-int8(data[0]))
+	if size > 8 || len(data) < size+1 {
+		return 0, 0
+	}
+
+	var x uint64
+	for _, b := range data[1 : size+1] {
+		x = x<<8 | uint64(b)
+	}
+	return x, size + 1
+}
+
+// gobUintBytes returns gob encoding of unsigned integer
+func gobUintBytes(x uint64) []byte {
+	if x < 0x80 {
+		return []byte{byte(x)}
+	}
+
+	var buf []byte
+	for ; x > 0; x >>= 8 {
+		buf = append([]byte{byte(x)}, buf...)
+	}
+	return append([]byte{byte(-int8(len(buf)))}, buf...)
+}
+
+// legacyTxRLP is Transaction RLP encoding layout of version 1 chain database
+type legacyTxRLP struct {
+	From        common.Address
+	To          common.Address
+	Nonce       uint64
+	Value       uint64
+	Nether      uint64
+	NetherPrice uint64
+	Data        []byte
+	Time        uint64
+}
+
+// legacyHash returns sha256 hash of the transaction legacy encoding, which was signed
+// by its sender before transactions were bound to chain. Legacy values are 64-bit integers
+func (tx *Transaction) legacyHash(signing TxSigning) (common.Hash, error) {
+	if tx.Value == nil || !tx.Value.IsUint64() || tx.Nether == nil || !tx.Nether.IsUint64() {
+		return common.Hash{}, fmt.Errorf("%w: legacy value %s and nether %s", ErrInvalidSigning, tx.Value, tx.Nether)
+	}
+
+	var data []byte
+	switch signing {
+	case SigningGob:
+		var err error
+		if data, err = legacyGobEncoding(tx); err != nil {
+			return common.Hash{}, err
+		}
+	case SigningRLP:
+		var err error
+		if data, err = rlp.EncodeToBytes(legacyTxRLP{
+			From:        tx.From,
+			To:          tx.To,
+			Nonce:       tx.Nonce,
+			Value:       tx.Value.Uint64(),
+			Nether:      tx.Nether.Uint64(),
+			NetherPrice: tx.NetherPrice,
+			Data:        tx.Data,
+			Time:        uint64(tx.Time),
+		}); err != nil {
+			return common.Hash{}, err
+		}
+	default:
+		return common.Hash{}, fmt.Errorf("%w: %d", ErrInvalidSigning, signing)
+	}
+
+	return sha256.Sum256(data), nil
+}
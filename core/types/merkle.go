@@ -0,0 +1,96 @@
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxMerkleDepth limits proof branch length, it fits any amount of leaves indexed by int
+const maxMerkleDepth = 63
+
+// Merkle tree leaves and inner nodes are hashed with distinct prefixes,
+// so inner node may not be proven as a leaf
+var (
+	merkleLeafPrefix = []byte{0}
+	merkleNodePrefix = []byte{1}
+)
+
+// merkleLevel returns hashed leaves padded with empty hashes up to the power of two
+func merkleLevel(leaves []common.Hash) []common.Hash {
+	size := 1
+	for size < len(leaves) {
+		size *= 2
+	}
+
+	level := make([]common.Hash, size)
+	for i, leaf := range leaves {
+		level[i] = sha256.Sum256(append(append([]byte{}, merkleLeafPrefix...), leaf.Bytes()...))
+	}
+	return level
+}
+
+// merkleParent returns the inner node hash of its children
+func merkleParent(left, right common.Hash) common.Hash {
+	data := make([]byte, 0, len(merkleNodePrefix)+2*common.HashLength)
+	data = append(data, merkleNodePrefix...)
+	data = append(data, left.Bytes()...)
+	data = append(data, right.Bytes()...)
+	return sha256.Sum256(data)
+}
+
+// MerkleRoot returns the root hash of binary Merkle tree of the leaves, which is empty if there are no leaves
+func MerkleRoot(leaves []common.Hash) common.Hash {
+	if len(leaves) == 0 {
+		return common.Hash{}
+	}
+
+	level := merkleLevel(leaves)
+	for len(level) > 1 {
+		for i := 0; i < len(level)/2; i++ {
+			level[i] = merkleParent(level[2*i], level[2*i+1])
+		}
+		level = level[:len(level)/2]
+	}
+
+	return level[0]
+}
+
+// MerkleBranch returns the sibling hashes of the leaf at index from the bottom of the tree up to its root
+func MerkleBranch(leaves []common.Hash, index int) ([]common.Hash, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("%w: leaf %d of %d", ErrInvalidProof, index, len(leaves))
+	}
+
+	var branch []common.Hash
+	level := merkleLevel(leaves)
+	for len(level) > 1 {
+		branch = append(branch, level[index^1])
+		for i := 0; i < len(level)/2; i++ {
+			level[i] = merkleParent(level[2*i], level[2*i+1])
+		}
+		level = level[:len(level)/2]
+		index /= 2
+	}
+
+	return branch, nil
+}
+
+// VerifyMerkleBranch checks the leaf at index is included into the tree of the root by its branch
+func VerifyMerkleBranch(root, leaf common.Hash, index int, branch []common.Hash) bool {
+	if len(branch) > maxMerkleDepth || index < 0 || index >= 1<<len(branch) {
+		return false
+	}
+
+	hash := merkleLevel([]common.Hash{leaf})[0]
+	for _, sibling := range branch {
+		if index%2 == 0 {
+			hash = merkleParent(hash, sibling)
+		} else {
+			hash = merkleParent(sibling, hash)
+		}
+		index /= 2
+	}
+
+	return hash == root
+}
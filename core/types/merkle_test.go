@@ -0,0 +1,93 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+// newTestLeaves returns n distinct leaves
+func newTestLeaves(n int) []common.Hash {
+	leaves := make([]common.Hash, n)
+	for i := range leaves {
+		leaves[i] = common.BigToHash(big.NewInt(int64(i + 1)))
+	}
+	return leaves
+}
+
+func TestMerkleBranch(t *testing.T) {
+	if root := MerkleRoot(nil); root != (common.Hash{}) {
+		t.Fatalf("expected empty root of no leaves, got %s", root)
+	}
+
+	// every leaf of the padded tree is proven by the branch of its depth
+	for size, depth := 1, 0; size <= 9; size++ {
+		if size > 1<<depth {
+			depth++
+		}
+
+		leaves := newTestLeaves(size)
+		root := MerkleRoot(leaves)
+		for i, leaf := range leaves {
+			branch, err := MerkleBranch(leaves, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(branch) != depth {
+				t.Fatalf("leaf %d of %d: expected branch of %d, got %d", i, size, depth, len(branch))
+			}
+			if !VerifyMerkleBranch(root, leaf, i, branch) {
+				t.Fatalf("leaf %d of %d is not verified", i, size)
+			}
+		}
+	}
+
+	leaves := newTestLeaves(5)
+	root := MerkleRoot(leaves)
+	branch, err := MerkleBranch(leaves, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MerkleBranch(leaves, len(leaves)); err == nil {
+		t.Fatal("branch of the missing leaf is returned")
+	}
+
+	// inner node is not a leaf, and padding is not a leaf of any index
+	inner, err := MerkleBranch(leaves, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerNode := merkleParent(merkleLevel(leaves[:1])[0], inner[0])
+
+	// the last padding node of the tree of 8 is the sibling of the empty padding node 6
+	last, err := MerkleBranch(leaves, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := []common.Hash{{}, merkleParent(merkleLevel(leaves[4:])[0], last[0]), last[2]}
+	if hash := merkleParent(last[2], merkleParent(padding[1], merkleParent(padding[0], common.Hash{}))); hash != root {
+		t.Fatalf("padding branch does not lead to the root")
+	}
+
+	tests := []struct {
+		name   string
+		leaf   common.Hash
+		index  int
+		branch []common.Hash
+	}{
+		{name: "other leaf", leaf: leaves[3], index: 2, branch: branch},
+		{name: "other index", leaf: leaves[2], index: 3, branch: branch},
+		{name: "index out of tree", leaf: leaves[2], index: 2 + 1<<len(branch), branch: branch},
+		{name: "negative index", leaf: leaves[2], index: -1, branch: branch},
+		{name: "truncated branch", leaf: leaves[2], index: 2, branch: branch[:len(branch)-1]},
+		{name: "inner node", leaf: innerNode, index: 0, branch: inner[1:]},
+		{name: "padding", leaf: common.Hash{}, index: 7, branch: padding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyMerkleBranch(root, tt.leaf, tt.index, tt.branch) {
+				t.Fatal("invalid branch is verified")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrInvalidProof = errors.New("invalid inclusion proof")
+)
+
+// BlockProof proves some of the block transactions and their receipts inclusion by their Merkle branches
+// of the block header TxHash and ReceiptHash trees, so it grows logarithmically with the block size
+type BlockProof struct {
+	BlockHash common.Hash `json:"block_hash" yaml:"block_hash"`
+	Txs       []*TxProof  `json:"txs" yaml:"txs"`
+}
+
+// TxProof is the branches of the block transaction and its receipt at the tx index
+type TxProof struct {
+	TxIndex       uint64        `json:"tx_index" yaml:"tx_index"`
+	TxBranch      []common.Hash `json:"tx_branch" yaml:"tx_branch"`
+	ReceiptBranch []common.Hash `json:"receipt_branch" yaml:"receipt_branch"`
+}
+
+// NewBlockProof returns inclusion proof of the block transactions at the indexes and their receipts
+func NewBlockProof(block *Block, receipts []*Receipt, indexes []int) (*BlockProof, error) {
+	if len(receipts) != len(block.Transactions) {
+		return nil, fmt.Errorf("%w: %d receipts of %d transactions", ErrInvalidProof, len(receipts), len(block.Transactions))
+	}
+
+	txHashes := make([]common.Hash, len(block.Transactions))
+	receiptHashes := make([]common.Hash, len(receipts))
+	for i, tx := range block.Transactions {
+		hash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+		txHashes[i] = common.BytesToHash(hash)
+
+		if receiptHashes[i], err = receipts[i].ConsensusHash(); err != nil {
+			return nil, err
+		}
+	}
+
+	proof := &BlockProof{
+		BlockHash: block.BlockHash,
+		Txs:       make([]*TxProof, len(indexes)),
+	}
+	for i, index := range indexes {
+		txBranch, err := MerkleBranch(txHashes, index)
+		if err != nil {
+			return nil, err
+		}
+		receiptBranch, err := MerkleBranch(receiptHashes, index)
+		if err != nil {
+			return nil, err
+		}
+
+		proof.Txs[i] = &TxProof{TxIndex: uint64(index), TxBranch: txBranch, ReceiptBranch: receiptBranch}
+	}
+
+	return proof, nil
+}
+
+// Verify checks the transactions and their receipts are the block header ones at the proven tx indexes,
+// which are in ascending order
+func (p *BlockProof) Verify(header *BlockHeader, txs []*SignedTx, receipts []*Receipt) error {
+	if p.BlockHash != header.BlockHash {
+		return fmt.Errorf("%w: block %s; expected: %s", ErrInvalidProof, p.BlockHash, header.BlockHash)
+	}
+	if len(txs) != len(p.Txs) || len(receipts) != len(p.Txs) {
+		return fmt.Errorf("%w: %d transactions and %d receipts of %d proofs", ErrInvalidProof, len(txs), len(receipts), len(p.Txs))
+	}
+
+	for i, txProof := range p.Txs {
+		if i > 0 && txProof.TxIndex <= p.Txs[i-1].TxIndex {
+			return fmt.Errorf("%w: tx index %d is not ascending", ErrInvalidProof, txProof.TxIndex)
+		}
+		if err := txProof.verify(header, txs[i], receipts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verify checks the transaction and its receipt branches lead to the block header roots
+func (p *TxProof) verify(header *BlockHeader, tx *SignedTx, receipt *Receipt) error {
+	if p.TxIndex >= 1<<maxMerkleDepth || receipt.TxIndex != int(p.TxIndex) {
+		return fmt.Errorf("%w: receipt tx index %d; expected: %d", ErrInvalidProof, receipt.TxIndex, p.TxIndex)
+	}
+
+	hash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+	txHash := common.BytesToHash(hash)
+	if receipt.TxHash != txHash || !VerifyMerkleBranch(header.TxHash, txHash, receipt.TxIndex, p.TxBranch) {
+		return fmt.Errorf("%w: tx %s is not included at %d", ErrInvalidProof, txHash, p.TxIndex)
+	}
+
+	if receipt.BlockHash != header.BlockHash {
+		return fmt.Errorf("%w: receipt block %s; expected: %s", ErrInvalidProof, receipt.BlockHash, header.BlockHash)
+	}
+
+	receiptHash, err := receipt.ConsensusHash()
+	if err != nil {
+		return err
+	}
+	if !VerifyMerkleBranch(header.ReceiptHash, receiptHash, receipt.TxIndex, p.ReceiptBranch) {
+		return fmt.Errorf("%w: receipt of tx %s is not included", ErrInvalidProof, txHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package types
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+// newTestProofBlock returns hashed block of n transfers with their receipts
+func newTestProofBlock(t *testing.T, n int) (*Block, []*Receipt) {
+	t.Helper()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewSigner(big.NewInt(1))
+
+	txs := make([]*SignedTx, n)
+	for i := range txs {
+		tx, err := NewTransaction(from, common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(10), uint64(i+1), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if txs[i], err = signer.Sign(tx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block := NewBlock(BlockHeader{Number: 1, NetherUsed: new(big.Int)}, txs)
+	txHash, err := block.HashTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.TxHash = common.BytesToHash(txHash)
+
+	receipts := make([]*Receipt, n)
+	for i, tx := range txs {
+		hash, err := tx.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipts[i] = &Receipt{
+			Addr:       from,
+			Balance:    big.NewInt(int64(1000 - i)),
+			NetherUsed: tx.Nether,
+			TxHash:     common.BytesToHash(hash),
+			TxIndex:    i,
+		}
+	}
+	if block.ReceiptHash, err = HashReceipts(receipts); err != nil {
+		t.Fatal(err)
+	}
+
+	blockHash, err := block.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.BlockHash = common.BytesToHash(blockHash)
+	for _, receipt := range receipts {
+		receipt.BlockHash = block.BlockHash
+		receipt.BlockNumber = block.Number
+	}
+
+	return block, receipts
+}
+
+func TestBlockProof(t *testing.T) {
+	block, receipts := newTestProofBlock(t, 7)
+	other, otherReceipts := newTestProofBlock(t, 7)
+
+	proof, err := NewBlockProof(block, receipts, []int{1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs, txReceipts := []*SignedTx{block.Transactions[1], block.Transactions[4]}, []*Receipt{receipts[1], receipts[4]}
+	if err := proof.Verify(&block.BlockHeader, txs, txReceipts); err != nil {
+		t.Fatal(err)
+	}
+
+	// proof size depends on the proven transactions, but not the whole block
+	for _, txProof := range proof.Txs {
+		if len(txProof.TxBranch) != 3 || len(txProof.ReceiptBranch) != 3 {
+			t.Fatalf("expected branches of 3, got %d and %d", len(txProof.TxBranch), len(txProof.ReceiptBranch))
+		}
+	}
+
+	forgedTx := *block.Transactions[4]
+	forgedTx.Value = big.NewInt(11)
+	forgedReceipt := *receipts[4]
+	forgedReceipt.Balance = big.NewInt(1)
+	movedReceipt := *receipts[4]
+	movedReceipt.TxIndex = 5
+
+	unordered, err := NewBlockProof(block, receipts, []int{4, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated, err := NewBlockProof(block, receipts, []int{1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated.Txs[1].TxBranch = truncated.Txs[1].TxBranch[1:]
+
+	tests := []struct {
+		name     string
+		proof    *BlockProof
+		header   *BlockHeader
+		txs      []*SignedTx
+		receipts []*Receipt
+	}{
+		{name: "other block header", proof: proof, header: &other.BlockHeader, txs: txs, receipts: txReceipts},
+		{name: "forged transaction", proof: proof, header: &block.BlockHeader, txs: []*SignedTx{txs[0], &forgedTx}, receipts: txReceipts},
+		{name: "forged receipt", proof: proof, header: &block.BlockHeader, txs: txs, receipts: []*Receipt{txReceipts[0], &forgedReceipt}},
+		{name: "receipt of other tx index", proof: proof, header: &block.BlockHeader, txs: txs, receipts: []*Receipt{txReceipts[0], &movedReceipt}},
+		{name: "other block receipt", proof: proof, header: &block.BlockHeader, txs: txs, receipts: []*Receipt{txReceipts[0], otherReceipts[4]}},
+		{name: "missing transaction", proof: proof, header: &block.BlockHeader, txs: txs[:1], receipts: txReceipts[:1]},
+		{
+			name:     "unordered transactions",
+			proof:    unordered,
+			header:   &block.BlockHeader,
+			txs:      []*SignedTx{txs[1], txs[0]},
+			receipts: []*Receipt{txReceipts[1], txReceipts[0]},
+		},
+		{name: "truncated branch", proof: truncated, header: &block.BlockHeader, txs: txs, receipts: txReceipts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.proof.Verify(tt.header, tt.txs, tt.receipts); !errors.Is(err, ErrInvalidProof) {
+				t.Fatalf("expected %s, got %v", ErrInvalidProof, err)
+			}
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package types
 
 import (
-	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
+	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/rlp"
+	"io"
+	"math/big"
 )
 
 // Receipt is an result of confirmed transaction as an event proof
@@ -12,11 +15,11 @@ type Receipt struct {
 	Addr            common.Address `json:"addr" yaml:"addr" yaml:"addr"`
 	Status          int32          `json:"status" yaml:"status"`
 	State           []byte         `json:"state" yaml:"state"`
-	Balance         uint64         `json:"balance" yaml:"balance" yaml:"balance"`
+	Balance         *big.Int       `json:"balance" yaml:"balance"`
 	ContractAddress common.Address `json:"contract_address" yaml:"contract_address"` // TBD
 
-	NetherUsed  uint64 `json:"nether_used" yaml:"nether_used"`
-	NetherPrice uint64 `json:"nether_price" yaml:"nether_price"`
+	NetherUsed  *big.Int `json:"nether_used" yaml:"nether_used"`
+	NetherPrice uint64   `json:"nether_price" yaml:"nether_price"`
 
 	BlockHash   common.Hash `json:"block_hash,omitempty" yaml:"block_hash"`
 	BlockNumber uint64      `json:"block_number,omitempty" yaml:"block_number"`
@@ -24,20 +27,124 @@ type Receipt struct {
 	TxIndex     int         `json:"tx_index" yaml:"tx_index"`
 }
 
-// Serialize serializes receipt
-func (r Receipt) Serialize() ([]byte, error) {
-	var buf bytes.Buffer
-	encoder := gob.NewEncoder(&buf)
-	if err := encoder.Encode(r); err != nil {
-		return nil, err
+// receiptJSON is Receipt JSON layout, big values are encoded as decimal strings
+type receiptJSON struct {
+	Addr            common.Address   `json:"addr"`
+	Status          int32            `json:"status"`
+	State           []byte           `json:"state"`
+	Balance         *math.Decimal256 `json:"balance"`
+	ContractAddress common.Address   `json:"contract_address"`
+	NetherUsed      *math.Decimal256 `json:"nether_used"`
+	NetherPrice     uint64           `json:"nether_price"`
+	BlockHash       common.Hash      `json:"block_hash,omitempty"`
+	BlockNumber     uint64           `json:"block_number,omitempty"`
+	TxHash          common.Hash      `json:"tx_hash"`
+	TxIndex         int              `json:"tx_index"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (r Receipt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(receiptJSON{
+		Addr:            r.Addr,
+		Status:          r.Status,
+		State:           r.State,
+		Balance:         (*math.Decimal256)(r.Balance),
+		ContractAddress: r.ContractAddress,
+		NetherUsed:      (*math.Decimal256)(r.NetherUsed),
+		NetherPrice:     r.NetherPrice,
+		BlockHash:       r.BlockHash,
+		BlockNumber:     r.BlockNumber,
+		TxHash:          r.TxHash,
+		TxIndex:         r.TxIndex,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *Receipt) UnmarshalJSON(input []byte) error {
+	var dec receiptJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	*r = Receipt{
+		Addr:            dec.Addr,
+		Status:          dec.Status,
+		State:           dec.State,
+		Balance:         (*big.Int)(dec.Balance),
+		ContractAddress: dec.ContractAddress,
+		NetherUsed:      (*big.Int)(dec.NetherUsed),
+		NetherPrice:     dec.NetherPrice,
+		BlockHash:       dec.BlockHash,
+		BlockNumber:     dec.BlockNumber,
+		TxHash:          dec.TxHash,
+		TxIndex:         dec.TxIndex,
+	}
+	return nil
+}
+
+// receiptRLP is Receipt canonical encoding layout
+type receiptRLP struct {
+	Addr            common.Address
+	Status          uint64
+	State           []byte
+	Balance         *big.Int
+	ContractAddress common.Address
+	NetherUsed      *big.Int
+	NetherPrice     uint64
+	BlockHash       common.Hash
+	BlockNumber     uint64
+	TxHash          common.Hash
+	TxIndex         uint64
+}
+
+// EncodeRLP implements rlp.Encoder
+func (r *Receipt) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, receiptRLP{
+		Addr:            r.Addr,
+		Status:          uint64(r.Status),
+		State:           r.State,
+		Balance:         r.Balance,
+		ContractAddress: r.ContractAddress,
+		NetherUsed:      r.NetherUsed,
+		NetherPrice:     r.NetherPrice,
+		BlockHash:       r.BlockHash,
+		BlockNumber:     r.BlockNumber,
+		TxHash:          r.TxHash,
+		TxIndex:         uint64(r.TxIndex),
+	})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
+	var dec receiptRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
+
+	*r = Receipt{
+		Addr:            dec.Addr,
+		Status:          int32(dec.Status),
+		State:           dec.State,
+		Balance:         dec.Balance,
+		ContractAddress: dec.ContractAddress,
+		NetherUsed:      dec.NetherUsed,
+		NetherPrice:     dec.NetherPrice,
+		BlockHash:       dec.BlockHash,
+		BlockNumber:     dec.BlockNumber,
+		TxHash:          dec.TxHash,
+		TxIndex:         int(dec.TxIndex),
+	}
+	return nil
+}
+
+// Serialize serializes receipt with canonical encoding
+func (r Receipt) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(&r)
 }
 
 // Deserialize deserializes binary data to receipt
 func (r *Receipt) Deserialize(d []byte) error {
-	decoder := gob.NewDecoder(bytes.NewReader(d))
-	return decoder.Decode(r)
+	return rlp.DecodeBytes(d, r)
 }
 
 func (r *Receipt) Hash() ([]byte, error) {
@@ -49,3 +156,33 @@ func (r *Receipt) Hash() ([]byte, error) {
 	hash := sha256.Sum256(data)
 	return hash[:], nil
 }
+
+// ConsensusHash returns a hash of the receipt execution results, which are committed to the block header.
+// Receipt block hash and number are not a part of it, as they are not known until the block is sealed
+func (r *Receipt) ConsensusHash() (common.Hash, error) {
+	receiptCopy := *r
+	receiptCopy.BlockHash = common.Hash{}
+	receiptCopy.BlockNumber = 0
+
+	hash, err := receiptCopy.Hash()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(hash), nil
+}
+
+// HashReceipts returns Merkle root of the block receipts consensus hashes, which is empty if there are no receipts,
+// so blocks without transactions keep their header encoding
+func HashReceipts(receipts []*Receipt) (common.Hash, error) {
+	hashes := make([]common.Hash, len(receipts))
+	for i, receipt := range receipts {
+		hash, err := receipt.ConsensusHash()
+		if err != nil {
+			return common.Hash{}, err
+		}
+		hashes[i] = hash
+	}
+
+	return MerkleRoot(hashes), nil
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrMissingRelayTx = errors.New("relayed transaction is missing")
+)
+
+// relayHashPrefix separates relay signatures from the other values signed by the node account key
+var relayHashPrefix = []byte("rbn relay:")
+
+// RelayedTx is the transaction sent to the peer, signed by the node account, which relays it.
+// Relay signature proves the account has sent the transaction, so it is blamed if the transaction is forged
+type RelayedTx struct {
+	Tx  *SignedTx `json:"tx" yaml:"tx"`
+	Sig []byte    `json:"sig" yaml:"sig"`
+}
+
+// NewRelayedTx signs the transaction relay with the node account key
+func NewRelayedTx(tx *SignedTx, key *ecdsa.PrivateKey) (*RelayedTx, error) {
+	r := &RelayedTx{Tx: tx}
+	hash, err := r.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	r.Sig, err = crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Hash returns the hash of the relayed transaction encoding, which is signed by the relaying account
+func (r *RelayedTx) Hash() (common.Hash, error) {
+	if r.Tx == nil {
+		return common.Hash{}, ErrMissingRelayTx
+	}
+
+	enc, err := r.Tx.Serialize()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return sha256.Sum256(append(append([]byte{}, relayHashPrefix...), enc...)), nil
+}
+
+// Relayer recovers address of the account, which has relayed the transaction
+func (r *RelayedTx) Relayer() (common.Address, error) {
+	hash, err := r.Hash()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pub, err := crypto.SigToPub(hash.Bytes(), r.Sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
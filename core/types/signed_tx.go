@@ -1,30 +1,84 @@
 package types
 
 import (
-	"crypto/elliptic"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/rlp"
+	"io"
 )
 
 type SignedTx struct {
 	Transaction
-	Sig []byte `json:"sig" yaml:"sig"`
+	Sig     []byte    `json:"sig" yaml:"sig"`
+	Signing TxSigning `json:"signing,omitempty" yaml:"signing,omitempty"` // legacy signing version of migrated transactions
 }
 
-func (t SignedTx) IsAuthentic() (bool, error) {
-	txHash, err := t.Transaction.Hash()
-	if err != nil {
-		return false, err
+// signedTxRLP is SignedTx canonical encoding layout, signing version is omitted for chain bound transactions
+type signedTxRLP struct {
+	Tx      *Transaction
+	Sig     []byte
+	Signing TxSigning `rlp:"optional"`
+}
+
+// EncodeRLP implements rlp.Encoder
+func (t *SignedTx) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, signedTxRLP{Tx: &t.Transaction, Sig: t.Sig, Signing: t.Signing})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (t *SignedTx) DecodeRLP(s *rlp.Stream) error {
+	var dec signedTxRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
 	}
 
-	recoveredPubKey, err := crypto.SigToPub(txHash, t.Sig)
+	t.Transaction = *dec.Tx
+	t.Sig = dec.Sig
+	t.Signing = dec.Signing
+	return nil
+}
+
+// signedTxJSON is SignedTx JSON layout, which keeps transaction fields flat
+type signedTxJSON struct {
+	txJSON
+	Sig     []byte    `json:"sig"`
+	Signing TxSigning `json:"signing,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (t SignedTx) MarshalJSON() ([]byte, error) {
+	return json.Marshal(signedTxJSON{txJSON: t.Transaction.toJSON(), Sig: t.Sig, Signing: t.Signing})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *SignedTx) UnmarshalJSON(input []byte) error {
+	var dec signedTxJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	t.Transaction.fromJSON(dec.txJSON)
+	t.Sig = dec.Sig
+	t.Signing = dec.Signing
+	return nil
+}
+
+// Serialize encodes SignedTx with its signature to canonical binary data
+func (t SignedTx) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(&t)
+}
+
+// Deserialize decodes binary data and returns valid SignedTx
+func (t *SignedTx) Deserialize(data []byte) error {
+	return rlp.DecodeBytes(data, t)
+}
+
+// IsAuthentic checks transaction is signed for the signer chain by its sender,
+// or with its legacy signing version if it was migrated from a legacy chain database
+func (t SignedTx) IsAuthentic(signer Signer) (bool, error) {
+	sender, err := signer.Sender(&t)
 	if err != nil {
 		return false, err
 	}
 
-	recoveredPubKeyBytes := elliptic.Marshal(crypto.S256(), recoveredPubKey.X, recoveredPubKey.Y)
-	recoveredPubKeyBytesHash := crypto.Keccak256(recoveredPubKeyBytes[1:])
-	recoveredAccount := common.BytesToAddress(recoveredPubKeyBytesHash[12:])
-
-	return recoveredAccount.Hex() == t.Transaction.From.Hex(), nil
+	return sender == t.From, nil
 }
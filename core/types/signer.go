@@ -0,0 +1,106 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+var (
+	ErrInvalidChainId = errors.New("invalid transaction chain id")
+)
+
+// Signer produces and verifies transaction signatures for a single chain
+type Signer interface {
+	// ChainId returns chain id transactions are bound to
+	ChainId() *big.Int
+	// Hash returns transaction hash to be signed
+	Hash(tx *Transaction) (common.Hash, error)
+	// Sign binds transaction to the signer chain and signs it with provided key
+	Sign(tx Transaction, key *ecdsa.PrivateKey) (*SignedTx, error)
+	// Sender recovers transaction signer address, legacy transactions are recovered
+	// from the hash of their signing version encoding
+	Sender(tx *SignedTx) (common.Address, error)
+}
+
+// chainSigner signs sha256 hash of transaction canonical encoding, which includes chain id
+type chainSigner struct {
+	chainId *big.Int
+}
+
+// NewSigner returns transaction Signer for specified chain
+func NewSigner(chainId *big.Int) Signer {
+	s := chainSigner{chainId: new(big.Int)}
+	if chainId != nil {
+		s.chainId.Set(chainId)
+	}
+	return s
+}
+
+func (s chainSigner) ChainId() *big.Int {
+	return new(big.Int).Set(s.chainId)
+}
+
+func (s chainSigner) Hash(tx *Transaction) (common.Hash, error) {
+	if tx.ChainId == nil || tx.ChainId.Cmp(s.chainId) != 0 {
+		return common.Hash{}, fmt.Errorf("%w: %s; expected: %s", ErrInvalidChainId, tx.ChainId, s.chainId)
+	}
+
+	data, err := tx.Serialize()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return sha256.Sum256(data), nil
+}
+
+func (s chainSigner) Sign(tx Transaction, key *ecdsa.PrivateKey) (*SignedTx, error) {
+	if s.chainId.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidChainId, s.chainId)
+	}
+	tx.ChainId = s.ChainId()
+
+	hash, err := s.Hash(&tx)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedTx{Transaction: tx, Sig: sig}, nil
+}
+
+func (s chainSigner) Sender(tx *SignedTx) (common.Address, error) {
+	hash, err := s.signingHash(tx)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pubKey, err := crypto.SigToPub(hash.Bytes(), tx.Sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// signingHash returns the hash signed by transaction sender. Legacy transactions are bound to chain
+// on database migration, but their signatures cover legacy encoding without chain id
+func (s chainSigner) signingHash(tx *SignedTx) (common.Hash, error) {
+	if tx.Signing == SigningChain {
+		return s.Hash(&tx.Transaction)
+	}
+
+	if tx.ChainId == nil || tx.ChainId.Cmp(s.chainId) != 0 {
+		return common.Hash{}, fmt.Errorf("%w: %s; expected: %s", ErrInvalidChainId, tx.ChainId, s.chainId)
+	}
+
+	return tx.Transaction.legacyHash(tx.Signing)
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+func TestSignerChainId(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewSigner(big.NewInt(1))
+
+	tx, err := NewTransaction(from, common.HexToAddress("0x55"), big.NewInt(10), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx, err := signer.Sign(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedTx.ChainId.Cmp(signer.ChainId()) != 0 {
+		t.Fatalf("expected chain id %s, got %s", signer.ChainId(), signedTx.ChainId)
+	}
+	if ok, err := signedTx.IsAuthentic(signer); err != nil || !ok {
+		t.Fatalf("signed tx is not authentic: %v", err)
+	}
+
+	// transaction signed for other chain is not replayed
+	otherTx, err := NewSigner(big.NewInt(2)).Sign(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherTx.IsAuthentic(signer); !errors.Is(err, ErrInvalidChainId) {
+		t.Fatalf("expected %s, got %v", ErrInvalidChainId, err)
+	}
+
+	// chain id is covered by the signature
+	forged := *otherTx
+	forged.ChainId = signer.ChainId()
+	if ok, err := forged.IsAuthentic(signer); err != nil || ok {
+		t.Fatalf("tx with replaced chain id is authentic: %v", err)
+	}
+
+	unbound := *signedTx
+	unbound.ChainId = nil
+	if _, err := unbound.IsAuthentic(signer); !errors.Is(err, ErrInvalidChainId) {
+		t.Fatalf("expected %s, got %v", ErrInvalidChainId, err)
+	}
+
+	if _, err := NewSigner(nil).Sign(tx, key); !errors.Is(err, ErrInvalidChainId) {
+		t.Fatalf("expected %s, got %v", ErrInvalidChainId, err)
+	}
+}
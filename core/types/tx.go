@@ -3,10 +3,14 @@ package types
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/rovergulf/chain/params"
+	"io"
+	"math/big"
 	"time"
 )
 
@@ -17,21 +21,26 @@ var (
 type Transactions []Transaction
 
 // NewTransaction creates a new transaction
-func NewTransaction(from, to common.Address, amount uint64, nonce uint64, data []byte) (Transaction, error) {
-	if from == to {
+func NewTransaction(from, to common.Address, amount *big.Int, nonce uint64, data []byte) (Transaction, error) {
+	if amount == nil || amount.Sign() < 0 {
+		return Transaction{}, fmt.Errorf("transaction amount must not be negative")
+	}
+
+	// zero value transaction to yourself is used to cancel pending transaction with the same nonce
+	if from == to && amount.Sign() != 0 {
 		return Transaction{}, fmt.Errorf("transaction cannot be sent to yourself")
 	}
 
-	percentile := params.Raftel / (params.TxPrice * params.NetherPrice)
-	nether := amount / percentile
-	if nether < params.NetherLimit {
-		nether = params.NetherLimit
+	percentile := new(big.Int).SetUint64(params.Raftel / (params.TxPrice * params.NetherPrice))
+	nether := new(big.Int).Quo(amount, percentile)
+	if netherLimit := new(big.Int).SetUint64(params.NetherLimit); nether.Cmp(netherLimit) < 0 {
+		nether = netherLimit
 	}
 
 	return Transaction{
 		From:        from,
 		To:          to,
-		Value:       amount,
+		Value:       new(big.Int).Set(amount),
 		Nonce:       nonce,
 		Nether:      nether,
 		NetherPrice: params.NetherPrice,
@@ -42,11 +51,12 @@ func NewTransaction(from, to common.Address, amount uint64, nonce uint64, data [
 
 // Transaction represents a Bitcoin transaction
 type Transaction struct {
+	ChainId     *big.Int       `json:"chain_id" yaml:"chain_id"` // set on signing, protects from replay on other chains
 	From        common.Address `json:"from" yaml:"from"`
 	To          common.Address `json:"to" yaml:"to"` // destination of contract, use empty address for contract creation
 	Nonce       uint64         `json:"nonce" yaml:"nonce"`
-	Value       uint64         `json:"value" yaml:"value"`
-	Nether      uint64         `json:"nether" yaml:"nether"`
+	Value       *big.Int       `json:"value" yaml:"value"`
+	Nether      *big.Int       `json:"nether" yaml:"nether"`
 	NetherPrice uint64         `json:"nether_price" yaml:"nether_price"`
 	Data        []byte         `json:"data" yaml:"data"` // contract data
 	Time        int64          `json:"time" yaml:"time"`
@@ -56,6 +66,63 @@ type Transaction struct {
 	//V []byte
 }
 
+// txJSON is Transaction JSON layout, big values are encoded as decimal strings
+type txJSON struct {
+	ChainId     *math.Decimal256 `json:"chain_id"`
+	From        common.Address   `json:"from"`
+	To          common.Address   `json:"to"`
+	Nonce       uint64           `json:"nonce"`
+	Value       *math.Decimal256 `json:"value"`
+	Nether      *math.Decimal256 `json:"nether"`
+	NetherPrice uint64           `json:"nether_price"`
+	Data        []byte           `json:"data"`
+	Time        int64            `json:"time"`
+}
+
+func (tx *Transaction) toJSON() txJSON {
+	return txJSON{
+		ChainId:     (*math.Decimal256)(tx.ChainId),
+		From:        tx.From,
+		To:          tx.To,
+		Nonce:       tx.Nonce,
+		Value:       (*math.Decimal256)(tx.Value),
+		Nether:      (*math.Decimal256)(tx.Nether),
+		NetherPrice: tx.NetherPrice,
+		Data:        tx.Data,
+		Time:        tx.Time,
+	}
+}
+
+func (tx *Transaction) fromJSON(dec txJSON) {
+	*tx = Transaction{
+		ChainId:     (*big.Int)(dec.ChainId),
+		From:        dec.From,
+		To:          dec.To,
+		Nonce:       dec.Nonce,
+		Value:       (*big.Int)(dec.Value),
+		Nether:      (*big.Int)(dec.Nether),
+		NetherPrice: dec.NetherPrice,
+		Data:        dec.Data,
+		Time:        dec.Time,
+	}
+}
+
+// MarshalJSON implements json.Marshaler
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tx.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (tx *Transaction) UnmarshalJSON(input []byte) error {
+	var dec txJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	tx.fromJSON(dec)
+	return nil
+}
+
 // Hash returns a hash of the transaction
 func (tx *Transaction) Hash() ([]byte, error) {
 	txCopy := *tx
@@ -69,26 +136,81 @@ func (tx *Transaction) Hash() ([]byte, error) {
 	return hash[:], nil
 }
 
-// Serialize encodes Transaction to binary data
-func (tx Transaction) Serialize() ([]byte, error) {
-	var encoded bytes.Buffer
+// txRLP is Transaction canonical encoding layout
+type txRLP struct {
+	ChainId     *big.Int
+	From        common.Address
+	To          common.Address
+	Nonce       uint64
+	Value       *big.Int
+	Nether      *big.Int
+	NetherPrice uint64
+	Data        []byte
+	Time        uint64
+}
 
-	enc := gob.NewEncoder(&encoded)
-	if err := enc.Encode(tx); err != nil {
-		return nil, err
+// EncodeRLP implements rlp.Encoder
+func (tx *Transaction) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, txRLP{
+		ChainId:     tx.ChainId,
+		From:        tx.From,
+		To:          tx.To,
+		Nonce:       tx.Nonce,
+		Value:       tx.Value,
+		Nether:      tx.Nether,
+		NetherPrice: tx.NetherPrice,
+		Data:        tx.Data,
+		Time:        uint64(tx.Time),
+	})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
+	var dec txRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+
+	*tx = Transaction{
+		ChainId:     dec.ChainId,
+		From:        dec.From,
+		To:          dec.To,
+		Nonce:       dec.Nonce,
+		Value:       dec.Value,
+		Nether:      dec.Nether,
+		NetherPrice: dec.NetherPrice,
+		Data:        dec.Data,
+		Time:        int64(dec.Time),
 	}
+	return nil
+}
 
-	return encoded.Bytes(), nil
+// Serialize encodes Transaction to canonical binary data
+func (tx Transaction) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(&tx)
 }
 
 // Deserialize decodes binary data and returns valid Transaction
 func (tx *Transaction) Deserialize(data []byte) error {
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	return decoder.Decode(tx)
+	return rlp.DecodeBytes(data, tx)
 }
 
-func (tx *Transaction) Cost() uint64 {
-	return tx.Value + tx.Nether
+// Fee returns transaction nether fee, which is nether limit charged by its price
+func (tx *Transaction) Fee() *big.Int {
+	fee := new(big.Int)
+	if tx.Nether != nil {
+		fee.Mul(tx.Nether, new(big.Int).SetUint64(tx.NetherPrice))
+	}
+	return fee
+}
+
+// Cost returns transaction value with its nether fee
+func (tx *Transaction) Cost() *big.Int {
+	cost := tx.Fee()
+	if tx.Value != nil {
+		cost.Add(cost, tx.Value)
+	}
+	return cost
 }
 
 func (tx *Transaction) AppendData(data []byte) {
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"io"
+)
+
+const (
+	TxStatusPending = "pending"
+	TxStatusMined   = "mined"
+)
+
+// TxLookupEntry represents mined transaction location in the chain
+type TxLookupEntry struct {
+	BlockHash   common.Hash `json:"block_hash" yaml:"block_hash"`
+	BlockNumber uint64      `json:"block_number" yaml:"block_number"`
+	TxIndex     int         `json:"tx_index" yaml:"tx_index"`
+}
+
+// txLookupRLP is TxLookupEntry canonical encoding layout
+type txLookupRLP struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	TxIndex     uint64
+}
+
+// EncodeRLP implements rlp.Encoder
+func (e *TxLookupEntry) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, txLookupRLP{
+		BlockHash:   e.BlockHash,
+		BlockNumber: e.BlockNumber,
+		TxIndex:     uint64(e.TxIndex),
+	})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (e *TxLookupEntry) DecodeRLP(s *rlp.Stream) error {
+	var dec txLookupRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+
+	*e = TxLookupEntry{
+		BlockHash:   dec.BlockHash,
+		BlockNumber: dec.BlockNumber,
+		TxIndex:     int(dec.TxIndex),
+	}
+	return nil
+}
+
+// Serialize serializes tx lookup entry with canonical encoding
+func (e TxLookupEntry) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(&e)
+}
+
+// Deserialize deserializes binary data to tx lookup entry
+func (e *TxLookupEntry) Deserialize(d []byte) error {
+	return rlp.DecodeBytes(d, e)
+}
@@ -4,22 +4,21 @@ import "time"
 
 // TxByPriceAndTime implements both the sort and the heap interface, making it useful
 // for all at once sorting as well as individually adding and removing elements.
-type TxByPriceAndTime []*Transaction
+type TxByPriceAndTime []*SignedTx
 
 func (txs TxByPriceAndTime) Len() int { return len(txs) }
 func (txs TxByPriceAndTime) Less(i, j int) bool {
 	// If the prices are equal, use the time the transaction was first seen for
 	// deterministic sorting
-	cmp := txs[i].Cost() - txs[j].Cost()
-	if cmp == 0 {
+	if txs[i].NetherPrice == txs[j].NetherPrice {
 		return time.Unix(txs[i].Time, 0).Before(time.Unix(txs[j].Time, 0))
 	}
-	return cmp > 0
+	return txs[i].NetherPrice > txs[j].NetherPrice
 }
 func (txs TxByPriceAndTime) Swap(i, j int) { txs[i], txs[j] = txs[j], txs[i] }
 
 func (txs *TxByPriceAndTime) Push(x interface{}) {
-	*txs = append(*txs, x.(*Transaction))
+	*txs = append(*txs, x.(*SignedTx))
 }
 
 func (txs *TxByPriceAndTime) Pop() interface{} {
@@ -29,3 +28,10 @@ func (txs *TxByPriceAndTime) Pop() interface{} {
 	*txs = old[0 : n-1]
 	return x
 }
+
+// TxByNonce implements the sort interface to keep sender transactions nonce sequence
+type TxByNonce []*SignedTx
+
+func (txs TxByNonce) Len() int           { return len(txs) }
+func (txs TxByNonce) Less(i, j int) bool { return txs[i].Nonce < txs[j].Nonce }
+func (txs TxByNonce) Swap(i, j int)      { txs[i], txs[j] = txs[j], txs[i] }
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/params"
 	"math/big"
 	"reflect"
 	"regexp"
@@ -33,18 +35,60 @@ var (
 	ErrInvalidRewardData    = errors.New("invalid reward tx data")
 	ErrReceiptNotExists     = errors.New("receipt does not exists")
 	ErrReceiptAlreadyExists = errors.New("receipt already exists")
+	ErrInvalidStateRoot     = errors.New("invalid state root")
+	ErrStateUndoNotExists   = errors.New("state undo journal does not exists")
+	ErrUnknownParent        = errors.New("unknown parent block")
+	ErrReorgTooDeep         = errors.New("chain reorganization is too deep")
+	ErrFinalFork            = errors.New("block forks finalized chain")
+	ErrDatabaseVersion      = errors.New("unsupported database version")
+	ErrBalanceOverflow      = errors.New("balance overflows 256 bits")
+	ErrInvalidEvidence      = errors.New("invalid misbehavior evidence")
+	ErrEvidenceIncluded     = errors.New("evidence is already included")
 )
 
+// block validation errors, so the peer sent invalid block may be penalized
 var (
+	ErrInvalidPrevHash     = errors.New("invalid previous block hash")
+	ErrInvalidBlockNumber  = errors.New("invalid block number")
+	ErrInvalidBlockHash    = errors.New("invalid block hash")
+	ErrInvalidTxHash       = errors.New("invalid transactions hash")
+	ErrInvalidReceiptHash  = errors.New("invalid receipts hash")
+	ErrInvalidBloom        = errors.New("invalid block addresses bloom")
+	ErrInvalidTimestamp    = errors.New("block timestamp is older than its parent")
+	ErrFutureBlock         = errors.New("block timestamp is too far in the future")
+	ErrInvalidTxSignature  = errors.New("invalid transaction signature")
+	ErrInvalidTxNonce      = errors.New("invalid transaction nonce")
+	ErrInsufficientBalance = errors.New("insufficient sender balance")
+	ErrInvalidTxValue      = errors.New("invalid transaction value")
+	ErrInvalidNetherUsed   = errors.New("invalid block nether used")
+	ErrInvalidRewardTxs    = errors.New("invalid block reward transactions")
+	ErrInvalidBlockSeal    = errors.New("invalid block consensus seal")
+	ErrInvalidEvidenceHash = errors.New("invalid block evidence hash")
+	ErrTooMuchEvidence     = errors.New("too much block evidence")
+	ErrInvalidPunished     = errors.New("invalid block punished accounts")
+)
+
+var (
+	databaseVersionKey = []byte("dbVersion")
 	lastHashKey        = []byte("lh")
+	headHeaderKey      = []byte("hh")
+	finalizedKey       = []byte("fin")
 	genesisKey         = []byte("gen")
 	genesisBlockKey    = []byte("root")
 	blocksPrefix       = []byte("blocks/")
 	blockNumbersPrefix = []byte("blockNums/")
 	blockHeadersPrefix = []byte("headers/")
+	headerNumsPrefix   = []byte("headerNums/")
 	balancesPrefix     = []byte("balances/")
 	txsPrefix          = []byte("txs/")
+	txLookupPrefix     = []byte("txLookup/")
+	addressTxsPrefix   = []byte("addrTxs/")
+	totalWeightPrefix  = []byte("td/")
+	stateUndoPrefix    = []byte("undo/")
 	receiptsPrefix     = []byte("receipts/")
+	receiptListsPrefix = []byte("blockReceipts/")
+	evidencePrefix     = []byte("evidence/")
+	accountSyncPrefix  = []byte("accountSync/")
 )
 
 func blockDbPrefix(hash common.Hash) []byte {
@@ -61,6 +105,11 @@ func blockHeaderDbPrefix(hash common.Hash) []byte {
 	return append(blockHeadersPrefix, hash.Bytes()...)
 }
 
+func headerNumDbPrefix(number uint64) []byte {
+	numStr := strconv.FormatUint(number, 10)
+	return append(headerNumsPrefix, []byte(numStr)...)
+}
+
 func balanceDbPrefix(addr common.Address) []byte {
 	return append(balancesPrefix, addr.Bytes()...)
 }
@@ -69,10 +118,38 @@ func txDbPrefix(hash common.Hash) []byte {
 	return append(txsPrefix, hash.Bytes()...)
 }
 
+func txLookupDbPrefix(hash common.Hash) []byte {
+	return append(txLookupPrefix, hash.Bytes()...)
+}
+
+func addressTxsDbPrefix(addr common.Address) []byte {
+	return append(addressTxsPrefix, addr.Bytes()...)
+}
+
+func totalWeightDbPrefix(hash common.Hash) []byte {
+	return append(totalWeightPrefix, hash.Bytes()...)
+}
+
+func stateUndoDbPrefix(hash common.Hash) []byte {
+	return append(stateUndoPrefix, hash.Bytes()...)
+}
+
 func receiptDbPrefix(hash common.Hash) []byte {
 	return append(receiptsPrefix, hash.Bytes()...)
 }
 
+func blockReceiptsDbPrefix(hash common.Hash) []byte {
+	return append(receiptListsPrefix, hash.Bytes()...)
+}
+
+func evidenceDbPrefix(hash common.Hash) []byte {
+	return append(evidencePrefix, hash.Bytes()...)
+}
+
+func accountSyncDbPrefix(addr common.Address) []byte {
+	return append(accountSyncPrefix, addr.Bytes()...)
+}
+
 func IsHashEmpty(hash common.Hash) bool {
 	return bytes.Compare(hash.Bytes(), emptyHash.Bytes()) == 0
 }
@@ -113,6 +190,21 @@ func CalcGasCost(gasLimit uint64, gasPrice *big.Int) *big.Int {
 	return gasLimitBig.Mul(gasLimitBig, gasPrice)
 }
 
+// ParseCoinAmount parses decimal coins amount, like "1.5", to Nether value
+func ParseCoinAmount(amount string) (*big.Int, error) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid coins amount: %q", amount)
+	}
+
+	value.Mul(value, new(big.Rat).SetInt(new(big.Int).SetUint64(params.Raftel)))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("coins amount %q is more precise than Nether", amount)
+	}
+
+	return new(big.Int).Set(value.Num()), nil
+}
+
 // SigRSV signatures R S V returned as arrays
 func SigRSV(isig interface{}) ([32]byte, [32]byte, uint8) {
 	var sig []byte
@@ -1,9 +1,7 @@
 package node
 
 import (
-	"context"
 	"github.com/dgraph-io/badger/v3"
-	"github.com/rovergulf/chain/core"
 	"github.com/rovergulf/chain/wallets"
 )
 
@@ -74,11 +72,6 @@ func (n *Node) setupNodeAccount() error {
 				return err
 			}
 
-			if _, err := n.bc.NewBalance(newWallet.Address(), 0); err != nil {
-				n.logger.Errorf("Unable to create node balance")
-				return err
-			}
-
 			if err := n.saveNodeAccount(newWallet); err != nil {
 				n.logger.Errorf("Unable to save node account to node storage: %s", err)
 				return err
@@ -93,26 +86,3 @@ func (n *Node) setupNodeAccount() error {
 	n.account = w
 	return nil
 }
-
-func (n *Node) syncKeystoreBalances(ctx context.Context) error {
-	accountsList, err := n.wm.GetAllAddresses()
-	if err != nil {
-		return err
-	}
-
-	for i := range accountsList {
-		account := accountsList[i]
-
-		if _, err := n.bc.GetBalance(account); err != nil {
-			if err == core.ErrBalanceNotExists {
-				if _, err := n.bc.NewBalance(account, 0); err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
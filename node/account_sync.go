@@ -0,0 +1,244 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+)
+
+var (
+	ErrInvalidAddressTxs = errors.New("peer sent invalid address transactions")
+	ErrNoAddressTxsPeer  = errors.New("no peer serves address transactions")
+)
+
+// syncAccounts downloads transactions of the node wallets accounts from the synced header chain blocks.
+// Every block is queried by the accounts filter, and its transactions and receipts inclusion is verified
+// by the proof against the stored header, so block bodies are not downloaded
+func (n *Node) syncAccounts(ctx context.Context) error {
+	addrs, err := n.wm.GetAllAddresses()
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	head, err := n.bc.CurrentHeader()
+	if err != nil {
+		return err
+	}
+
+	from, err := n.accountsSyncStart(addrs, head)
+	if err != nil {
+		return err
+	}
+
+	for from <= head.Number {
+		last, err := n.fetchAddressTxs(ctx, addrs, from, head.Number)
+		if err != nil {
+			return err
+		}
+
+		header, err := n.bc.GetHeaderByNumber(last)
+		if err != nil {
+			return err
+		}
+		if err := n.bc.SetAccountSyncHead(addrs, header); err != nil {
+			return err
+		}
+
+		from = last + 1
+	}
+
+	return nil
+}
+
+// accountsSyncStart returns the lowest block number, which transactions are not synced for any of accounts.
+// Account synced up to the block, which is not in the header chain anymore, is synced again
+// from params.MaxReorgDepth blocks before it
+func (n *Node) accountsSyncStart(addrs []common.Address, head *types.BlockHeader) (uint64, error) {
+	start := head.Number + 1
+	for _, addr := range addrs {
+		number, hash, err := n.bc.AccountSyncHead(addr)
+		if err != nil {
+			return 0, err
+		}
+
+		if number > 0 {
+			header, err := n.bc.GetHeaderByNumber(number)
+			if err != nil || header.BlockHash != hash {
+				if number > params.MaxReorgDepth {
+					number -= params.MaxReorgDepth
+				} else {
+					number = 0
+				}
+			}
+		}
+
+		if number+1 < start {
+			start = number + 1
+		}
+	}
+
+	return start, nil
+}
+
+// fetchAddressTxs queries the peers for the accounts transactions of the blocks range, until one of them
+// serves at least some of it. Returns the last block number, which transactions are stored up to
+func (n *Node) fetchAddressTxs(ctx context.Context, addrs []common.Address, from, to uint64) (uint64, error) {
+	for _, p := range n.peers.List() {
+		if status := p.Status(); status == nil || status.Number < from {
+			continue
+		}
+
+		data, err := n.requestAddressTxs(ctx, p, &GetAddressTxsData{
+			Addresses: addrs,
+			From:      from,
+			To:        to,
+		})
+		if err != nil {
+			n.logger.Debugw("Unable to query address transactions", "peer", p.id, "err", err)
+			continue
+		}
+
+		// header-only peer has no blocks of the range
+		if data.Last < from {
+			continue
+		}
+
+		if err := n.insertAddressTxs(addrs, from, data); err != nil {
+			return 0, fmt.Errorf("%w; peer: %s", err, p.id)
+		}
+
+		return data.Last, nil
+	}
+
+	return 0, fmt.Errorf("%w: blocks %d-%d", ErrNoAddressTxsPeer, from, to)
+}
+
+// insertAddressTxs checks the reply has every header chain block within the served range, which header bloom
+// matches any of the accounts, and stores their accounts transactions, once their inclusion proof is verified.
+// Bloom matches are checked by the block, so peer may not omit blocks, but it is not checked whether
+// all of the accounts transactions of the served block are included
+func (n *Node) insertAddressTxs(addrs []common.Address, from uint64, data *AddressTxsData) error {
+	addresses := make(map[common.Address]bool, len(addrs))
+	for _, addr := range addrs {
+		addresses[addr] = true
+	}
+
+	blocks := data.Blocks
+	for number := from; number <= data.Last; number++ {
+		header, err := n.bc.GetHeaderByNumber(number)
+		if err != nil {
+			return err
+		}
+		if !header.Bloom.TestAny(addrs) {
+			continue
+		}
+
+		if len(blocks) == 0 || blocks[0].BlockHash != header.BlockHash {
+			return fmt.Errorf("%w: block %d matching the accounts is omitted", ErrInvalidAddressTxs, number)
+		}
+		if err := n.insertAddressBlock(addresses, header, blocks[0]); err != nil {
+			return err
+		}
+		blocks = blocks[1:]
+	}
+
+	if len(blocks) > 0 {
+		return fmt.Errorf("%w: block %s does not match the accounts", ErrInvalidAddressTxs, blocks[0].BlockHash)
+	}
+
+	return nil
+}
+
+// insertAddressBlock stores the accounts transactions of the header chain block. Block without them
+// must have the whole body, which proves the header bloom match is false positive
+func (n *Node) insertAddressBlock(addresses map[common.Address]bool, header *types.BlockHeader, block *AddressBlock) error {
+	if len(block.Transactions) == 0 {
+		if block.Proof != nil || len(block.Receipts) > 0 {
+			return fmt.Errorf("%w: incomplete block %d", ErrInvalidAddressTxs, header.Number)
+		}
+
+		txHash, err := types.NewBlock(*header, block.Body).HashTransactions()
+		if err != nil {
+			return err
+		}
+		if common.BytesToHash(txHash) != header.TxHash {
+			return fmt.Errorf("%w: block %d body does not match its header", ErrInvalidAddressTxs, header.Number)
+		}
+		for _, tx := range block.Body {
+			if addresses[tx.To] || (!tx.IsReward() && addresses[tx.From]) {
+				return fmt.Errorf("%w: tx of block %d is omitted", ErrInvalidAddressTxs, header.Number)
+			}
+		}
+		return nil
+	}
+
+	if block.Proof == nil || len(block.Body) > 0 || len(block.Transactions) != len(block.Receipts) {
+		return fmt.Errorf("%w: incomplete block %d", ErrInvalidAddressTxs, header.Number)
+	}
+	if block.Proof.BlockHash != header.BlockHash {
+		return fmt.Errorf("%w: proof of block %s; expected: %s", ErrInvalidAddressTxs, block.Proof.BlockHash, header.BlockHash)
+	}
+
+	for i, tx := range block.Transactions {
+		if !addresses[tx.To] && (tx.IsReward() || !addresses[tx.From]) {
+			return fmt.Errorf("%w: tx of block %d is not queried", ErrInvalidAddressTxs, header.Number)
+		}
+		if block.Receipts[i].BlockNumber != header.Number {
+			return fmt.Errorf("%w: receipt of block %d", ErrInvalidAddressTxs, block.Receipts[i].BlockNumber)
+		}
+	}
+
+	if err := n.bc.InsertProvenTxs(block.Proof, block.Transactions, block.Receipts); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAddressTxs, err)
+	}
+
+	return nil
+}
+
+// requestAddressTxs sends the address transactions query to the peer and waits for its reply
+func (n *Node) requestAddressTxs(ctx context.Context, p *Peer, req *GetAddressTxsData) (*AddressTxsData, error) {
+	res, err := n.sendRequest(ctx, p, GetAddressTxsMsg, func(id uint64) interface{} {
+		req.RequestId = id
+		return req
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := res.(*AddressTxsData)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected reply", ErrInvalidAddressTxs)
+	}
+	if data.Last > req.To {
+		return nil, fmt.Errorf("%w: served up to %d of %d", ErrInvalidAddressTxs, data.Last, req.To)
+	}
+
+	return data, nil
+}
+
+// accountsSyncNumber returns the lowest block number, which the node wallets accounts transactions are synced up to
+func (n *Node) accountsSyncNumber() (uint64, error) {
+	addrs, err := n.wm.GetAllAddresses()
+	if err != nil {
+		return 0, err
+	}
+
+	var lowest uint64
+	for i, addr := range addrs {
+		number, _, err := n.bc.AccountSyncHead(addr)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 || number < lowest {
+			lowest = number
+		}
+	}
+
+	return lowest, nil
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"testing"
+)
+
+func TestInsertAddressTxs(t *testing.T) {
+	ctx := context.Background()
+
+	producer := newTestNode(t, nil, 0)
+	signer := producer.account.Address()
+	for i := 0; i < 3; i++ {
+		if _, err := producer.generateBlock(ctx, producerConfig{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := newTestNode(t, nil, 0, signer)
+	connectTestNodes(t, producer, n)
+	if err := n.synchronise(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// every block rewards the signer, so it matches every header bloom
+	addrs := []common.Address{signer}
+	blocks := make([]*AddressBlock, 3)
+	bodies := make([]*AddressBlock, 3)
+	for i := range blocks {
+		header, err := producer.bc.GetHeaderByNumber(uint64(i + 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := producer.bc.GetBlock(header.BlockHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blocks[i], err = producer.addressBlock(&block, map[common.Address]bool{signer: true}); err != nil {
+			t.Fatal(err)
+		}
+		bodies[i] = &AddressBlock{BlockHash: block.BlockHash, Body: block.Transactions}
+	}
+
+	otherKey, _ := crypto.GenerateKey()
+	other := crypto.PubkeyToAddress(otherKey.PublicKey)
+	otherProof := *blocks[0]
+	otherProof.Proof = blocks[1].Proof
+	doubled := append(append([]*types.SignedTx{}, bodies[0].Body...), bodies[0].Body...)
+
+	tests := []struct {
+		name   string
+		addrs  []common.Address
+		blocks []*AddressBlock
+	}{
+		{name: "omitted matching block", addrs: addrs, blocks: []*AddressBlock{blocks[0], blocks[2]}},
+		{name: "block not matching the accounts", addrs: []common.Address{other}, blocks: blocks[:1]},
+		{name: "body omits account transaction", addrs: addrs, blocks: []*AddressBlock{bodies[0], blocks[1], blocks[2]}},
+		{name: "empty body", addrs: addrs, blocks: []*AddressBlock{{BlockHash: blocks[0].BlockHash}, blocks[1], blocks[2]}},
+		{name: "body not matching the header", addrs: addrs, blocks: []*AddressBlock{{BlockHash: blocks[0].BlockHash, Body: doubled}, blocks[1], blocks[2]}},
+		{name: "proof of other block", addrs: addrs, blocks: []*AddressBlock{&otherProof, blocks[1], blocks[2]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.insertAddressTxs(tt.addrs, 1, &AddressTxsData{Blocks: tt.blocks, Last: 3})
+			if !errors.Is(err, ErrInvalidAddressTxs) {
+				t.Fatalf("expected %s, got %v", ErrInvalidAddressTxs, err)
+			}
+		})
+	}
+
+	if err := n.insertAddressTxs(addrs, 1, &AddressTxsData{Blocks: blocks, Last: 3}); err != nil {
+		t.Fatal(err)
+	}
+	txs, _, err := n.bc.TransactionsByAddress(signer, "", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != len(blocks) {
+		t.Fatalf("expected %d rewards, got %d", len(blocks), len(txs))
+	}
+}
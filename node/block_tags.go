@@ -0,0 +1,142 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BlockTagLatest    = "latest"    // canonical chain head
+	BlockTagFinalized = "finalized" // the highest block, which is never reverted
+	BlockTagSafe      = "safe"      // the highest block confirmed by the majority of block authors
+	BlockTagEarliest  = "earliest"  // genesis block
+	BlockTagPending   = "pending"   // the next block assembled from the pool transactions
+)
+
+var (
+	ErrInvalidBlockID = errors.New("invalid block number, hash or tag")
+	ErrPendingState   = errors.New("pending block state is not available")
+)
+
+// blockByID returns block by its tag, hash, or decimal or 0x-prefixed hex number
+func (n *Node) blockByID(id string) (*types.Block, error) {
+	switch id {
+	case BlockTagLatest:
+		block, err := n.bc.GetBlock(n.bc.LastHash)
+		if err != nil {
+			return nil, err
+		}
+		return &block, nil
+	case BlockTagFinalized:
+		return n.bc.GetBlockByNumber(n.bc.FinalizedNumber())
+	case BlockTagSafe:
+		return n.bc.GetBlockByNumber(n.bc.SafeNumber())
+	case BlockTagEarliest:
+		return n.bc.GetBlockByNumber(0)
+	case BlockTagPending:
+		return n.pendingBlock()
+	}
+
+	if strings.HasPrefix(id, "0x") && len(id) == 2+common.HashLength*2 {
+		hash, err := hexutil.Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBlockID, id)
+		}
+
+		block, err := n.bc.GetBlock(common.BytesToHash(hash))
+		if err != nil {
+			return nil, err
+		}
+		return &block, nil
+	}
+
+	number, err := parseBlockNumber(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.bc.GetBlockByNumber(number)
+}
+
+// headerByID returns the chain tip branch header by its tag, hash, or decimal or 0x-prefixed hex number.
+// Headers are served by header-only node as well, so the latest one may be ahead of the blocks
+func (n *Node) headerByID(id string) (*types.BlockHeader, error) {
+	switch id {
+	case BlockTagLatest:
+		return n.bc.CurrentHeader()
+	case BlockTagFinalized:
+		return n.bc.GetHeaderByNumber(n.bc.FinalizedNumber())
+	case BlockTagSafe:
+		return n.bc.GetHeaderByNumber(n.bc.SafeNumber())
+	case BlockTagEarliest:
+		return n.bc.GetHeaderByNumber(0)
+	case BlockTagPending:
+		return nil, ErrPendingState
+	}
+
+	if strings.HasPrefix(id, "0x") && len(id) == 2+common.HashLength*2 {
+		hash, err := hexutil.Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBlockID, id)
+		}
+		return n.bc.GetBlockHeader(common.BytesToHash(hash))
+	}
+
+	number, err := parseBlockNumber(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.bc.GetHeaderByNumber(number)
+}
+
+// parseBlockNumber parses decimal or 0x-prefixed hex block number
+func parseBlockNumber(id string) (uint64, error) {
+	var number uint64
+	var err error
+	if strings.HasPrefix(id, "0x") {
+		number, err = hexutil.DecodeUint64(id)
+	} else {
+		number, err = strconv.ParseUint(id, 10, 64)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidBlockID, id)
+	}
+
+	return number, nil
+}
+
+// pendingBlock returns the next block with the pool transactions, which would be selected
+// by the block producer right now. It is neither executed nor sealed, so it has no hash and state root
+func (n *Node) pendingBlock() (*types.Block, error) {
+	head, err := n.bc.GetBlock(n.bc.LastHash)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().Unix()
+	if timestamp < head.Timestamp+n.bc.BlockTime() {
+		timestamp = head.Timestamp + n.bc.BlockTime()
+	}
+
+	block := types.NewBlock(types.BlockHeader{
+		PrevHash:  head.BlockHash,
+		Number:    head.Number + 1,
+		Timestamp: timestamp,
+	}, n.txPool.Select(params.TxPerBlockLimit))
+
+	block.NetherUsed = new(big.Int)
+	for _, tx := range block.Transactions {
+		block.NetherUsed.Add(block.NetherUsed, tx.Nether)
+	}
+
+	return block, nil
+}
@@ -0,0 +1,67 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"strconv"
+	"testing"
+)
+
+func TestBlockByID(t *testing.T) {
+	ctx := context.Background()
+	n := newTestNode(t, nil, 0)
+
+	// blocks of the single signer are safe right away, and final after params.PoAFinalityDepth confirmations
+	const head = params.PoAFinalityDepth + 8
+	blocks := make([]*types.Block, head+2) // genesis and pending blocks are not generated
+	for i := uint64(1); i <= head; i++ {
+		block, err := n.generateBlock(ctx, producerConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks[i] = block
+	}
+
+	tests := []struct {
+		id     string
+		number uint64
+		err    error
+	}{
+		{id: BlockTagLatest, number: head},
+		{id: BlockTagSafe, number: head},
+		{id: BlockTagFinalized, number: head - params.PoAFinalityDepth},
+		{id: BlockTagEarliest, number: 0},
+		{id: BlockTagPending, number: head + 1},
+		{id: "5", number: 5},
+		{id: "0x5", number: 5},
+		{id: blocks[3].BlockHash.Hex(), number: 3},
+		{id: "05x", err: ErrInvalidBlockID},
+		{id: "0x", err: ErrInvalidBlockID},
+		{id: "-1", err: ErrInvalidBlockID},
+		{id: strconv.FormatUint(head+1, 10), err: core.ErrBlockNotExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			block, err := n.blockByID(tt.id)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %s, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if block.Number != tt.number {
+				t.Fatalf("expected block %d, got %d", tt.number, block.Number)
+			}
+			if want := blocks[tt.number]; want != nil && block.BlockHash != want.BlockHash {
+				t.Fatalf("expected block %s, got %s", blocks[tt.number].BlockHash, block.BlockHash)
+			}
+		})
+	}
+}
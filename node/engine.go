@@ -0,0 +1,123 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	hraft "github.com/hashicorp/raft"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/consensus/poa"
+	"github.com/rovergulf/chain/consensus/raft"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	EnginePoA  = "poa"
+	EngineRaft = "raft"
+
+	DefaultRaftAddr = "127.0.0.1:9430"
+
+	raftMaxPool           = 3
+	raftTransportTimeout  = 10 * time.Second
+	raftSnapshotsRetained = 2
+)
+
+var (
+	ErrUnknownEngine     = errors.New("unknown consensus engine")
+	ErrUnsupportedEngine = errors.New("not supported by the node consensus engine")
+)
+
+// authorizer is the consensus engine, which seals blocks with the node account
+type authorizer interface {
+	Authorize(account common.Address, signFn consensus.SignerFn, signHashFn consensus.SignHashFn)
+}
+
+// setupEngine creates consensus engine selected by the `consensus.engine` config key
+// and sets it to verify chain blocks
+func (n *Node) setupEngine(chain *core.BlockChain, gen *core.Genesis) error {
+	switch engine := viper.GetString("consensus.engine"); engine {
+	case EnginePoA, "":
+		election, err := consensus.NewElection(gen.Election)
+		if err != nil {
+			return fmt.Errorf("unable to setup block author election: %w", err)
+		}
+
+		n.engine = poa.New(chain, params.PoAEpoch, gen.BlockTime, election)
+	case EngineRaft:
+		config, err := n.raftConfig()
+		if err != nil {
+			return err
+		}
+
+		n.engine = raft.New(chain, config)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownEngine, engine)
+	}
+
+	n.engine.(authorizer).Authorize(n.account.Address(), func(tx *types.Transaction) (*types.SignedTx, error) {
+		return n.account.SignTx(tx, chain.Signer())
+	}, func(hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, n.account.GetKey().PrivateKey)
+	})
+	chain.SetEngine(n.engine)
+
+	return nil
+}
+
+// raftConfig returns raft engine TCP transport listening on `raft.addr`, log and stable
+// store within the node database and snapshots store in the data directory
+func (n *Node) raftConfig() (raft.Config, error) {
+	addr := viper.GetString("raft.addr")
+	if addr == "" {
+		addr = DefaultRaftAddr
+	}
+
+	transport, err := hraft.NewTCPTransport(addr, nil, raftMaxPool, raftTransportTimeout, os.Stderr)
+	if err != nil {
+		return raft.Config{}, fmt.Errorf("unable to listen raft transport: %w", err)
+	}
+
+	snapshots, err := hraft.NewFileSnapshotStore(filepath.Join(viper.GetString("data_dir"), "raft"), raftSnapshotsRetained, os.Stderr)
+	if err != nil {
+		return raft.Config{}, fmt.Errorf("unable to open raft snapshots: %w", err)
+	}
+
+	store := raft.NewStore(n.db)
+
+	return raft.Config{
+		Transport:     transport,
+		LogStore:      store,
+		StableStore:   store,
+		SnapshotStore: snapshots,
+		Bootstrap:     viper.GetBool("raft.bootstrap"),
+		Resync:        n.downloader.triggerSync,
+		Logger:        n.logger,
+	}, nil
+}
+
+// startEngine starts raft node, other engines have nothing to run
+func (n *Node) startEngine() error {
+	if engine, ok := n.engine.(*raft.Raft); ok {
+		n.logger.Infow("Starting raft node", "addr", viper.GetString("raft.addr"),
+			"bootstrap", viper.GetBool("raft.bootstrap"))
+		return engine.Start()
+	}
+
+	return nil
+}
+
+// stopEngine stops raft node before the chain database is closed
+func (n *Node) stopEngine() {
+	if engine, ok := n.engine.(*raft.Raft); ok {
+		if err := engine.Shutdown(); err != nil {
+			n.logger.Errorf("Unable to stop raft node: %s", err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"sync"
+)
+
+var (
+	ErrEvidencePoolFull = errors.New("evidence pool is full")
+)
+
+// evidencePool keeps misbehavior evidence recorded by the node until it is included in the block
+type evidencePool struct {
+	items map[common.Hash]*types.Evidence
+	order []common.Hash // evidence is included in the order it was recorded
+	lock  sync.Mutex
+}
+
+func newEvidencePool() *evidencePool {
+	return &evidencePool{
+		items: make(map[common.Hash]*types.Evidence),
+	}
+}
+
+// Add puts the evidence to the pool, returns false if it is known already
+func (p *evidencePool) Add(ev *types.Evidence) (bool, error) {
+	hash, err := ev.Hash()
+	if err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.items[hash]; ok {
+		return false, nil
+	}
+	if len(p.items) >= params.EvidencePoolSlots {
+		return false, ErrEvidencePoolFull
+	}
+
+	p.items[hash] = ev
+	p.order = append(p.order, hash)
+	return true, nil
+}
+
+// Remove drops the evidence from the pool
+func (p *evidencePool) Remove(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.items[hash]; !ok {
+		return
+	}
+	delete(p.items, hash)
+
+	for i, h := range p.order {
+		if h == hash {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// List returns pool evidence in the order it was recorded
+func (p *evidencePool) List() []*types.Evidence {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	list := make([]*types.Evidence, 0, len(p.order))
+	for _, hash := range p.order {
+		list = append(list, p.items[hash])
+	}
+	return list
+}
+
+// recordEvidence verifies the evidence and adds it to the pool, so it is included in the next sealed block
+func (n *Node) recordEvidence(ev *types.Evidence) {
+	if err := n.bc.VerifyEvidence(ev); err != nil {
+		n.logger.Debugw("Misbehavior evidence is not recorded", "kind", ev.Kind, "offender", ev.Offender, "err", err)
+		return
+	}
+
+	added, err := n.evidence.Add(ev)
+	if err != nil {
+		n.logger.Warnw("Unable to record misbehavior evidence", "kind", ev.Kind, "offender", ev.Offender, "err", err)
+		return
+	}
+
+	if added {
+		n.logger.Warnw("Recorded misbehavior evidence", "kind", ev.Kind, "offender", ev.Offender)
+	}
+}
+
+// pendingEvidence returns pool evidence, which may be included in the next block.
+// Evidence included by the chain already, or not valid anymore, is dropped from the pool
+func (n *Node) pendingEvidence(limit int) []*types.Evidence {
+	var pending []*types.Evidence
+	for _, ev := range n.evidence.List() {
+		if len(pending) == limit {
+			break
+		}
+
+		if err := n.bc.VerifyEvidence(ev); err != nil {
+			if hash, err := ev.Hash(); err == nil {
+				n.evidence.Remove(hash)
+			}
+			continue
+		}
+		pending = append(pending, ev)
+	}
+
+	return pending
+}
+
+// removeIncludedEvidence drops evidence of the block from the pool
+func (n *Node) removeIncludedEvidence(block *types.Block) {
+	for _, ev := range block.Evidence {
+		hash, err := ev.Hash()
+		if err != nil {
+			n.logger.Warnf("Unable to get evidence hash: %s", err)
+			continue
+		}
+		n.evidence.Remove(hash)
+	}
+}
+
+// importBlock inserts the block received from the network and records its author misbehavior:
+// another block of the same number sealed by the same author, or block breaking validation rules
+func (n *Node) importBlock(ctx context.Context, block *types.Block) error {
+	n.checkDoubleSign(block)
+
+	err := n.bc.InsertBlock(ctx, block)
+	if err != nil && !errors.Is(err, core.ErrBlockAlreadyExists) {
+		if author, sealErr := consensus.Ecrecover(&block.BlockHeader); sealErr == nil {
+			// block is copied without unsigned local values, so every node records the same evidence
+			invalid := *block
+			invalid.TxHashes = nil
+			invalid.ReceivedAt = 0
+			n.recordEvidence(&types.Evidence{
+				Kind:     types.EvidenceInvalidBlock,
+				Offender: author,
+				Blocks:   []*types.Block{&invalid},
+			})
+		}
+	}
+
+	return err
+}
+
+// checkDoubleSign records evidence if the canonical block of the same number is sealed by the block author
+func (n *Node) checkDoubleSign(block *types.Block) {
+	hash, err := block.Hash()
+	if err != nil || common.BytesToHash(hash) != block.BlockHash {
+		return
+	}
+
+	canonical, err := n.bc.GetBlockByNumber(block.Number)
+	if err != nil || canonical.BlockHash == block.BlockHash {
+		return
+	}
+
+	author, err := consensus.Ecrecover(&block.BlockHeader)
+	if err != nil {
+		return
+	}
+	if signer, err := consensus.Ecrecover(&canonical.BlockHeader); err != nil || signer != author {
+		return
+	}
+
+	// another signature of the same seal is not an offence
+	sealHash, err := block.SealHash()
+	if err != nil {
+		return
+	}
+	if canonicalSeal, err := canonical.SealHash(); err != nil || bytes.Equal(canonicalSeal, sealHash) {
+		return
+	}
+
+	// headers are ordered by their hashes, so the same evidence is recorded by every node
+	headers := []*types.BlockHeader{&canonical.BlockHeader, &block.BlockHeader}
+	if bytes.Compare(canonical.BlockHash.Bytes(), block.BlockHash.Bytes()) > 0 {
+		headers[0], headers[1] = headers[1], headers[0]
+	}
+
+	n.recordEvidence(&types.Evidence{
+		Kind:     types.EvidenceDoubleSign,
+		Offender: author,
+		Headers:  headers,
+	})
+}
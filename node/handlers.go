@@ -2,123 +2,431 @@ package node
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/opentracing/opentracing-go"
-	"github.com/rovergulf/chain/pkg/traceutil"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
 )
 
-func (n *Node) handleStatusMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	if n.tracer != nil {
-		span := n.tracer.StartSpan("status_msg_handler", traceutil.ProvideParentSpan(ctx))
-		defer span.Finish()
-		ctx = opentracing.ContextWithSpan(ctx, span)
-	}
+const (
+	maxHeadersServe   = 192 // max headers sent in reply to the single request
+	maxBodiesServe    = 64  // max block bodies sent in reply to the single request
+	maxPooledTxsServe = 256 // max transactions sent in reply to the single request
+	maxReceiptsServe  = 256 // max receipts sent in reply to the single request
+
+	maxAddressBlocksServe = 64   // max address blocks sent in reply to the single request
+	maxAddressesQuery     = 64   // max addresses of the single address transactions query
+	maxAddressHeadersScan = 4096 // max headers filtered by the addresses bloom for the single request
+)
 
-	payload, err := json.Marshal(n.srv.NodeInfo())
+// newCallResult encodes the reply message data
+func newCallResult(code uint64, data interface{}) (*CallResult, error) {
+	payload, err := rlp.EncodeToBytes(data)
 	if err != nil {
 		return nil, err
 	}
 
 	return &CallResult{
-		Code: NodeDataMsg,
+		Code: code,
 		Data: payload,
 	}, nil
 }
 
-func (n *Node) handleNewBlockHashesMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// decodeMsg decodes the message payload, decoding errors are protocol violations
+func decodeMsg(payload []byte, v interface{}) error {
+	if err := rlp.DecodeBytes(payload, v); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMsg, err)
+	}
+	return nil
 }
 
-func (n *Node) handleTransactionsMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleStatusMsg updates the peer chain status, which may not change its network or genesis
+func (n *Node) handleStatusMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var status StatusResult
+	if err := decodeMsg(payload, &status); err != nil {
+		return nil, err
+	}
+
+	negotiated := p.Status()
+	if negotiated == nil || status.ProtocolVersion != negotiated.ProtocolVersion ||
+		status.NetworkId != negotiated.NetworkId || status.Genesis != negotiated.Genesis {
+		return nil, fmt.Errorf("%w: status does not match the handshake", ErrInvalidMsg)
+	}
+
+	p.SetStatus(&status)
+	return nil, nil
 }
 
-func (n *Node) handleGetBlockHeadersMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleNewBlockHashesMsg updates the peer head with the announced blocks
+func (n *Node) handleNewBlockHashesMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var announces []BlockAnnounce
+	if err := decodeMsg(payload, &announces); err != nil {
+		return nil, err
+	}
+
+	for _, announce := range announces {
+		p.SetHead(announce.Hash, announce.Number, 0)
+	}
+
+	return nil, nil
 }
 
-func (n *Node) handleBlockHeadersMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleTransactionsMsg adds transactions relayed by the peer to the pool
+func (n *Node) handleTransactionsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var relays []*types.RelayedTx
+	if err := decodeMsg(payload, &relays); err != nil {
+		return nil, err
+	}
+
+	return nil, n.addPeerTxs(ctx, p, relays)
 }
 
-func (n *Node) GetBlockBodiesMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleGetBlockHeadersMsg replies with the chain tip branch headers, so header-only node serves them as well.
+// Reply is cut at the first unknown header
+func (n *Node) handleGetBlockHeadersMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var req GetBlockHeadersData
+	if err := decodeMsg(payload, &req); err != nil {
+		return nil, err
+	}
+
+	amount := req.Amount
+	if amount > maxHeadersServe {
+		amount = maxHeadersServe
+	}
+
+	var origin *types.BlockHeader
+	var err error
+	if req.Hash != (common.Hash{}) {
+		origin, err = n.bc.GetBlockHeader(req.Hash)
+	} else {
+		origin, err = n.bc.GetHeaderByNumber(req.Number)
+	}
+
+	headers := make([]*types.BlockHeader, 0, amount)
+	if err == nil && amount > 0 {
+		headers = append(headers, origin)
+
+		step := req.Skip + 1
+		for number := origin.Number; uint64(len(headers)) < amount; {
+			if req.Reverse {
+				if number < step {
+					break
+				}
+				number -= step
+			} else {
+				if number+step < number {
+					break
+				}
+				number += step
+			}
+
+			header, err := n.bc.GetHeaderByNumber(number)
+			if err != nil {
+				break
+			}
+			headers = append(headers, header)
+		}
+	}
+
+	return newCallResult(BlockHeadersMsg, &BlockHeadersData{
+		RequestId: req.RequestId,
+		Headers:   headers,
+	})
 }
 
-func (n *Node) BlockBodiesMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleBlockHeadersMsg delivers headers to the pending sync request, unrequested headers are dropped
+func (n *Node) handleBlockHeadersMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var data BlockHeadersData
+	if err := decodeMsg(payload, &data); err != nil {
+		return nil, err
+	}
+
+	n.downloader.deliver(p.id, data.RequestId, data.Headers)
+	return nil, nil
 }
 
-func (n *Node) handleNewBlockMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleGetBlockBodiesMsg replies with bodies of requested blocks, reply is cut at the first unknown block
+func (n *Node) handleGetBlockBodiesMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var req GetBlockBodiesData
+	if err := decodeMsg(payload, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Hashes) > maxBodiesServe {
+		req.Hashes = req.Hashes[:maxBodiesServe]
+	}
+
+	bodies := make([]*BlockBody, 0, len(req.Hashes))
+	for _, hash := range req.Hashes {
+		block, err := n.bc.GetBlock(hash)
+		if err != nil {
+			break
+		}
+		bodies = append(bodies, &BlockBody{
+			Transactions: block.Transactions,
+			Evidence:     block.Evidence,
+		})
+	}
+
+	return newCallResult(BlockBodiesMsg, &BlockBodiesData{
+		RequestId: req.RequestId,
+		Bodies:    bodies,
+	})
 }
 
-func (n *Node) handleGetNodeDataMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleBlockBodiesMsg delivers bodies to the pending sync request, unrequested bodies are dropped
+func (n *Node) handleBlockBodiesMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var data BlockBodiesData
+	if err := decodeMsg(payload, &data); err != nil {
+		return nil, err
+	}
+
+	n.downloader.deliver(p.id, data.RequestId, data.Bodies)
+	return nil, nil
 }
 
-func (n *Node) handleNodeDataMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleNewBlockMsg imports the block propagated by the peer and updates the peer head.
+// Invalid block is recorded as its author evidence, relaying peer is not dropped for it
+func (n *Node) handleNewBlockMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var data NewBlockData
+	if err := decodeMsg(payload, &data); err != nil {
+		return nil, err
+	}
+	if data.Block == nil {
+		return nil, fmt.Errorf("%w: empty block", ErrInvalidMsg)
+	}
+
+	p.SetHead(data.Block.BlockHash, data.Block.Number, data.TotalWeight)
+
+	if err := n.importBlock(ctx, data.Block); err != nil && !errors.Is(err, core.ErrBlockAlreadyExists) {
+		n.logger.Debugw("Unable to import peer block", "id", p.id, "hash", data.Block.BlockHash,
+			"number", data.Block.Number, "err", err)
+	}
+
+	return nil, nil
 }
 
-func (n *Node) handleGetReceiptsMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+func (n *Node) handleGetNodeDataMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	return nil, nil
 }
 
-func (n *Node) handleReceiptsMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+func (n *Node) handleNodeDataMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	return nil, nil
 }
 
-func (n *Node) handleNewPooledTransactionHashesMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleGetReceiptsMsg replies with receipts of requested transactions hashes, unknown ones are skipped
+func (n *Node) handleGetReceiptsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var hashes []common.Hash
+	if err := decodeMsg(payload, &hashes); err != nil {
+		return nil, err
+	}
+	if len(hashes) > maxReceiptsServe {
+		hashes = hashes[:maxReceiptsServe]
+	}
+
+	receipts := make([]*types.Receipt, 0, len(hashes))
+	for _, hash := range hashes {
+		receipt, err := n.bc.GetReceipt(ctx, hash)
+		if err != nil {
+			continue
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return newCallResult(ReceiptsMsg, receipts)
 }
 
-func (n *Node) handleGetPooledTransactionsMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+func (n *Node) handleReceiptsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	return nil, nil
 }
 
-func (n *Node) handlePooledTransactionsMsg(ctx context.Context, payload []byte) (*CallResult, error) {
-	return &CallResult{
-		Code: 0,
-		Data: nil,
-	}, nil
+// handleNewPooledTransactionHashesMsg requests announced transactions, which are not in the pool yet
+func (n *Node) handleNewPooledTransactionHashesMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var hashes []common.Hash
+	if err := decodeMsg(payload, &hashes); err != nil {
+		return nil, err
+	}
+
+	var unknown []common.Hash
+	for _, hash := range hashes {
+		if len(unknown) == maxPooledTxsServe {
+			break
+		}
+		if _, ok := n.txPool.Get(hash); !ok {
+			unknown = append(unknown, hash)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil, nil
+	}
+
+	return newCallResult(GetPooledTransactionsMsg, unknown)
+}
+
+// handleGetPooledTransactionsMsg replies with requested pool transactions, unknown ones are skipped
+func (n *Node) handleGetPooledTransactionsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var hashes []common.Hash
+	if err := decodeMsg(payload, &hashes); err != nil {
+		return nil, err
+	}
+	if len(hashes) > maxPooledTxsServe {
+		hashes = hashes[:maxPooledTxsServe]
+	}
+
+	relays := make([]*types.RelayedTx, 0, len(hashes))
+	for _, hash := range hashes {
+		tx, ok := n.txPool.Get(hash)
+		if !ok {
+			continue
+		}
+
+		relay, err := types.NewRelayedTx(tx, n.account.GetKey().PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, relay)
+	}
+
+	return newCallResult(PooledTransactionsMsg, relays)
+}
+
+// handlePooledTransactionsMsg adds requested transactions to the pool
+func (n *Node) handlePooledTransactionsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var relays []*types.RelayedTx
+	if err := decodeMsg(payload, &relays); err != nil {
+		return nil, err
+	}
+
+	return nil, n.addPeerTxs(ctx, p, relays)
+}
+
+// handleGetAddressTxsMsg replies with the canonical blocks, which header bloom matches any of queried addresses,
+// with their transactions and receipts proven by Merkle branches. Block of the false positive bloom match
+// is served with all of its transactions instead, so the requester knows none of it was omitted.
+// Header-only node has no blocks to serve
+func (n *Node) handleGetAddressTxsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var req GetAddressTxsData
+	if err := decodeMsg(payload, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Addresses) > maxAddressesQuery {
+		return nil, fmt.Errorf("%w: %d addresses of max %d", ErrInvalidMsg, len(req.Addresses), maxAddressesQuery)
+	}
+
+	// blocks are served up to the node blocks head, which may be behind its header chain
+	last := req.To
+	if head := n.bc.ChainLength - 1; head < last {
+		last = head
+	}
+	if req.From <= last && last-req.From >= maxAddressHeadersScan {
+		last = req.From + maxAddressHeadersScan - 1
+	}
+
+	addresses := make(map[common.Address]bool, len(req.Addresses))
+	for _, addr := range req.Addresses {
+		addresses[addr] = true
+	}
+
+	var blocks []*AddressBlock
+	for number := req.From; number <= last; number++ {
+		header, err := n.bc.GetHeaderByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		if !header.Bloom.TestAny(req.Addresses) {
+			continue
+		}
+
+		block, err := n.bc.GetBlock(header.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		addressBlock, err := n.addressBlock(&block, addresses)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, addressBlock)
+
+		if len(blocks) == maxAddressBlocksServe {
+			last = number
+			break
+		}
+	}
+
+	return newCallResult(AddressTxsMsg, &AddressTxsData{
+		RequestId: req.RequestId,
+		Blocks:    blocks,
+		Last:      last,
+	})
+}
+
+// addressBlock returns the block transactions of the addresses and their receipts with the inclusion proof,
+// or all of the block transactions, if there are no transactions of the addresses
+func (n *Node) addressBlock(block *types.Block, addresses map[common.Address]bool) (*AddressBlock, error) {
+	var indexes []int
+	for i, tx := range block.Transactions {
+		if addresses[tx.To] || (!tx.IsReward() && addresses[tx.From]) {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return &AddressBlock{BlockHash: block.BlockHash, Body: block.Transactions}, nil
+	}
+
+	receipts, err := n.bc.GetBlockReceipts(block.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := types.NewBlockProof(block, receipts, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	addressBlock := &AddressBlock{BlockHash: block.BlockHash, Proof: proof}
+	for _, i := range indexes {
+		addressBlock.Transactions = append(addressBlock.Transactions, block.Transactions[i])
+		addressBlock.Receipts = append(addressBlock.Receipts, receipts[i])
+	}
+
+	return addressBlock, nil
+}
+
+// handleAddressTxsMsg delivers address blocks to the pending sync request, unrequested blocks are dropped
+func (n *Node) handleAddressTxsMsg(ctx context.Context, p *Peer, payload []byte) (*CallResult, error) {
+	var data AddressTxsData
+	if err := decodeMsg(payload, &data); err != nil {
+		return nil, err
+	}
+
+	n.downloader.deliver(p.id, data.RequestId, &data)
+	return nil, nil
+}
+
+// addPeerTxs adds peer transactions to the pool. Rejected transactions are not a protocol violation,
+// as the peer pool state may differ, but every transaction must be relayed by the peer account.
+// Forged transaction relay signed by the peer is recorded as its misbehavior evidence
+func (n *Node) addPeerTxs(ctx context.Context, p *Peer, relays []*types.RelayedTx) error {
+	for _, relay := range relays {
+		if relayer, err := relay.Relayer(); err != nil || relayer != p.Account() {
+			return fmt.Errorf("%w: tx is not relayed by the peer account", ErrInvalidMsg)
+		}
+
+		tx := relay.Tx
+		_, err := n.AddPendingTX(ctx, *tx, PeerNode{id: p.id, account: p.Account()})
+		if errors.Is(err, ErrForgedTx) {
+			n.recordEvidence(&types.Evidence{
+				Kind:     types.EvidenceForgedTx,
+				Offender: p.Account(),
+				Relays:   []*types.RelayedTx{relay},
+			})
+		}
+		if err != nil {
+			n.logger.Debugw("Peer transaction is rejected", "id", p.id, "from", tx.From, "nonce", tx.Nonce, "err", err)
+		}
+	}
+
+	return nil
 }
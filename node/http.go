@@ -3,18 +3,29 @@ package node
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rovergulf/chain/consensus/poa"
+	"github.com/rovergulf/chain/consensus/raft"
 	"github.com/rovergulf/chain/core"
 	"github.com/rovergulf/chain/core/types"
-	"github.com/rovergulf/chain/params"
 	"github.com/rovergulf/chain/wallets"
+	"math/big"
 	"net/http"
+	"strconv"
 )
 
 func (n *Node) serveHttp() error {
+	n.registerRoutes()
+
+	return http.ListenAndServe(n.metadata.ApiAddress(), &n.httpHandler)
+}
+
+// registerRoutes sets node HTTP API handlers, node management ones are available to local requests only
+func (n *Node) registerRoutes() {
 	r := n.httpHandler.router
 
 	// http utility routes
@@ -36,26 +47,37 @@ func (n *Node) serveHttp() error {
 
 	r.HandleFunc("/node/info", n.nodeInfo).Methods(http.MethodGet)
 	r.HandleFunc("/node/peers", n.searchKnownPeers).Methods(http.MethodGet)
+	r.HandleFunc("/node/signers", n.listSigners).Methods(http.MethodGet)
+	r.HandleFunc("/node/signers", n.localOnly(n.proposeSigner)).Methods(http.MethodPost)
+	r.HandleFunc("/node/signers/{address}", n.localOnly(n.discardSigner)).Methods(http.MethodDelete)
+	r.HandleFunc("/node/evidence", n.listEvidence).Methods(http.MethodGet)
+	r.HandleFunc("/node/raft", n.raftStatus).Methods(http.MethodGet)
+	r.HandleFunc("/node/raft/members", n.localOnly(n.addRaftMember)).Methods(http.MethodPost)
+	r.HandleFunc("/node/raft/members/{account}", n.localOnly(n.removeRaftMember)).Methods(http.MethodDelete)
 
 	r.HandleFunc("/chain/info", n.healthCheck).Methods(http.MethodGet)
 	r.HandleFunc("/genesis", n.ShowGenesis).Methods(http.MethodGet)
 
 	r.HandleFunc("/blocks", n.ListBlocks).Methods(http.MethodGet)
 	r.HandleFunc("/blocks/latest", n.LatestBlock).Methods(http.MethodGet)
-	r.HandleFunc("/blocks/{hash}", n.FindBlock).Methods(http.MethodGet)
+	r.HandleFunc("/blocks/finalized", n.FinalizedBlock).Methods(http.MethodGet)
+	r.HandleFunc("/blocks/safe", n.SafeBlock).Methods(http.MethodGet)
+	r.HandleFunc("/blocks/{id}", n.FindBlock).Methods(http.MethodGet)
+
+	r.HandleFunc("/headers/{id}", n.FindHeader).Methods(http.MethodGet)
 
 	r.HandleFunc("/balances", n.ListBalances).Methods(http.MethodGet)
 	r.HandleFunc("/balances/{addr}", n.GetBalance).Methods(http.MethodGet)
+	r.HandleFunc("/balances/{addr}/txs", n.AddressTransactions).Methods(http.MethodGet)
 
 	r.HandleFunc("/tx/add", n.txAdd).Methods(http.MethodPost)
+	r.HandleFunc("/tx/replace", n.txReplace).Methods(http.MethodPost)
 	r.HandleFunc("/tx/{hash}", n.txFind).Methods(http.MethodGet)
 
 	r.HandleFunc("/accounts", n.healthCheck).Methods(http.MethodGet)
 	r.HandleFunc("/accounts", n.healthCheck).Methods(http.MethodPost)
 	r.HandleFunc("/accounts", n.healthCheck).Methods(http.MethodPut)
 	r.HandleFunc("/accounts/{address}", n.healthCheck).Methods(http.MethodGet)
-
-	return http.ListenAndServe(n.metadata.ApiAddress(), &n.httpHandler)
 }
 
 func (n *Node) nodeInfo(w http.ResponseWriter, r *http.Request) {
@@ -81,13 +103,37 @@ func (n *Node) nodeInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pendingTxs, queuedTxs := n.txPool.Stats()
+
+	progress, err := n.SyncProgress()
+	if err != nil {
+		n.httpResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// slots after the next block depend on its hash, so only the next one is known
+	slots := make([]*Slot, 0, 1)
+	slot, err := n.nextSlot(ctx, &lb.BlockHeader)
+	if err != nil {
+		n.httpResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+	if slot != nil {
+		slots = append(slots, slot)
+	}
+
 	n.httpResponse(w, map[string]interface{}{
-		"node_info": n.srv.NodeInfo(),
-		"genesis":   gen.BlockHash,
-		"head":      lb.BlockHeader.BlockHash.Hex(),
-		//"pending_txs": n.pendingState.pendingTxLen(),
+		"node_info":   n.srv.NodeInfo(),
+		"genesis":     gen.BlockHash,
+		"head":        lb.BlockHeader.BlockHash.Hex(),
+		"safe":        n.bc.SafeNumber(),
+		"finalized":   n.bc.FinalizedNumber(),
+		"pending_txs": pendingTxs,
+		"queued_txs":  queuedTxs,
+		"slots":       slots,
 		"peers":       n.srv.PeerCount(),
 		"in_gen_race": n.inGenRace,
+		"sync":        progress,
 		"db_size": map[string]int64{
 			"chain_lsm":    bcLsm,
 			"chain_vlog":   bcVlog,
@@ -99,6 +145,157 @@ func (n *Node) nodeInfo(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// listEvidence returns misbehavior evidence recorded by the node, which is not included in the chain yet
+func (n *Node) listEvidence(w http.ResponseWriter, r *http.Request) {
+	n.httpResponse(w, n.evidence.List())
+}
+
+func (n *Node) listSigners(w http.ResponseWriter, r *http.Request) {
+	head, err := n.bc.GetBlockHeader(n.bc.LastHash)
+	if err != nil {
+		n.httpResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	engine, ok := n.engine.(*poa.PoA)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("signers voting is %w", ErrUnsupportedEngine), http.StatusNotImplemented)
+		return
+	}
+
+	snap, err := engine.Snapshot(head)
+	if err != nil {
+		n.httpResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	n.httpResponse(w, SignersResult{
+		Number:    snap.Number,
+		Hash:      snap.Hash,
+		Signers:   snap.SignersList(),
+		Votes:     snap.Votes,
+		Proposals: engine.Proposals(),
+	})
+}
+
+func (n *Node) proposeSigner(w http.ResponseWriter, r *http.Request) {
+	engine, ok := n.engine.(*poa.PoA)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("signers voting is %w", ErrUnsupportedEngine), http.StatusNotImplemented)
+		return
+	}
+
+	var req SignerProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if !common.IsHexAddress(req.Address) {
+		n.httpResponse(w, fmt.Errorf("invalid signer address: %s", req.Address), http.StatusBadRequest)
+		return
+	}
+
+	engine.Propose(common.HexToAddress(req.Address), req.Authorize)
+	n.httpResponse(w, engine.Proposals())
+}
+
+func (n *Node) discardSigner(w http.ResponseWriter, r *http.Request) {
+	engine, ok := n.engine.(*poa.PoA)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("signers voting is %w", ErrUnsupportedEngine), http.StatusNotImplemented)
+		return
+	}
+
+	address := mux.Vars(r)["address"]
+	if !common.IsHexAddress(address) {
+		n.httpResponse(w, fmt.Errorf("invalid signer address: %s", address), http.StatusBadRequest)
+		return
+	}
+
+	engine.Discard(common.HexToAddress(address))
+	n.httpResponse(w, engine.Proposals())
+}
+
+func (n *Node) raftStatus(w http.ResponseWriter, r *http.Request) {
+	engine, ok := n.engine.(*raft.Raft)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("raft cluster is %w", ErrUnsupportedEngine), http.StatusNotImplemented)
+		return
+	}
+
+	members, err := engine.Members()
+	if err != nil {
+		n.httpResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	n.httpResponse(w, RaftResult{
+		State:   engine.State(),
+		Members: members,
+	})
+}
+
+func (n *Node) addRaftMember(w http.ResponseWriter, r *http.Request) {
+	engine, ok := n.engine.(*raft.Raft)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("raft cluster is %w", ErrUnsupportedEngine), http.StatusNotImplemented)
+		return
+	}
+
+	var req RaftMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if !common.IsHexAddress(req.Account) {
+		n.httpResponse(w, fmt.Errorf("invalid member account: %s", req.Account), http.StatusBadRequest)
+		return
+	}
+
+	if req.Address == "" {
+		n.httpResponse(w, fmt.Errorf("member raft address is required"), http.StatusBadRequest)
+		return
+	}
+
+	if err := engine.AddVoter(common.HexToAddress(req.Account), req.Address); err != nil {
+		n.httpResponse(w, err, raftErrorStatus(err))
+		return
+	}
+
+	n.raftStatus(w, r)
+}
+
+func (n *Node) removeRaftMember(w http.ResponseWriter, r *http.Request) {
+	engine, ok := n.engine.(*raft.Raft)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("raft cluster is %w", ErrUnsupportedEngine), http.StatusNotImplemented)
+		return
+	}
+
+	account := mux.Vars(r)["account"]
+	if !common.IsHexAddress(account) {
+		n.httpResponse(w, fmt.Errorf("invalid member account: %s", account), http.StatusBadRequest)
+		return
+	}
+
+	if err := engine.RemoveMember(common.HexToAddress(account)); err != nil {
+		n.httpResponse(w, err, raftErrorStatus(err))
+		return
+	}
+
+	n.raftStatus(w, r)
+}
+
+// raftErrorStatus returns conflict status for membership changes sent to the follower
+func raftErrorStatus(err error) int {
+	if errors.Is(err, raft.ErrNotLeader) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 func (n *Node) searchKnownPeers(w http.ResponseWriter, r *http.Request) {
 	n.httpResponse(w, n.srv.PeersInfo())
 }
@@ -150,6 +347,29 @@ func (n *Node) GetBalance(w http.ResponseWriter, r *http.Request) {
 
 	address := common.HexToAddress(addr)
 
+	// balance of the block state is read from the state trie, latest one from the flat index
+	if id := r.URL.Query().Get("block"); id != "" && id != BlockTagLatest {
+		if id == BlockTagPending {
+			n.httpResponse(w, ErrPendingState, http.StatusBadRequest)
+			return
+		}
+
+		block, err := n.blockByID(id)
+		if err != nil {
+			n.httpResponse(w, err, blockErrorStatus(err))
+			return
+		}
+
+		balance, err := n.bc.GetBalanceAt(address, block.Root)
+		if err != nil {
+			n.httpResponse(w, err, blockErrorStatus(err))
+			return
+		}
+
+		n.httpResponse(w, balance)
+		return
+	}
+
 	balance, err := n.bc.GetBalance(address)
 	if err != nil {
 		n.httpResponse(w, err, http.StatusBadRequest)
@@ -159,6 +379,54 @@ func (n *Node) GetBalance(w http.ResponseWriter, r *http.Request) {
 	n.httpResponse(w, balance)
 }
 
+func (n *Node) AddressTransactions(w http.ResponseWriter, r *http.Request) {
+	//ctx := r.Context()
+	vars := mux.Vars(r)
+	addr := vars["addr"]
+
+	if !common.IsHexAddress(addr) {
+		n.httpResponse(w, fmt.Errorf("invalid address: %s", addr), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var limit int
+	if limitVar := query.Get("limit"); len(limitVar) > 0 {
+		value, err := strconv.Atoi(limitVar)
+		if err != nil {
+			n.httpResponse(w, fmt.Errorf("invalid limit: %s", limitVar), http.StatusBadRequest)
+			return
+		}
+		limit = value
+	}
+
+	var desc bool
+	switch order := query.Get("order"); order {
+	case "", orderAsc:
+	case orderDesc:
+		desc = true
+	default:
+		n.httpResponse(w, fmt.Errorf("invalid order: %s", order), http.StatusBadRequest)
+		return
+	}
+
+	txs, next, err := n.bc.TransactionsByAddress(common.HexToAddress(addr), query.Get("cursor"), limit, desc)
+	if err != nil {
+		if err == core.ErrInvalidCursor {
+			n.httpResponse(w, err, http.StatusBadRequest)
+		} else {
+			n.httpResponse(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	n.httpResponse(w, AddressTxsResult{
+		Txs:        txs,
+		NextCursor: next,
+	})
+}
+
 func (n *Node) txAdd(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -182,8 +450,14 @@ func (n *Node) txAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nonce := n.bc.GetNextAccountNonce(from)
-	tx, err := types.NewTransaction(from, common.HexToAddress(req.To), uint64(req.Value*params.Raftel), nonce, req.Data)
+	nonce := n.txPool.Nonce(from)
+	value, err := core.ParseCoinAmount(req.Value.String())
+	if err != nil {
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := types.NewTransaction(from, common.HexToAddress(req.To), value, nonce, req.Data)
 	if err != nil {
 		n.logger.Errorf("Unable to create new transaction: %s", err)
 		n.httpResponse(w, err, http.StatusBadRequest)
@@ -197,14 +471,14 @@ func (n *Node) txAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	signedTx, err := wallets.NewSignedTx(tx, wallet.GetKey().PrivateKey)
+	signedTx, err := wallets.NewSignedTx(tx, n.bc.Signer(), wallet.GetKey().PrivateKey)
 	if err != nil {
 		n.logger.Errorf("Unable to sign tx: %s", err)
 		n.httpResponse(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	receipt, err := n.AddPendingTX(ctx, signedTx, n.metadata)
+	receipt, err := n.AddLocalTX(ctx, signedTx)
 	if err != nil {
 		n.logger.Errorf("Unable to add pending tx: %s", err)
 		n.httpResponse(w, err, http.StatusInternalServerError)
@@ -214,6 +488,71 @@ func (n *Node) txAdd(w http.ResponseWriter, r *http.Request) {
 	n.httpResponse(w, receipt)
 }
 
+// txReplace speeds up or cancels pool transaction by the new one with the same nonce and higher nether price
+func (n *Node) txReplace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req TxReplaceRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	from := common.HexToAddress(req.From)
+
+	pooled, ok := n.txPool.Find(from, req.Nonce)
+	if !ok {
+		n.httpResponse(w, fmt.Errorf("there is no pending '%s' transaction with nonce %d", from, req.Nonce),
+			http.StatusNotFound)
+		return
+	}
+
+	var tx types.Transaction
+	var err error
+	if req.Cancel {
+		tx, err = types.NewTransaction(from, from, new(big.Int), req.Nonce, nil)
+	} else {
+		tx, err = types.NewTransaction(from, pooled.To, pooled.Value, req.Nonce, pooled.Data)
+	}
+	if err != nil {
+		n.logger.Errorf("Unable to create replacement transaction: %s", err)
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if tx.NetherPrice, err = n.txPool.ReplacementPrice(pooled.NetherPrice); err != nil {
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.NetherPrice > tx.NetherPrice {
+		tx.NetherPrice = req.NetherPrice
+	}
+
+	wallet, err := n.wm.GetWallet(from, req.FromPwd)
+	if err != nil {
+		n.logger.Errorf("Unable to find stored account key: %s", err)
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	signedTx, err := wallets.NewSignedTx(tx, n.bc.Signer(), wallet.GetKey().PrivateKey)
+	if err != nil {
+		n.logger.Errorf("Unable to sign tx: %s", err)
+		n.httpResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	receipt, err := n.AddLocalTX(ctx, signedTx)
+	if err != nil {
+		n.logger.Errorf("Unable to replace pending tx: %s", err)
+		n.httpResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	n.httpResponse(w, receipt)
+}
+
 func (n *Node) txFind(w http.ResponseWriter, r *http.Request) {
 	//ctx := r.Context()
 	vars := mux.Vars(r)
@@ -229,13 +568,29 @@ func (n *Node) txFind(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := n.bc.FindTransaction(hash)
+	if tx, ok := n.txPool.Get(hash); ok {
+		n.httpResponse(w, TxResult{
+			Status: types.TxStatusPending,
+			Tx:     tx,
+		})
+		return
+	}
+
+	tx, lookup, err := n.bc.FindTransaction(hash)
 	if err != nil {
-		n.httpResponse(w, err, http.StatusInternalServerError)
+		if err == core.ErrTxNotExists {
+			n.httpResponse(w, err, http.StatusNotFound)
+		} else {
+			n.httpResponse(w, err, http.StatusInternalServerError)
+		}
 		return
 	}
 
-	n.httpResponse(w, tx)
+	n.httpResponse(w, TxResult{
+		Status: types.TxStatusMined,
+		Tx:     tx,
+		Lookup: lookup,
+	})
 }
 
 func (n *Node) ListBlocks(w http.ResponseWriter, r *http.Request) {
@@ -250,30 +605,51 @@ func (n *Node) ListBlocks(w http.ResponseWriter, r *http.Request) {
 }
 
 func (n *Node) LatestBlock(w http.ResponseWriter, r *http.Request) {
-	//ctx := r.Context()
-	b, err := n.bc.GetBlock(n.bc.LastHash)
-	if err != nil {
-		n.httpResponse(w, err, http.StatusInternalServerError)
-		return
-	}
+	n.blockResponse(w, BlockTagLatest)
+}
 
-	n.httpResponse(w, b)
+func (n *Node) FinalizedBlock(w http.ResponseWriter, r *http.Request) {
+	n.blockResponse(w, BlockTagFinalized)
 }
 
+func (n *Node) SafeBlock(w http.ResponseWriter, r *http.Request) {
+	n.blockResponse(w, BlockTagSafe)
+}
+
+// FindBlock returns block by its hash, number or tag
 func (n *Node) FindBlock(w http.ResponseWriter, r *http.Request) {
-	//ctx := r.Context()
-	vars := mux.Vars(r)
-	hash := vars["hash"]
-	if len(hash) == 0 {
-		n.httpResponse(w, fmt.Errorf("invalid hash: %s", hash), http.StatusBadRequest)
+	n.blockResponse(w, mux.Vars(r)["id"])
+}
+
+// FindHeader returns the chain tip branch header by its hash, number or tag
+func (n *Node) FindHeader(w http.ResponseWriter, r *http.Request) {
+	header, err := n.headerByID(mux.Vars(r)["id"])
+	if err != nil {
+		n.httpResponse(w, err, blockErrorStatus(err))
 		return
 	}
 
-	b, err := n.bc.GetBlock(common.HexToHash(hash))
+	n.httpResponse(w, header)
+}
+
+func (n *Node) blockResponse(w http.ResponseWriter, id string) {
+	b, err := n.blockByID(id)
 	if err != nil {
-		n.httpResponse(w, err, http.StatusInternalServerError)
+		n.httpResponse(w, err, blockErrorStatus(err))
 		return
 	}
 
 	n.httpResponse(w, b)
 }
+
+// blockErrorStatus returns bad request status for invalid block id and not found for missing block
+func blockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidBlockID), errors.Is(err, ErrPendingState):
+		return http.StatusBadRequest
+	case errors.Is(err, core.ErrBlockNotExists), errors.Is(err, core.ErrBalanceNotExists):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
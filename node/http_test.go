@@ -0,0 +1,56 @@
+package node
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalOnlyRoutes(t *testing.T) {
+	n := newTestNode(t, nil, 0)
+	n.registerRoutes()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		remote string
+		header http.Header
+		status int
+	}{
+		{name: "remote request", remote: "192.0.2.1:1234", status: http.StatusForbidden},
+		{name: "browser request", remote: "127.0.0.1:1234", header: http.Header{"Origin": {"http://example.com"}}, status: http.StatusForbidden},
+		{name: "proxied request", remote: "127.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"192.0.2.1"}}, status: http.StatusForbidden},
+		{name: "local request", remote: "127.0.0.1:1234", status: http.StatusBadRequest},
+		{name: "local IPv6 request", remote: "[::1]:1234", status: http.StatusBadRequest},
+		{name: "remote signer discard", method: http.MethodDelete, path: "/node/signers/0x01", remote: "192.0.2.1:1234", status: http.StatusForbidden},
+		{name: "remote raft member", path: "/node/raft/members", remote: "192.0.2.1:1234", status: http.StatusForbidden},
+		{name: "remote raft member removal", method: http.MethodDelete, path: "/node/raft/members/0x01", remote: "192.0.2.1:1234", status: http.StatusForbidden},
+		{name: "local raft member", path: "/node/raft/members", remote: "127.0.0.1:1234", status: http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// local requests reach the handler, which rejects the empty body or the node engine
+			method, path := http.MethodPost, "/node/signers"
+			if tt.method != "" {
+				method = tt.method
+			}
+			if tt.path != "" {
+				path = tt.path
+			}
+			r := httptest.NewRequest(method, path, bytes.NewBufferString("{}"))
+			r.RemoteAddr = tt.remote
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+
+			w := httptest.NewRecorder()
+			n.httpHandler.router.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+		})
+	}
+}
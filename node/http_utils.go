@@ -2,6 +2,7 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/opentracing/opentracing-go"
@@ -11,11 +12,14 @@ import (
 	"github.com/rovergulf/chain/pkg/resutil"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 )
 
+var ErrLocalOnly = errors.New("endpoint is available to local requests only")
+
 var allowedHeaders = []string{
 	"Accept",
 	"Content-Type",
@@ -77,15 +81,45 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.router.ServeHTTP(w, r.WithContext(ctx))
 }
 
+// localOnly restricts unauthenticated node management handler to the node host requests.
+// Browser and proxied requests are rejected, as they may be made on behalf of remote clients
+func (n *Node) localOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopback(r.RemoteAddr) || r.Header.Get("Origin") != "" ||
+			r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+			n.httpResponse(w, ErrLocalOnly, http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// isLoopback returns whether the request remote address is a loopback one
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (n *Node) httpResponse(w http.ResponseWriter, i interface{}, statusCode ...int) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
-	if len(statusCode) > http.StatusOK {
+	if len(statusCode) > 0 {
 		w.WriteHeader(statusCode[0])
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
 
+	// errors have no exported fields to be encoded
+	if err, ok := i.(error); ok {
+		i = map[string]string{"error": err.Error()}
+	}
+
 	if err := resutil.WriteJSON(w, n.logger, i); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(fmt.Sprintf("Unable to write json response: %s", err)))
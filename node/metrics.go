@@ -0,0 +1,32 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// peerHandshakes counts completed rbn protocol handshakes
+	peerHandshakes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "rbn",
+		Subsystem: "p2p",
+		Name:      "handshakes_total",
+		Help:      "Number of completed peer handshakes",
+	})
+
+	// peerHandshakeFailures counts failed rbn protocol handshakes by the peer disconnect reason
+	peerHandshakeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rbn",
+		Subsystem: "p2p",
+		Name:      "handshake_failures_total",
+		Help:      "Number of failed peer handshakes by disconnect reason",
+	}, []string{"reason"})
+
+	// peerProtocolViolations counts peers dropped for protocol violations after handshake
+	peerProtocolViolations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "rbn",
+		Subsystem: "p2p",
+		Name:      "protocol_violations_total",
+		Help:      "Number of peers dropped for protocol violations",
+	})
+)
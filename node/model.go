@@ -1,5 +1,13 @@
 package node
 
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus/poa"
+	"github.com/rovergulf/chain/consensus/raft"
+	"github.com/rovergulf/chain/core/types"
+)
+
 type CallRequest struct {
 	Code uint64 `json:"code" yaml:"code"`
 	Data []byte `json:"data" yaml:"data"`
@@ -12,12 +20,101 @@ type CallResult struct {
 	Data []byte `json:"data" yaml:"data"`
 }
 
-// StatusResult
+// StatusResult is the node chain status exchanged by peers on the rbn protocol handshake
 type StatusResult struct {
-	Head      string `json:"head" yaml:"head"`
-	Genesis   string `json:"genesis"  yaml:"genesis"`
-	NetworkId string `json:"network_id" yaml:"network_id"`
-	Uptime    int64  `json:"uptime" yaml:"uptime"`
+	ProtocolVersion uint32      `json:"protocol_version" yaml:"protocol_version"`
+	NetworkId       uint64      `json:"network_id" yaml:"network_id"`
+	TotalWeight     uint64      `json:"total_weight" yaml:"total_weight"`
+	Head            common.Hash `json:"head" yaml:"head"`
+	Number          uint64      `json:"number" yaml:"number"`
+	Genesis         common.Hash `json:"genesis" yaml:"genesis"`
+}
+
+// BlockAnnounce is the block hash and number announced by NewBlockHashesMsg
+type BlockAnnounce struct {
+	Hash   common.Hash `json:"hash" yaml:"hash"`
+	Number uint64      `json:"number" yaml:"number"`
+}
+
+// NewBlockData is the block propagated by NewBlockMsg with the sender chain total weight
+type NewBlockData struct {
+	Block       *types.Block `json:"block" yaml:"block"`
+	TotalWeight uint64       `json:"total_weight" yaml:"total_weight"`
+}
+
+// GetBlockHeadersData requests headers starting from the origin block hash, or number if the hash is empty.
+// Skip headers are omitted between the returned ones, which are in descending order if reverse is set
+type GetBlockHeadersData struct {
+	RequestId uint64      `json:"request_id" yaml:"request_id"`
+	Hash      common.Hash `json:"hash" yaml:"hash"`
+	Number    uint64      `json:"number" yaml:"number"`
+	Amount    uint64      `json:"amount" yaml:"amount"`
+	Skip      uint64      `json:"skip" yaml:"skip"`
+	Reverse   bool        `json:"reverse" yaml:"reverse"`
+}
+
+// BlockHeadersData is the reply to GetBlockHeadersData request with the same id
+type BlockHeadersData struct {
+	RequestId uint64               `json:"request_id" yaml:"request_id"`
+	Headers   []*types.BlockHeader `json:"headers" yaml:"headers"`
+}
+
+// GetBlockBodiesData requests bodies of the blocks by their hashes
+type GetBlockBodiesData struct {
+	RequestId uint64        `json:"request_id" yaml:"request_id"`
+	Hashes    []common.Hash `json:"hashes" yaml:"hashes"`
+}
+
+// BlockBody is the block transactions and evidence, which are committed by its header hashes
+type BlockBody struct {
+	Transactions []*types.SignedTx `json:"transactions" yaml:"transactions"`
+	Evidence     []*types.Evidence `json:"evidence" yaml:"evidence"`
+}
+
+// BlockBodiesData is the reply to GetBlockBodiesData request with the same id
+type BlockBodiesData struct {
+	RequestId uint64       `json:"request_id" yaml:"request_id"`
+	Bodies    []*BlockBody `json:"bodies" yaml:"bodies"`
+}
+
+// GetAddressTxsData is the filter query of the blocks within numbers range, which contain transactions
+// sent or received by any of the addresses
+type GetAddressTxsData struct {
+	RequestId uint64           `json:"request_id" yaml:"request_id"`
+	Addresses []common.Address `json:"addresses" yaml:"addresses"`
+	From      uint64           `json:"from" yaml:"from"`
+	To        uint64           `json:"to" yaml:"to"`
+}
+
+// AddressBlock is the block transactions of the queried addresses and their receipts,
+// which inclusion is proven by the Merkle branches of the block header roots. Block, which header bloom
+// matches the addresses falsely, has all of its transactions in the body instead
+type AddressBlock struct {
+	BlockHash    common.Hash       `json:"block_hash" yaml:"block_hash"`
+	Proof        *types.BlockProof `json:"proof" yaml:"proof" rlp:"nil"`
+	Transactions []*types.SignedTx `json:"transactions" yaml:"transactions"`
+	Receipts     []*types.Receipt  `json:"receipts" yaml:"receipts"`
+	Body         []*types.SignedTx `json:"body" yaml:"body"`
+}
+
+// AddressTxsData is the reply to GetAddressTxsData request with the same id. Blocks are in chain order,
+// and the reply has every block up to the last number, which header bloom matches any of the addresses.
+// The last number is below the requested range start, if the peer has no blocks of the range
+type AddressTxsData struct {
+	RequestId uint64          `json:"request_id" yaml:"request_id"`
+	Blocks    []*AddressBlock `json:"blocks" yaml:"blocks"`
+	Last      uint64          `json:"last" yaml:"last"`
+}
+
+// SyncProgress is the chain sync progress: blocks are imported from the starting number,
+// the current one is the last imported block and the highest is the sync peer head
+type SyncProgress struct {
+	Mode     SyncMode `json:"mode" yaml:"mode"`
+	Syncing  bool     `json:"syncing" yaml:"syncing"`
+	Starting uint64   `json:"starting" yaml:"starting"`
+	Current  uint64   `json:"current" yaml:"current"`
+	Highest  uint64   `json:"highest" yaml:"highest"`
+	Headers  uint64   `json:"headers" yaml:"headers"`
 }
 
 type PeerInfo struct {
@@ -47,9 +144,64 @@ type BalanceSyncResult struct {
 }
 
 type TxAddRequest struct {
-	From    string  `json:"from" yaml:"from"`
-	FromPwd string  `json:"from_pwd" yaml:"from_pwd"`
-	To      string  `json:"to" yaml:"to"`
-	Value   float64 `json:"value" yaml:"value"`
-	Data    []byte  `json:"data" yaml:"data"`
+	From    string      `json:"from" yaml:"from"`
+	FromPwd string      `json:"from_pwd" yaml:"from_pwd"`
+	To      string      `json:"to" yaml:"to"`
+	Value   json.Number `json:"value" yaml:"value"` // coins amount, like "1.5"
+	Data    []byte      `json:"data" yaml:"data"`
+}
+
+// TxReplaceRequest replaces pool transaction with the same nonce by the one with higher nether price.
+// Cancel replaces it with zero value transaction sent to the sender itself
+type TxReplaceRequest struct {
+	From        string `json:"from" yaml:"from"`
+	FromPwd     string `json:"from_pwd" yaml:"from_pwd"`
+	Nonce       uint64 `json:"nonce" yaml:"nonce"`
+	NetherPrice uint64 `json:"nether_price,omitempty" yaml:"nether_price,omitempty"` // minimal replacement price is used, if lower
+	Cancel      bool   `json:"cancel" yaml:"cancel"`
+}
+
+// SignerProposalRequest adds this node vote to authorize or drop the block signer
+type SignerProposalRequest struct {
+	Address   string `json:"address" yaml:"address"`
+	Authorize bool   `json:"authorize" yaml:"authorize"`
+}
+
+// SignersResult represents authorized block signers and votes at the chain head
+type SignersResult struct {
+	Number    uint64                  `json:"number" yaml:"number"`
+	Hash      common.Hash             `json:"hash" yaml:"hash"`
+	Signers   []common.Address        `json:"signers" yaml:"signers"`
+	Votes     []*poa.Vote             `json:"votes" yaml:"votes"`
+	Proposals map[common.Address]bool `json:"proposals" yaml:"proposals"` // this node votes
+}
+
+// RaftMemberRequest adds the node account to the raft cluster voters
+type RaftMemberRequest struct {
+	Account string `json:"account" yaml:"account"`
+	Address string `json:"address" yaml:"address"` // raft transport address
+}
+
+// RaftResult represents this node raft state and the cluster members
+type RaftResult struct {
+	State   string        `json:"state" yaml:"state"`
+	Members []raft.Member `json:"members" yaml:"members"`
+}
+
+// TxResult represents transaction lookup result with its status
+type TxResult struct {
+	Status string               `json:"status" yaml:"status"`
+	Tx     *types.SignedTx      `json:"tx" yaml:"tx"`
+	Lookup *types.TxLookupEntry `json:"lookup,omitempty" yaml:"lookup,omitempty"`
+}
+
+const (
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+// AddressTxsResult represents address transactions history page
+type AddressTxsResult struct {
+	Txs        []*types.AddressTx `json:"txs" yaml:"txs"`
+	NextCursor string             `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
 }
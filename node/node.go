@@ -7,7 +7,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/gorilla/mux"
+	"github.com/rovergulf/chain/consensus"
 	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/txpool"
 	"github.com/rovergulf/chain/core/types"
 	"github.com/rovergulf/chain/database/badgerdb"
 	"github.com/rovergulf/chain/params"
@@ -17,6 +19,8 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -49,10 +53,18 @@ type Node struct {
 
 	inGenRace bool
 
+	engine       consensus.Engine
+	txAdded      chan struct{}      // signals block producer in the dev seal mode
+	stopProducer context.CancelFunc // stops block production loop
+	producerWg   sync.WaitGroup
+
 	knownPeers knownPeers
+	peers      *peerSet    // connected peers, which have completed the handshake
+	downloader *downloader // chain sync requests
 
 	// network state
-	pendingState *pendingState
+	txPool   *txpool.TxPool
+	evidence *evidencePool // misbehavior evidence to be included in the sealed blocks
 
 	newSyncBlocks chan types.Block    // ??
 	newSyncTXs    chan types.SignedTx // ??
@@ -81,10 +93,15 @@ func New(opts params.Options) (*Node, error) {
 		},
 		config:         opts,
 		logger:         opts.Logger,
+		knownPeers:     newKnownPeers(),
+		peers:          newPeerSet(),
+		downloader:     newDownloader(),
 		blockBroadcast: make(chan types.Block),
 		blockAnnounce:  make(chan types.BlockHeader),
 		txBroadcast:    make(chan []common.Hash),
 		txAnnounce:     make(chan []common.Hash),
+		txAdded:        make(chan struct{}, 1),
+		evidence:       newEvidencePool(),
 	}
 
 	return n, nil
@@ -128,6 +145,13 @@ func (n *Node) Init(ctx context.Context) error {
 		return err
 	}
 
+	if _, err := syncMode(); err != nil {
+		n.logger.Errorf("Unable to set sync mode: %s", err)
+		return err
+	}
+
+	n.txPool = txpool.New(txPoolConfig(), chain, n.logger)
+
 	n.wm, err = wallets.NewManager(n.config)
 	if err != nil {
 		n.logger.Errorf("Unable to init wallets manager: %s", err)
@@ -140,14 +164,63 @@ func (n *Node) Init(ctx context.Context) error {
 	}
 	n.logger.Debugf("Node account: %s", n.account.Address())
 
-	//if err := n.syncKeystoreBalances(ctx); err != nil {
-	//	n.logger.Errorf("Unable to sync keystore balances with chain state: %s", err)
-	//}
+	gen, err := chain.GetGenesis(ctx)
+	if err != nil {
+		n.logger.Errorf("Unable to load genesis: %s", err)
+		return err
+	}
+
+	if err := n.setupEngine(chain, gen); err != nil {
+		n.logger.Errorf("Unable to setup consensus engine: %s", err)
+		return err
+	}
+
+	// journal transactions are validated against the current chain state again
+	if err := n.txPool.LoadJournal(func(tx *types.SignedTx) error {
+		_, err := n.AddLocalTX(ctx, *tx)
+		return err
+	}); err != nil {
+		n.logger.Errorf("Unable to load local transactions journal: %s", err)
+		return err
+	}
 
 	return nil
 }
 
+// txPoolConfig returns transaction pool configuration, relative journal path is located in data directory
+func txPoolConfig() txpool.Config {
+	config := txpool.DefaultConfig
+
+	if journal := viper.GetString("txpool.journal"); journal != "" {
+		if !filepath.IsAbs(journal) {
+			journal = filepath.Join(viper.GetString("data_dir"), journal)
+		}
+		config.Journal = journal
+	}
+
+	if rejournal := viper.GetInt("txpool.rejournal"); rejournal > 0 {
+		config.Rejournal = time.Duration(rejournal) * time.Second
+	}
+
+	if viper.IsSet("txpool.pricebump") {
+		config.PriceBump = viper.GetUint64("txpool.pricebump")
+	}
+
+	return config
+}
+
 func (n *Node) Run(ctx context.Context) error {
+	go n.handleChainReorgs(ctx)
+	go n.handleChainHeads(ctx)
+	go n.rotateTxJournal(ctx)
+	go n.syncLoop(ctx)
+
+	if err := n.startEngine(); err != nil {
+		n.logger.Errorf("Unable to start consensus engine: %s", err)
+		return err
+	}
+	n.startProducer(ctx)
+
 	go func() {
 		nodeAddress := fmt.Sprintf("%s:%d",
 			viper.GetString("node.addr"), viper.GetInt("node.port"))
@@ -170,6 +243,13 @@ func (n *Node) Shutdown() {
 	//close(n.newSyncTXs)
 	//close(n.newSyncBlocks)
 
+	// block being sealed is inserted before the chain database is closed
+	if n.stopProducer != nil {
+		n.stopProducer()
+		n.producerWg.Wait()
+	}
+	n.stopEngine()
+
 	if n.srv != nil {
 		n.srv.Stop()
 	}
@@ -180,6 +260,12 @@ func (n *Node) Shutdown() {
 		}
 	}
 
+	if n.txPool != nil {
+		if err := n.txPool.Close(); err != nil {
+			n.logger.Errorf("Unable to close transactions journal: %s", err)
+		}
+	}
+
 	if n.bc != nil {
 		n.bc.Shutdown()
 	}
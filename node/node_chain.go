@@ -1,130 +1,126 @@
 package node
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/opentracing/opentracing-go"
+	"github.com/rovergulf/chain/consensus"
 	"github.com/rovergulf/chain/core"
 	"github.com/rovergulf/chain/core/types"
 	"github.com/rovergulf/chain/params"
+	"math/big"
 	"time"
 )
 
 var (
 	ErrNoTxAvailable = fmt.Errorf("no transactions available")
+	ErrForgedTx      = fmt.Errorf("transaction sender is forged")
+	ErrRewardTx      = fmt.Errorf("reward transactions are added by block producer only")
+	ErrLegacyTx      = fmt.Errorf("legacy signed transactions are not bound to chain")
 )
 
-func (n *Node) generateBlock(ctx context.Context) (*types.Block, error) {
+// generateBlock seals a new block on top of the canonical chain head with the pool transactions
+func (n *Node) generateBlock(ctx context.Context, config producerConfig) (*types.Block, error) {
 	if n.tracer != nil {
 		span := n.tracer.StartSpan("generate_block")
 		defer span.Finish()
 		ctx = opentracing.ContextWithSpan(ctx, span)
 	}
 
-	if n.pendingState.pendingTxLen() == 0 {
-		return nil, ErrNoTxAvailable
+	lb, err := n.bc.GetBlock(n.bc.LastHash)
+	if err != nil {
+		return nil, err
 	}
 
-	txs := n.pendingState.getTxsAsArray(params.TxPerBlockLimit)
-
-	lb, err := n.bc.GetBlock(n.bc.LastHash)
+	slot, err := n.nextSlot(ctx, &lb.BlockHeader)
 	if err != nil {
 		return nil, err
 	}
 
+	// fallback author seals block only if the authors before it have missed their slots
+	if slot == nil || time.Now().Unix() < slot.NotBefore {
+		return nil, fmt.Errorf("%w: block %d", consensus.ErrNotAuthor, lb.Number+1)
+	}
+
+	txs := n.txPool.Select(params.TxPerBlockLimit)
+	if len(txs) == 0 && config.skipEmpty {
+		return nil, ErrNoTxAvailable
+	}
+
+	timestamp := time.Now().Unix()
+	if timestamp < slot.NotBefore {
+		timestamp = slot.NotBefore
+	}
+
 	header := types.BlockHeader{
-		Root:      lb.Root,
 		PrevHash:  lb.BlockHash,
 		Number:    lb.Number + 1,
-		Timestamp: time.Now().Unix(),
-		Coinbase:  n.account.Address(),
+		Timestamp: timestamp,
 	}
-
-	if core.IsHashEmpty(header.Root) {
-		gb, err := n.bc.GetGenesisBlock(ctx)
-		if err != nil {
-			return nil, err
-		}
-		header.Root = gb.BlockHash
+	if err := n.engine.Prepare(ctx, &header); err != nil {
+		return nil, err
 	}
 
 	b := types.NewBlock(header, txs)
 
-	var txHashes [][]byte
+	b.NetherUsed = new(big.Int)
 	for _, tx := range b.Transactions {
-		txHash, err := tx.Hash()
-		if err != nil {
-			return nil, err
-		}
-		txHashes = append(txHashes, txHash)
-		b.NetherUsed += tx.Nether
+		b.NetherUsed.Add(b.NetherUsed, tx.Nether)
 	}
 
-	rewardTxs, err := n.genRewardTxs(b)
-	if err != nil {
+	if err := n.engine.Finalize(ctx, b); err != nil {
 		return nil, err
 	}
-	b.Transactions = append(b.Transactions, rewardTxs...)
-	txs = append(txs, rewardTxs...)
-
-	b.TxHash = sha256.Sum256(bytes.Join(txHashes, []byte{}))
 
-	blockHash, err := b.Hash()
+	txHash, err := b.HashTransactions()
 	if err != nil {
 		return nil, err
 	}
-	b.BlockHash = common.BytesToHash(blockHash)
-
-	n.removeAppliedPendingTXs(ctx, b)
+	b.TxHash = common.BytesToHash(txHash)
+	b.Bloom = types.CreateBloom(b.Transactions)
 
-	if err := n.bc.AddBlock(b); err != nil {
+	b.Evidence = n.pendingEvidence(params.EvidencePerBlockLimit)
+	if b.EvidenceHash, err = b.HashEvidence(); err != nil {
 		return nil, err
 	}
-
-	if err := n.bc.ApplyBlock(ctx, b); err != nil {
+	if b.Punished, err = n.bc.Punished(ctx, b); err != nil {
 		return nil, err
 	}
 
-	return b, nil
-}
-
-func (n *Node) genRewardTxs(b *types.Block) ([]*types.SignedTx, error) {
-	var txs []*types.SignedTx
-
-	peers := n.knownPeers.GetPeers()
-
-	// add this node to separate reward between all peers
-	peers[n.metadata.TcpAddress()] = n.metadata
-
-	peersAward := b.NetherUsed / uint64(len(peers))
-
-	perPeer := peersAward / uint64(len(peers))
-
-	for addr := range peers {
-		peer := peers[addr]
-
-		amount := perPeer
-		if peer.account == b.Coinbase {
-			amount += params.NetherLimit
-		}
+	root, receipts, err := n.bc.ExecuteBlock(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	b.Root = root
+	if b.ReceiptHash, err = types.HashReceipts(receipts); err != nil {
+		return nil, err
+	}
 
-		tx, err := types.NewTransaction(common.HexToAddress(""), peer.account, amount, 0, types.TxRewardData)
-		if err != nil {
-			return nil, err
-		}
+	if err := n.engine.Seal(ctx, b); err != nil {
+		return nil, err
+	}
 
-		signedTx, err := n.account.SignTx(&tx)
-		if err != nil {
-			return nil, err
-		}
+	blockHash, err := b.Hash()
+	if err != nil {
+		return nil, err
+	}
+	b.BlockHash = common.BytesToHash(blockHash)
 
-		txs = append(txs, signedTx)
+	// replicated blocks are inserted once they are committed by the engine
+	if committer, ok := n.engine.(consensus.Committer); ok {
+		err = committer.Commit(ctx, b)
+	} else {
+		err = n.bc.InsertBlock(ctx, b)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return txs, nil
+	n.removeAppliedPendingTXs(ctx, b)
+	n.removeIncludedEvidence(b)
+
+	return b, nil
 }
 
 func (n *Node) removeAppliedPendingTXs(ctx context.Context, block *types.Block) {
@@ -134,11 +130,6 @@ func (n *Node) removeAppliedPendingTXs(ctx context.Context, block *types.Block)
 		ctx = opentracing.ContextWithSpan(ctx, span)
 	}
 
-	pendingTxLen := n.pendingState.pendingTxLen()
-	if len(block.Transactions) > 0 && pendingTxLen > 0 {
-		n.logger.Info("Updating in-memory Pending TXs Pool:")
-	}
-
 	for _, tx := range block.Transactions {
 		txHash, err := tx.Transaction.Hash()
 		if err != nil {
@@ -146,11 +137,24 @@ func (n *Node) removeAppliedPendingTXs(ctx context.Context, block *types.Block)
 			continue
 		}
 
-		n.pendingState.removeTx(common.BytesToHash(txHash))
+		n.txPool.Remove(common.BytesToHash(txHash))
 	}
+
+	// pending transactions are rebuilt from the new chain head accounts nonces
+	n.txPool.Reset()
 }
 
+// AddPendingTX validates transaction received from the peer and adds it to the pool
 func (n *Node) AddPendingTX(ctx context.Context, tx types.SignedTx, peer PeerNode) (*types.Receipt, error) {
+	return n.addTx(ctx, tx, false)
+}
+
+// AddLocalTX validates transaction submitted to this node and adds it to the pool and its journal
+func (n *Node) AddLocalTX(ctx context.Context, tx types.SignedTx) (*types.Receipt, error) {
+	return n.addTx(ctx, tx, true)
+}
+
+func (n *Node) addTx(ctx context.Context, tx types.SignedTx, local bool) (*types.Receipt, error) {
 	if n.tracer != nil {
 		span := n.tracer.StartSpan("add_pending_tx")
 		defer span.Finish()
@@ -158,30 +162,30 @@ func (n *Node) AddPendingTX(ctx context.Context, tx types.SignedTx, peer PeerNod
 	}
 
 	if tx.IsReward() {
-		fmt.Println("\t-_-\treward tx")
+		return nil, fmt.Errorf("%w: sender '%s'", ErrRewardTx, tx.From)
 	}
 
-	ok, err := tx.IsAuthentic()
+	if tx.Signing != types.SigningChain {
+		return nil, fmt.Errorf("%w: signing version %d", ErrLegacyTx, tx.Signing)
+	}
+
+	ok, err := tx.IsAuthentic(n.bc.Signer())
 	if err != nil {
 		return nil, err
 	}
 
 	if !ok {
-		// TODO set report counter and attacker account purge
-		return nil, fmt.Errorf("wrong TX. Sender '%s' is forged", tx.From)
+		return nil, fmt.Errorf("%w: sender '%s'", ErrForgedTx, tx.From)
 	}
 
-	balance, ok := n.pendingState.getBalance(tx.From)
-	if !ok {
-		accountBalance, err := n.bc.GetBalance(tx.From)
-		if err != nil {
-			return nil, err
-		} else {
-			if err := n.pendingState.addBalance(tx.From, accountBalance); err != nil {
-				return nil, err
-			}
-			balance = accountBalance
-		}
+	var replaced *types.SignedTx
+	if local {
+		replaced, err = n.txPool.AddLocal(&tx)
+	} else {
+		replaced, err = n.txPool.Add(&tx)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	txHash, err := tx.Transaction.Hash()
@@ -189,24 +193,114 @@ func (n *Node) AddPendingTX(ctx context.Context, tx types.SignedTx, peer PeerNod
 		return nil, err
 	}
 
-	hash := common.BytesToHash(txHash)
+	if replaced != nil {
+		n.logger.Infow("Replaced pending tx", "from", tx.From, "nonce", tx.Nonce,
+			"old_price", replaced.NetherPrice, "new_price", tx.NetherPrice)
+	}
+	n.broadcastTxs(common.BytesToHash(txHash))
+	n.notifyProducer()
+
+	// sender balance, which is left after all of its pool transactions
+	balance := new(big.Int)
+	accountBalance, err := n.bc.GetBalance(tx.From)
+	if err == nil {
+		balance.Set(accountBalance.Balance)
+	} else if err != core.ErrBalanceNotExists {
+		return nil, err
+	}
 
-	balance.Balance -= tx.Cost()
-	balance.Nonce = tx.Nonce
+	pending, queued := n.txPool.Content(tx.From)
+	for _, poolTx := range append(pending, queued...) {
+		balance.Sub(balance, poolTx.Cost())
+	}
 
 	receipt := &types.Receipt{
 		Addr:        tx.From,
 		Status:      0,
-		Balance:     balance.Balance,
+		Balance:     balance,
 		NetherUsed:  tx.Nether,
 		NetherPrice: tx.NetherPrice,
-		TxHash:      hash,
+		TxHash:      common.BytesToHash(txHash),
 		TxIndex:     0,
 	}
 
-	if err := n.pendingState.addTx(hash, &tx); err != nil {
-		return nil, err
+	return receipt, nil
+}
+
+// handleChainReorgs keeps pending transactions consistent with the canonical chain:
+// transactions of dropped blocks are returned to the pending state, and transactions
+// of the new branch are removed from it
+func (n *Node) handleChainReorgs(ctx context.Context) {
+	reorgs := make(chan core.ChainReorgEvent)
+	sub := n.bc.SubscribeChainReorg(reorgs)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-sub.Err():
+			if err != nil {
+				n.logger.Errorf("Chain reorg subscription failed: %s", err)
+			}
+			return
+		case ev := <-reorgs:
+			n.logger.Infow("Updating pending TXs after chain reorg", "new_head", ev.NewHead,
+				"dropped", len(ev.DroppedTxs), "applied", len(ev.AppliedTxs))
+
+			n.removeAppliedPendingTXs(ctx, &types.Block{Transactions: ev.AppliedTxs})
+
+			for _, tx := range ev.DroppedTxs {
+				if _, err := n.AddPendingTX(ctx, *tx, n.metadata); err != nil {
+					n.logger.Warnf("Unable to return dropped tx to pending state: %s", err)
+				}
+			}
+		}
 	}
+}
 
-	return receipt, nil
+// handleChainHeads re-validates transactions pool against the state of every new chain head
+func (n *Node) handleChainHeads(ctx context.Context) {
+	heads := make(chan core.ChainHeadEvent)
+	sub := n.bc.SubscribeChainHead(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-sub.Err():
+			if err != nil {
+				n.logger.Errorf("Chain head subscription failed: %s", err)
+			}
+			return
+		case ev := <-heads:
+			n.removeIncludedEvidence(ev.Block)
+			n.txPool.Reset()
+			pending, queued := n.txPool.Stats()
+			n.logger.Debugw("Transactions pool re-validated", "head", ev.Block.Number,
+				"pending", pending, "queued", queued)
+			// imported sync blocks are announced once the sync is done
+			if !n.downloader.isSyncing() {
+				n.broadcastStatus(ctx)
+			}
+		}
+	}
+}
+
+// rotateTxJournal periodically rewrites local transactions journal to drop mined and stale transactions
+func (n *Node) rotateTxJournal(ctx context.Context) {
+	ticker := time.NewTicker(txPoolConfig().Rejournal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.txPool.Rotate(); err != nil {
+				n.logger.Errorf("Unable to rotate local transactions journal: %s", err)
+			}
+		}
+	}
 }
@@ -1,7 +1,9 @@
 package node
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -56,18 +58,41 @@ func (n *Node) getTrustedNodes() []*enode.Node {
 func (n *Node) getServerProtocols() []p2p.Protocol {
 	var protos []p2p.Protocol
 	protos = append(protos, p2p.Protocol{
-		Name:    "rbn",
-		Version: 1,
-		Length:  2,
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  AddressTxsMsg + 1,
 		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 			peer := NewPeer(p, rw)
 			defer peer.Close()
 
+			if err := n.handshake(context.Background(), peer); err != nil {
+				reason := handshakeDiscReason(err)
+				peerHandshakeFailures.WithLabelValues(reason.String()).Inc()
+				n.logger.Debugw("Peer handshake failed", "id", peer.id, "reason", reason, "err", err)
+				// disconnect reason is returned as is, so the peer receives it instead of subprotocol error
+				peer.peer.Disconnect(reason)
+				return reason
+			}
+			peerHandshakes.Inc()
+
+			n.peers.Register(peer)
+			defer n.peers.Unregister(peer.id)
+			n.downloader.triggerSync()
+
 			go n.announceTx(peer)
 			go n.announceBlocks(peer)
 
 			n.logger.Infow("New peer", "id", peer.id)
-			return n.runPeer(peer)
+			if err := n.runPeer(peer); err != nil {
+				if errors.Is(err, ErrInvalidMsg) {
+					peerProtocolViolations.Inc()
+					n.logger.Debugw("Dropped peer on protocol violation", "id", peer.id, "err", err)
+					peer.peer.Disconnect(p2p.DiscProtocolError)
+					return p2p.DiscProtocolError
+				}
+				return err
+			}
+			return nil
 		},
 		NodeInfo: n.Info,
 		//PeerInfo: func(id enode.ID) interface{} {
@@ -85,6 +110,11 @@ func (n *Node) connectPeers(ctx context.Context) error {
 	return nil
 }
 
+const (
+	ProtocolName    = "rbn"
+	ProtocolVersion = 3 // rbn/3 relays transactions signed by the relaying node account
+)
+
 const (
 	StatusMsg = iota
 	NewBlockHashesMsg
@@ -101,105 +131,111 @@ const (
 	NewPooledTransactionHashesMsg
 	GetPooledTransactionsMsg
 	PooledTransactionsMsg
+	GetAddressTxsMsg
+	AddressTxsMsg
 )
 
 var (
 	handshakeTimeout = 5 * time.Second
+	maxStatusMsgSize = uint32(1024)
+	maxMsgSize       = uint32(10 * 1024 * 1024)
+)
+
+var (
+	ErrNoStatusMsg             = errors.New("first message is not status")
+	ErrStatusMsgTooLarge       = errors.New("status message is too large")
+	ErrInvalidStatusMsg        = errors.New("invalid status message")
+	ErrProtocolVersionMismatch = errors.New("protocol version mismatch")
+	ErrNetworkIdMismatch       = errors.New("network id mismatch")
+	ErrGenesisMismatch         = errors.New("genesis block mismatch")
+	ErrInvalidMsg              = errors.New("invalid message")
 )
 
+// peerHandler handles the peer message payload and returns the reply message, if there is any
+type peerHandler func(ctx context.Context, p *Peer, payload []byte) (*CallResult, error)
+
+// peerHandlers returns the rbn protocol message handlers by message code
+func (n *Node) peerHandlers() map[uint64]peerHandler {
+	return map[uint64]peerHandler{
+		StatusMsg:                     n.handleStatusMsg,
+		NewBlockHashesMsg:             n.handleNewBlockHashesMsg,
+		TransactionsMsg:               n.handleTransactionsMsg,
+		GetBlockHeadersMsg:            n.handleGetBlockHeadersMsg,
+		BlockHeadersMsg:               n.handleBlockHeadersMsg,
+		GetBlockBodiesMsg:             n.handleGetBlockBodiesMsg,
+		BlockBodiesMsg:                n.handleBlockBodiesMsg,
+		NewBlockMsg:                   n.handleNewBlockMsg,
+		GetNodeDataMsg:                n.handleGetNodeDataMsg,
+		NodeDataMsg:                   n.handleNodeDataMsg,
+		GetReceiptsMsg:                n.handleGetReceiptsMsg,
+		ReceiptsMsg:                   n.handleReceiptsMsg,
+		NewPooledTransactionHashesMsg: n.handleNewPooledTransactionHashesMsg,
+		GetPooledTransactionsMsg:      n.handleGetPooledTransactionsMsg,
+		PooledTransactionsMsg:         n.handlePooledTransactionsMsg,
+		GetAddressTxsMsg:              n.handleGetAddressTxsMsg,
+		AddressTxsMsg:                 n.handleAddressTxsMsg,
+	}
+}
+
+// runPeer handles peer messages until the connection is closed or the peer violates the protocol,
+// which is reported by ErrInvalidMsg. Handler failures of valid messages are logged and the peer is kept
 func (n *Node) runPeer(p *Peer) error {
+	handlers := n.peerHandlers()
+	for {
+		if err := n.handlePeerMsg(p, handlers); err != nil {
+			return err
+		}
+	}
+}
+
+// handlePeerMsg reads the next peer message, handles it and sends the reply
+func (n *Node) handlePeerMsg(p *Peer, handlers map[uint64]peerHandler) error {
 	ctx := context.Background()
 
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
 	var span opentracing.Span
 	if n.tracer != nil {
 		span = n.tracer.StartSpan("handle_peer")
 		defer span.Finish()
+		span.SetTag("msg_code", msg.Code)
 		ctx = opentracing.ContextWithSpan(ctx, span)
 	}
 
-	msg, err := p.rw.ReadMsg()
-	if err != nil {
-		return err
+	if msg.Size > maxMsgSize {
+		return fmt.Errorf("%w: message size %d > %d", ErrInvalidMsg, msg.Size, maxMsgSize)
 	}
 
-	if span != nil {
-		span.SetTag("msg_code", msg.Code)
-		span.SetBaggageItem("ack", "true")
+	handler, ok := handlers[msg.Code]
+	if !ok {
+		return fmt.Errorf("%w: unknown message code %d", ErrInvalidMsg, msg.Code)
 	}
 
 	payload, err := ioutil.ReadAll(msg.Payload)
 	if err != nil {
 		return err
 	}
+	atomic.AddInt64(&n.received, 1)
 
-	if span != nil {
-		span.SetBaggageItem("read", "true")
-	}
-
-	var res *CallResult
-	switch msg.Code {
-	case NewBlockHashesMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case TransactionsMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case GetBlockHeadersMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case BlockHeadersMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case GetBlockBodiesMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case BlockBodiesMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case NewBlockMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case GetNodeDataMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case NodeDataMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case GetReceiptsMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case ReceiptsMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case NewPooledTransactionHashesMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case GetPooledTransactionsMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
-			return err
-		}
-	case PooledTransactionsMsg:
-		if res, err = nilPeerHandler(ctx, payload); err != nil {
+	res, err := handler(ctx, p, payload)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMsg) {
 			return err
 		}
-	default:
-		return fmt.Errorf("invalid message code")
+		n.logger.Warnw("Unable to handle peer message", "id", p.id, "code", msg.Code, "err", err)
+		return nil
 	}
 
 	if res != nil {
-		if err := p2p.Send(p.rw, res.Code, res.Data); err != nil {
+		if err := p.rw.WriteMsg(p2p.Msg{
+			Code:    res.Code,
+			Size:    uint32(len(res.Data)),
+			Payload: bytes.NewReader(res.Data),
+		}); err != nil {
 			n.logger.Errorw("Unable to send p2p message", "err", err)
 			return err
 		}
@@ -208,22 +244,23 @@ func (n *Node) runPeer(p *Peer) error {
 	return nil
 }
 
+// handshake exchanges chain status with the peer and stores the peer status once it is compatible
 func (n *Node) handshake(ctx context.Context, p *Peer) error {
+	status, err := n.localStatus(ctx)
+	if err != nil {
+		return err
+	}
+
 	errC := make(chan error, 2)
 
 	var peerStatus StatusResult
 
 	go func() {
-		errC <- p2p.Send(p.rw, StatusMsg, StatusResult{
-			Head:      "",
-			Genesis:   "",
-			NetworkId: "",
-			Uptime:    0,
-		})
+		errC <- p2p.Send(p.rw, StatusMsg, status)
 	}()
 
 	go func() {
-		errC <- n.readStatus(ctx, p, &peerStatus)
+		errC <- n.readStatus(ctx, p, status, &peerStatus)
 	}()
 
 	timeout := time.NewTimer(handshakeTimeout)
@@ -239,14 +276,89 @@ func (n *Node) handshake(ctx context.Context, p *Peer) error {
 		}
 	}
 
+	p.SetStatus(&peerStatus)
+	n.logger.Debugw("Peer handshake completed", "id", p.id, "head", peerStatus.Head,
+		"number", peerStatus.Number, "td", peerStatus.TotalWeight)
+
 	return nil
 }
 
-func (n *Node) readStatus(ctx context.Context, p *Peer, status *StatusResult) error {
+// localStatus returns the node chain status sent to peers on handshake
+func (n *Node) localStatus(ctx context.Context) (*StatusResult, error) {
+	genesis, err := n.bc.GetGenesisBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// header chain may be ahead of blocks, so header-only node reports its synced headers tip
+	head, err := n.bc.CurrentHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	td, err := n.bc.GetTotalWeight(head.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatusResult{
+		ProtocolVersion: ProtocolVersion,
+		NetworkId:       viper.GetUint64("network.id"),
+		TotalWeight:     td,
+		Head:            head.BlockHash,
+		Number:          head.Number,
+		Genesis:         genesis.BlockHash,
+	}, nil
+}
+
+// readStatus reads the peer status message and checks it is on the same network and chain
+func (n *Node) readStatus(ctx context.Context, p *Peer, local, status *StatusResult) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Code != StatusMsg {
+		return fmt.Errorf("%w: code %d", ErrNoStatusMsg, msg.Code)
+	}
+	if msg.Size > maxStatusMsgSize {
+		return fmt.Errorf("%w: %d > %d", ErrStatusMsgTooLarge, msg.Size, maxStatusMsgSize)
+	}
+	if err := msg.Decode(status); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidStatusMsg, err)
+	}
+
+	if status.ProtocolVersion != local.ProtocolVersion {
+		return fmt.Errorf("%w: %d; expected: %d", ErrProtocolVersionMismatch, status.ProtocolVersion, local.ProtocolVersion)
+	}
+	if status.NetworkId != local.NetworkId {
+		return fmt.Errorf("%w: %d; expected: %d", ErrNetworkIdMismatch, status.NetworkId, local.NetworkId)
+	}
+	if status.Genesis != local.Genesis {
+		return fmt.Errorf("%w: %s; expected: %s", ErrGenesisMismatch, status.Genesis, local.Genesis)
+	}
 
 	return nil
 }
 
+// handshakeDiscReason returns the reason the peer is disconnected with on handshake error
+func handshakeDiscReason(err error) p2p.DiscReason {
+	var reason p2p.DiscReason
+	switch {
+	case errors.As(err, &reason):
+		return reason
+	case errors.Is(err, ErrProtocolVersionMismatch):
+		return p2p.DiscIncompatibleVersion
+	case errors.Is(err, ErrNetworkIdMismatch), errors.Is(err, ErrGenesisMismatch):
+		return p2p.DiscUselessPeer
+	case errors.Is(err, ErrNoStatusMsg), errors.Is(err, ErrStatusMsgTooLarge), errors.Is(err, ErrInvalidStatusMsg):
+		return p2p.DiscProtocolError
+	default:
+		return p2p.DiscSubprotocolError
+	}
+}
+
 func (n *Node) announceBlocks(p *Peer) {
 	for {
 		select {
@@ -269,6 +381,15 @@ func (n *Node) announceTx(p *Peer) {
 	}
 }
 
+// broadcastTxs sends pool transactions hashes to connected peers, if there are any.
+// Replacement transaction is announced as a new one, so peers replace their pool transaction with the same nonce
+func (n *Node) broadcastTxs(hashes ...common.Hash) {
+	select {
+	case n.txBroadcast <- hashes:
+	default:
+	}
+}
+
 func (n *Node) Info() interface{} {
 	return struct {
 		Received int64 `json:"received"`
@@ -280,7 +401,3 @@ func (n *Node) Info() interface{} {
 func (n *Node) PeerInfo(id enode.ID) interface{} {
 	return nil
 }
-
-func nilPeerHandler(ctx context.Context, payload []byte) (*CallResult, error) {
-	return nil, fmt.Errorf("not implemented")
-}
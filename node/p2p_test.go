@@ -0,0 +1,198 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/wallets"
+	"math/big"
+	"testing"
+)
+
+// newTestPeerPair returns both ends of the connected peers pipe
+func newTestPeerPair(t *testing.T) (*Peer, *Peer) {
+	t.Helper()
+
+	a, b := p2p.MsgPipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+
+	pa := &Peer{id: "a", peer: p2p.NewPeer(enode.ID{1}, "a", nil), rw: a}
+	pb := &Peer{id: "b", peer: p2p.NewPeer(enode.ID{2}, "b", nil), rw: b}
+	return pa, pb
+}
+
+func TestHandshake(t *testing.T) {
+	ctx := context.Background()
+	n := newTestNode(t, nil, 0)
+	local, err := n.localStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		code   uint64
+		status func(status StatusResult) StatusResult
+		reason p2p.DiscReason
+		ok     bool
+	}{
+		{name: "same chain", code: StatusMsg, ok: true},
+		{
+			name: "other genesis",
+			code: StatusMsg,
+			status: func(status StatusResult) StatusResult {
+				status.Genesis = common.HexToHash("0x01")
+				return status
+			},
+			reason: p2p.DiscUselessPeer,
+		},
+		{
+			name: "other network",
+			code: StatusMsg,
+			status: func(status StatusResult) StatusResult {
+				status.NetworkId++
+				return status
+			},
+			reason: p2p.DiscUselessPeer,
+		},
+		{
+			name: "other protocol version",
+			code: StatusMsg,
+			status: func(status StatusResult) StatusResult {
+				status.ProtocolVersion--
+				return status
+			},
+			reason: p2p.DiscIncompatibleVersion,
+		},
+		{name: "no status message", code: TransactionsMsg, reason: p2p.DiscProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := *local
+			if tt.status != nil {
+				remote = tt.status(remote)
+			}
+
+			p, rp := newTestPeerPair(t)
+			go func() {
+				var status StatusResult
+				n.readStatus(ctx, rp, local, &status)
+				p2p.Send(rp.rw, tt.code, &remote)
+			}()
+
+			err := n.handshake(ctx, p)
+			if tt.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p.Status().Genesis != local.Genesis || p.Status().Head != local.Head {
+					t.Fatalf("peer status is not stored: %+v", p.Status())
+				}
+				return
+			}
+			if reason := handshakeDiscReason(err); reason != tt.reason {
+				t.Fatalf("expected %s, got %s: %v", tt.reason, reason, err)
+			}
+		})
+	}
+}
+
+// runTestPeer runs the node message loop of the handshaked peer of the account, and returns the peer,
+// its remote end and the loop error channel
+func runTestPeer(t *testing.T, n *Node, account common.Address, status *StatusResult) (*Peer, *Peer, chan error) {
+	t.Helper()
+
+	p, rp := newTestPeerPair(t)
+	p.account = account
+	negotiated := *status
+	p.SetStatus(&negotiated)
+	done := make(chan error, 1)
+	go func() {
+		done <- n.runPeer(p)
+	}()
+	return p, rp, done
+}
+
+func TestPeerLoop(t *testing.T) {
+	ctx := context.Background()
+	n := newTestNode(t, nil, 0)
+	local, err := n.localStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := wallets.NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, rp, done := runTestPeer(t, n, key.Address, local)
+
+	head := common.HexToHash("0x05")
+	if err := p2p.Send(rp.rw, NewBlockHashesMsg, []BlockAnnounce{{Hash: head, Number: local.Number + 7}}); err != nil {
+		t.Fatal(err)
+	}
+	// rejected transaction of the valid relay keeps the peer
+	tx, err := types.NewTransaction(key.Address, n.account.Address(), big.NewInt(1), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedTx, err := n.bc.Signer().Sign(tx, key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay, err := types.NewRelayedTx(signedTx, key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p2p.Send(rp.rw, TransactionsMsg, []*types.RelayedTx{relay}); err != nil {
+		t.Fatal(err)
+	}
+
+	// requests are replied in order, so the previous messages are handled once the reply is received
+	if err := p2p.Send(rp.rw, GetPooledTransactionsMsg, []common.Hash{common.HexToHash("0x01")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p2p.ExpectMsg(rp.rw, PooledTransactionsMsg, []*types.RelayedTx{}); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.Status(); status.Head != head || status.Number != local.Number+7 {
+		t.Fatalf("expected announced head %s of block %d, got %s of %d", head, local.Number+7, status.Head, status.Number)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("peer loop stopped: %v", err)
+	default:
+	}
+
+	// protocol violations stop the loop
+	other := *local
+	other.Genesis = common.HexToHash("0x01")
+	tests := []struct {
+		name string
+		code uint64
+		data interface{}
+	}{
+		{name: "status of other genesis", code: StatusMsg, data: &other},
+		{name: "undecodable message", code: NewBlockHashesMsg, data: []uint{1, 2}},
+		{name: "unknown message code", code: 0x7f, data: []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rp, done := runTestPeer(t, n, common.Address{}, local)
+			if err := p2p.Send(rp.rw, tt.code, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-done; !errors.Is(err, ErrInvalidMsg) {
+				t.Fatalf("expected %s, got %v", ErrInvalidMsg, err)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/rovergulf/chain/params"
@@ -16,10 +17,14 @@ import (
 type Peer struct {
 	id      string
 	version string
+	account common.Address // node account key is the peer p2p key, which is authenticated on RLPx handshake
 
 	peer *p2p.Peer
 	rw   p2p.MsgReadWriter
 
+	status *StatusResult // negotiated on handshake and updated by peer announcements
+	lock   sync.RWMutex
+
 	logger *zap.SugaredLogger
 }
 
@@ -29,6 +34,9 @@ func NewPeer(peer *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
 		peer: peer,
 		rw:   rw,
 	}
+	if pub := peer.Node().Pubkey(); pub != nil {
+		p.account = crypto.PubkeyToAddress(*pub)
+	}
 
 	return p
 }
@@ -41,7 +49,14 @@ func (p *Peer) ID() string {
 	return p.id
 }
 
+// Account returns the peer node account address
+func (p *Peer) Account() common.Address {
+	return p.account
+}
+
 func (p *Peer) Version() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.version
 }
 
@@ -49,12 +64,110 @@ func (p *Peer) Info() {
 
 }
 
+// Status returns a copy of the peer chain status, or nil if handshake is not completed yet
+func (p *Peer) Status() *StatusResult {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.status == nil {
+		return nil
+	}
+	status := *p.status
+	return &status
+}
+
+// SetStatus stores the peer chain status received on handshake
+func (p *Peer) SetStatus(status *StatusResult) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.status = status
+	p.version = fmt.Sprintf("%s/%d", ProtocolName, status.ProtocolVersion)
+}
+
+// SetHead updates the peer chain head, once it has announced a higher block.
+// Total weight is kept, if it is not announced
+func (p *Peer) SetHead(hash common.Hash, number, td uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.status == nil || number <= p.status.Number {
+		return
+	}
+	p.status.Head = hash
+	p.status.Number = number
+	if td > p.status.TotalWeight {
+		p.status.TotalWeight = td
+	}
+}
+
+// peerSet keeps connected peers, which have completed the handshake
+type peerSet struct {
+	peers map[string]*Peer
+	lock  sync.RWMutex
+}
+
+func newPeerSet() *peerSet {
+	return &peerSet{
+		peers: make(map[string]*Peer),
+	}
+}
+
+// Register adds the peer to the set
+func (ps *peerSet) Register(p *Peer) {
+	ps.lock.Lock()
+	ps.peers[p.id] = p
+	ps.lock.Unlock()
+}
+
+// Unregister removes the peer from the set
+func (ps *peerSet) Unregister(id string) {
+	ps.lock.Lock()
+	delete(ps.peers, id)
+	ps.lock.Unlock()
+}
+
+// Len returns the number of connected peers
+func (ps *peerSet) Len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+	return len(ps.peers)
+}
+
+// List returns connected peers, so they may be iterated without lock
+func (ps *peerSet) List() []*Peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*Peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
+// Best returns the peer with the heaviest chain, or nil if there are no peers
+func (ps *peerSet) Best() *Peer {
+	var best *Peer
+	var bestTd uint64
+	for _, p := range ps.List() {
+		status := p.Status()
+		if status == nil {
+			continue
+		}
+		if best == nil || status.TotalWeight > bestTd {
+			best, bestTd = p, status.TotalWeight
+		}
+	}
+	return best
+}
+
 var (
 	peerPrefix = []byte("peers/")
 )
 
 func peerDbPrefix() []byte {
-	return append(peerPrefix)
+	return peerPrefix
 }
 
 type knownPeers struct {
@@ -62,6 +175,13 @@ type knownPeers struct {
 	lock  *sync.RWMutex
 }
 
+func newKnownPeers() knownPeers {
+	return knownPeers{
+		peers: make(map[string]PeerNode),
+		lock:  new(sync.RWMutex),
+	}
+}
+
 func (k knownPeers) Exists(addr string) bool {
 	k.lock.RLock()
 	_, ok := k.peers[addr]
@@ -69,10 +189,13 @@ func (k knownPeers) Exists(addr string) bool {
 	return ok
 }
 
+// GetPeers returns a copy of known peers, so it may be iterated without lock
 func (k knownPeers) GetPeers() map[string]PeerNode {
-	var peers map[string]PeerNode
 	k.lock.RLock()
-	peers = k.peers
+	peers := make(map[string]PeerNode, len(k.peers))
+	for addr, peer := range k.peers {
+		peers[addr] = peer
+	}
 	k.lock.RUnlock()
 	return peers
 }
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/consensus/raft"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// producerConfig is block production loop configuration
+type producerConfig struct {
+	blockTime time.Duration // interval between block sealing attempts, slots are timed by the genesis block time
+	skipEmpty bool          // do not seal blocks without pool transactions
+	devSeal   bool          // seal block as soon as transaction is added to the pool
+}
+
+func blockProducerConfig() producerConfig {
+	config := producerConfig{
+		blockTime: time.Duration(params.BlockTime) * time.Second,
+		skipEmpty: viper.GetBool("node.skip_empty_blocks"),
+		devSeal:   viper.GetBool("node.dev_seal"),
+	}
+
+	if blockTime := viper.GetInt("node.block_time"); blockTime > 0 {
+		config.blockTime = time.Duration(blockTime) * time.Second
+	}
+
+	return config
+}
+
+// Slot is the node account turn to seal the block
+type Slot struct {
+	Number    uint64         `json:"number" yaml:"number"`
+	Author    common.Address `json:"author" yaml:"author"`         // election winner
+	Rank      int            `json:"rank" yaml:"rank"`             // 0 for the winner, fallback authors follow it
+	NotBefore int64          `json:"not_before" yaml:"not_before"` // unix time the node may seal block since
+}
+
+// nextSlot returns the node account slot of the block following provided parent, or nil
+// if the node is not eligible to seal it. Election winner may seal block a genesis block time
+// after the parent block, and every next fallback author one block time later
+func (n *Node) nextSlot(ctx context.Context, parent *types.BlockHeader) (*Slot, error) {
+	authors, err := n.engine.Authors(ctx, parent)
+	if err != nil {
+		// nobody seals blocks until raft cluster elects its leader
+		if errors.Is(err, raft.ErrNoLeader) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for rank, author := range authors {
+		if author != n.account.Address() {
+			continue
+		}
+
+		return &Slot{
+			Number:    parent.Number + 1,
+			Author:    authors[0],
+			Rank:      rank,
+			NotBefore: parent.Timestamp + int64(rank+1)*n.bc.BlockTime(),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// notifyProducer wakes up block producer in the dev seal mode
+func (n *Node) notifyProducer() {
+	select {
+	case n.txAdded <- struct{}{}:
+	default:
+	}
+}
+
+// startProducer runs block production loop until the node is shut down
+func (n *Node) startProducer(ctx context.Context) {
+	ctx, n.stopProducer = context.WithCancel(ctx)
+
+	n.producerWg.Add(1)
+	go func() {
+		defer n.producerWg.Done()
+		n.produceBlocks(ctx)
+	}()
+}
+
+// produceBlocks seals a new block every configured block time, if the node account
+// is the consensus engine author of the next block, or the author has missed its slot
+func (n *Node) produceBlocks(ctx context.Context) {
+	config := blockProducerConfig()
+	n.logger.Infow("Starting block producer", "block_time", config.blockTime,
+		"skip_empty", config.skipEmpty, "dev_seal", config.devSeal)
+
+	ticker := time.NewTicker(config.blockTime)
+	defer ticker.Stop()
+
+	var txAdded <-chan struct{}
+	if config.devSeal {
+		txAdded = n.txAdded
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Block producer stopped")
+			return
+		case <-ticker.C:
+		case <-txAdded:
+		}
+
+		block, err := n.generateBlock(ctx, config)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrNoTxAvailable):
+			case errors.Is(err, consensus.ErrNotAuthor):
+				n.logger.Debugf("Skip block sealing: %s", err)
+			default:
+				n.logger.Errorf("Unable to produce block: %s", err)
+			}
+			continue
+		}
+
+		n.logger.Infow("Produced block", "number", block.Number, "hash", block.BlockHash,
+			"txs", len(block.Transactions))
+	}
+}
@@ -0,0 +1,264 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/consensus"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/txpool"
+	"github.com/rovergulf/chain/params"
+	"github.com/rovergulf/chain/wallets"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestNode returns development network node of the key account, or a new one if the key is nil,
+// which seals blocks by the genesis block time. Genesis signers are the provided accounts,
+// or the node account itself if none are provided
+func newTestNode(t *testing.T, key *keystore.Key, blockTime int64, signers ...common.Address) *Node {
+	t.Helper()
+	ctx := context.Background()
+
+	logger := zap.NewNop().Sugar()
+	opts := params.Options{DbFilePath: t.TempDir(), WalletsFilePath: t.TempDir(), Logger: logger}
+	wm, err := wallets.NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == nil {
+		if key, err = wallets.NewRandomKey(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := wm.AddWallet(key, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(signers) == 0 {
+		signers = []common.Address{w.Address()}
+	}
+	genesisSigners := make([]string, len(signers))
+	for i, signer := range signers {
+		genesisSigners[i] = signer.Hex()
+	}
+	viper.Set("network.id", params.OpenDevNetworkId)
+	viper.Set("genesis.signers", genesisSigners)
+	viper.Set("genesis.block_time", blockTime)
+
+	bc, err := core.NewBlockChain(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bc.Shutdown)
+	if err := bc.NewGenesisBlockWithRewrite(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.LoadChainState(ctx); err != nil {
+		t.Fatal(err)
+	}
+	gen, err := bc.GetGenesis(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.bc, n.wm, n.account = bc, wm, w
+	n.txPool = txpool.New(txpool.DefaultConfig, bc, logger)
+	if err := n.setupEngine(bc, gen); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+// newTestSigners returns nodes of n accounts, which are all the genesis signers
+func newTestSigners(t *testing.T, n int, blockTime int64) []*Node {
+	t.Helper()
+
+	keys := make([]*keystore.Key, n)
+	signers := make([]common.Address, n)
+	for i := range keys {
+		key, err := wallets.NewRandomKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i], signers[i] = key, key.Address
+	}
+
+	nodes := make([]*Node, n)
+	for i, key := range keys {
+		nodes[i] = newTestNode(t, key, blockTime, signers...)
+	}
+	return nodes
+}
+
+// nodeOf returns the node of the account
+func nodeOf(t *testing.T, nodes []*Node, account common.Address) *Node {
+	t.Helper()
+
+	for _, n := range nodes {
+		if n.account.Address() == account {
+			return n
+		}
+	}
+	t.Fatalf("no node of %s", account)
+	return nil
+}
+
+func TestNextSlot(t *testing.T) {
+	ctx := context.Background()
+	const blockTime = 15
+
+	nodes := newTestSigners(t, 3, blockTime)
+	genesis, err := nodes[0].bc.GetBlockHeader(nodes[0].bc.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authors, err := nodes[0].engine.Authors(ctx, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// election winner may seal block a block time after the parent, and every fallback author one block time later
+	for rank, author := range authors {
+		slot, err := nodeOf(t, nodes, author).nextSlot(ctx, genesis)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Slot{Number: 1, Author: authors[0], Rank: rank, NotBefore: genesis.Timestamp + blockTime*int64(rank+1)}
+		if slot == nil || *slot != want {
+			t.Fatalf("rank %d: expected slot %+v, got %+v", rank, want, slot)
+		}
+	}
+
+	signers := make([]common.Address, len(nodes))
+	for i, n := range nodes {
+		signers[i] = n.account.Address()
+	}
+	outsider := newTestNode(t, nil, blockTime, signers...)
+	if slot, err := outsider.nextSlot(ctx, genesis); err != nil || slot != nil {
+		t.Fatalf("expected no slot of the outsider, got %+v, %v", slot, err)
+	}
+}
+
+func TestGenerateBlockSlot(t *testing.T) {
+	ctx := context.Background()
+	const blockTime = 15
+
+	n := newTestNode(t, nil, blockTime)
+	genesis, err := n.bc.GetBlockHeader(n.bc.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// genesis is old enough, so the first block is sealed right away with the current time
+	start := time.Now().Unix()
+	block, err := n.generateBlock(ctx, producerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Timestamp < start || block.Timestamp < genesis.Timestamp+blockTime {
+		t.Fatalf("unexpected block timestamp %d, genesis %d, start %d", block.Timestamp, genesis.Timestamp, start)
+	}
+
+	// the next slot is a block time after the sealed block
+	if _, err := n.generateBlock(ctx, producerConfig{}); !errors.Is(err, consensus.ErrNotAuthor) {
+		t.Fatalf("expected %s, got %v", consensus.ErrNotAuthor, err)
+	}
+	if n.bc.ChainLength != 2 {
+		t.Fatalf("expected chain length 2, got %d", n.bc.ChainLength)
+	}
+}
+
+func TestFallbackAuthorBlock(t *testing.T) {
+	ctx := context.Background()
+	const blockTime = 15
+
+	nodes := newTestSigners(t, 2, blockTime)
+	genesis, err := nodes[0].bc.GetBlockHeader(nodes[0].bc.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authors, err := nodes[0].engine.Authors(ctx, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	winner, fallback := nodeOf(t, nodes, authors[0]), nodeOf(t, nodes, authors[1])
+
+	block, err := fallback.generateBlock(ctx, producerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fallback author block timestamped within the winner slot is rejected
+	early := *block
+	early.Timestamp = genesis.Timestamp + blockTime
+	if err := fallback.engine.Seal(ctx, &early); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := early.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	early.BlockHash = common.BytesToHash(hash)
+	if err := winner.bc.InsertBlock(ctx, &early); !errors.Is(err, core.ErrInvalidBlockSeal) {
+		t.Fatalf("expected %s, got %v", core.ErrInvalidBlockSeal, err)
+	}
+
+	if err := winner.bc.InsertBlock(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestElectionDeterminism(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestSigners(t, 3, 0)
+
+	// every node elects the same authors, and the winner block is accepted by all of them
+	for i := 0; i < 6; i++ {
+		head, err := nodes[0].bc.GetBlockHeader(nodes[0].bc.LastHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authors, err := nodes[0].engine.Authors(ctx, head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range nodes[1:] {
+			other, err := n.engine.Authors(ctx, head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(authors, other) {
+				t.Fatalf("block %d: expected authors %v, got %v", head.Number+1, authors, other)
+			}
+		}
+
+		winner := nodeOf(t, nodes, authors[0])
+		slot, err := winner.nextSlot(ctx, head)
+		if err != nil || slot == nil || slot.Rank != 0 {
+			t.Fatalf("block %d: expected winner slot, got %+v, %v", head.Number+1, slot, err)
+		}
+		block, err := winner.generateBlock(ctx, producerConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range nodes {
+			if n == winner {
+				continue
+			}
+			if err := n.bc.InsertBlock(ctx, block); err != nil {
+				t.Fatalf("block %d: %s", block.Number, err)
+			}
+		}
+	}
+}
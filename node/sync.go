@@ -1 +1,709 @@
 package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/rovergulf/chain/core"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/spf13/viper"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxHeaderFetch   = 192  // headers requested by the single request, skeleton headers are this far apart
+	maxSkeletonSize  = 128  // skeleton headers requested from the sync peer at once
+	maxBodyFetch     = 64   // block bodies requested by the single request
+	maxBlocksRound   = 1024 // blocks downloaded before the next batch of headers is read from the chain
+	maxFetchFailures = 3    // fetch attempts of the same task from different peers
+
+	syncInterval       = 10 * time.Second
+	syncRequestTimeout = 10 * time.Second
+)
+
+var (
+	ErrSyncTimeout     = errors.New("sync request timeout")
+	ErrInvalidHeaders  = errors.New("peer sent invalid headers")
+	ErrInvalidBodies   = errors.New("peer sent invalid block bodies")
+	ErrSyncIncomplete  = errors.New("sync tasks are not completed")
+	ErrUnknownSyncMode = errors.New("unknown sync mode")
+)
+
+// downloader keeps sync requests sent to peers until their replies are delivered, and the sync progress
+type downloader struct {
+	requestId uint64
+	pending   map[uint64]*syncRequest
+	lock      sync.Mutex
+
+	syncing  int32         // set while the sync is in progress
+	starting uint64        // the last imported block number once the sync has started
+	highest  uint64        // the sync peer head number
+	trigger  chan struct{} // triggers the sync once a peer is registered or the chain is behind the consensus
+}
+
+// syncRequest is the pending request sent to the peer
+type syncRequest struct {
+	peer string
+	resC chan interface{}
+}
+
+func newDownloader() *downloader {
+	return &downloader{
+		pending: make(map[uint64]*syncRequest),
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// register adds the pending request of the peer and returns its id
+func (d *downloader) register(peer string) (uint64, chan interface{}) {
+	id := atomic.AddUint64(&d.requestId, 1)
+	req := &syncRequest{
+		peer: peer,
+		resC: make(chan interface{}, 1),
+	}
+
+	d.lock.Lock()
+	d.pending[id] = req
+	d.lock.Unlock()
+
+	return id, req.resC
+}
+
+// unregister drops the pending request, so its late reply is ignored
+func (d *downloader) unregister(id uint64) {
+	d.lock.Lock()
+	delete(d.pending, id)
+	d.lock.Unlock()
+}
+
+// deliver passes the reply to the pending request, if it has been sent to the same peer
+func (d *downloader) deliver(peer string, id uint64, data interface{}) {
+	d.lock.Lock()
+	req, ok := d.pending[id]
+	if ok && req.peer == peer {
+		delete(d.pending, id)
+	}
+	d.lock.Unlock()
+
+	if ok && req.peer == peer {
+		req.resC <- data
+	}
+}
+
+// triggerSync starts the sync without waiting for the next sync interval
+func (d *downloader) triggerSync() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// isSyncing returns whether the sync is in progress
+func (d *downloader) isSyncing() bool {
+	return atomic.LoadInt32(&d.syncing) == 1
+}
+
+// syncMode returns the node sync mode set by node.sync_mode config value
+func syncMode() (SyncMode, error) {
+	mode := SyncMode(viper.GetString("node.sync_mode"))
+	switch mode {
+	case SyncModeDefault, SyncModeFull, SyncModeAccount:
+		return mode, nil
+	case "":
+		return SyncModeDefault, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownSyncMode, mode)
+	}
+}
+
+// SyncProgress returns the chain sync progress
+func (n *Node) SyncProgress() (*SyncProgress, error) {
+	mode, err := syncMode()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := n.bc.CurrentHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := n.syncedNumber(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	n.downloader.lock.Lock()
+	defer n.downloader.lock.Unlock()
+
+	return &SyncProgress{
+		Mode:     mode,
+		Syncing:  n.downloader.isSyncing(),
+		Starting: n.downloader.starting,
+		Current:  current,
+		Highest:  n.downloader.highest,
+		Headers:  head.Number,
+	}, nil
+}
+
+// syncedNumber returns the last block number, which is imported by the node, or which accounts transactions
+// are synced up to in account sync mode
+func (n *Node) syncedNumber(mode SyncMode) (uint64, error) {
+	if mode == SyncModeAccount {
+		return n.accountsSyncNumber()
+	}
+	return n.bc.ChainLength - 1, nil
+}
+
+// syncLoop synchronizes the chain with the best peer every node.sync_interval seconds and once a new peer is connected
+func (n *Node) syncLoop(ctx context.Context) {
+	interval := syncInterval
+	if seconds := viper.GetInt("node.sync_interval"); seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.downloader.trigger:
+		}
+
+		if err := n.synchronise(ctx); err != nil {
+			n.logger.Warnf("Unable to sync chain: %s", err)
+		}
+	}
+}
+
+// synchronise downloads headers from the peer with the heaviest chain, if it is heavier than the local one.
+// Full sync mode node downloads and imports blocks of the synced headers after them, so blocks import
+// resumes after restart from the last imported block. Account sync mode node downloads only transactions
+// of its wallets accounts
+func (n *Node) synchronise(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&n.downloader.syncing, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreInt32(&n.downloader.syncing, 0)
+
+	mode, err := syncMode()
+	if err != nil {
+		return err
+	}
+
+	peer := n.peers.Best()
+	if peer == nil {
+		return nil
+	}
+	status := peer.Status()
+
+	local, err := n.bc.CurrentHeader()
+	if err != nil {
+		return err
+	}
+	localTd, err := n.bc.GetTotalWeight(local.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	synced, err := n.syncedNumber(mode)
+	if err != nil {
+		return err
+	}
+
+	headersBehind := status.TotalWeight > localTd
+	blocksBehind := mode == SyncModeFull && local.BlockHash != n.bc.LastHash
+	accountsBehind := mode == SyncModeAccount && synced < local.Number
+	if !headersBehind && !blocksBehind && !accountsBehind {
+		return nil
+	}
+
+	n.downloader.lock.Lock()
+	n.downloader.starting = synced
+	n.downloader.highest = local.Number
+	if status.Number > local.Number {
+		n.downloader.highest = status.Number
+	}
+	n.downloader.lock.Unlock()
+
+	if headersBehind {
+		n.logger.Infow("Starting chain sync", "peer", peer.id, "mode", mode, "local", local.Number,
+			"highest", status.Number, "td", status.TotalWeight)
+
+		ancestor, err := n.findAncestor(ctx, peer, local.Number, status.Number)
+		if err != nil {
+			return fmt.Errorf("%w; peer: %s", err, peer.id)
+		}
+
+		if err := n.syncHeaders(ctx, peer, ancestor, status.Number); err != nil {
+			return fmt.Errorf("%w; peer: %s", err, peer.id)
+		}
+	}
+
+	switch mode {
+	case SyncModeFull:
+		if err := n.syncBlocks(ctx); err != nil {
+			return err
+		}
+	case SyncModeAccount:
+		if err := n.syncAccounts(ctx); err != nil {
+			return err
+		}
+	}
+
+	n.broadcastStatus(ctx)
+	return nil
+}
+
+// findAncestor looks for the highest local header of the peer chain, going back
+// from the lowest of both heads. Genesis is the same, as it is checked on handshake
+func (n *Node) findAncestor(ctx context.Context, p *Peer, local, remote uint64) (uint64, error) {
+	from := local
+	if remote < from {
+		from = remote
+	}
+
+	for {
+		amount := uint64(maxHeaderFetch)
+		if from+1 < amount {
+			amount = from + 1
+		}
+
+		headers, err := n.requestHeaders(ctx, p, &GetBlockHeadersData{
+			Number:  from,
+			Amount:  amount,
+			Reverse: true,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if uint64(len(headers)) != amount {
+			return 0, fmt.Errorf("%w: %d headers of %d", ErrInvalidHeaders, len(headers), amount)
+		}
+
+		for i, header := range headers {
+			if header.Number != from-uint64(i) {
+				return 0, fmt.Errorf("%w: header %d; expected: %d", ErrInvalidHeaders, header.Number, from-uint64(i))
+			}
+			if _, err := n.bc.GetBlockHeader(header.BlockHash); err == nil {
+				return header.Number, nil
+			}
+		}
+
+		if from < amount {
+			return 0, fmt.Errorf("%w: no common ancestor", ErrInvalidHeaders)
+		}
+		from -= amount
+	}
+}
+
+// syncHeaders downloads and inserts the peer headers above the ancestor up to the height. The sync peer
+// provides skeleton headers every maxHeaderFetch blocks, and the gaps between them are filled by all peers
+func (n *Node) syncHeaders(ctx context.Context, master *Peer, ancestor, height uint64) error {
+	for from := ancestor + 1; from <= height; {
+		count := (height - from + 1) / maxHeaderFetch
+		if count > maxSkeletonSize {
+			count = maxSkeletonSize
+		}
+
+		// the rest of headers is less than a single skeleton segment
+		if count == 0 {
+			headers, err := n.requestHeaders(ctx, master, &GetBlockHeadersData{
+				Number: from,
+				Amount: height - from + 1,
+			})
+			if err != nil {
+				return err
+			}
+			if err := checkHeadersRange(headers, from, height-from+1); err != nil {
+				return err
+			}
+			_, err = n.bc.InsertHeaders(headers)
+			return err
+		}
+
+		skeleton, err := n.requestHeaders(ctx, master, &GetBlockHeadersData{
+			Number: from + maxHeaderFetch - 1,
+			Amount: count,
+			Skip:   maxHeaderFetch - 1,
+		})
+		if err != nil {
+			return err
+		}
+		if uint64(len(skeleton)) != count {
+			return fmt.Errorf("%w: %d skeleton headers of %d", ErrInvalidHeaders, len(skeleton), count)
+		}
+
+		segments, err := n.fillSkeleton(ctx, from, skeleton)
+		if err != nil {
+			return err
+		}
+
+		for _, segment := range segments {
+			if _, err := n.bc.InsertHeaders(segment); err != nil {
+				return err
+			}
+		}
+
+		from += count * maxHeaderFetch
+	}
+
+	return nil
+}
+
+// fillSkeleton downloads headers between the skeleton ones concurrently from every peer, which has the whole skeleton
+func (n *Node) fillSkeleton(ctx context.Context, from uint64, skeleton []*types.BlockHeader) ([][]*types.BlockHeader, error) {
+	var peers []*Peer
+	top := skeleton[len(skeleton)-1].Number
+	for _, p := range n.peers.List() {
+		if status := p.Status(); status != nil && status.Number >= top {
+			peers = append(peers, p)
+		}
+	}
+
+	segments := make([][]*types.BlockHeader, len(skeleton))
+	if err := n.fetchTasks(ctx, peers, len(skeleton), func(ctx context.Context, p *Peer, i int) error {
+		start := from + uint64(i)*maxHeaderFetch
+		headers, err := n.requestHeaders(ctx, p, &GetBlockHeadersData{
+			Number: start,
+			Amount: maxHeaderFetch,
+		})
+		if err != nil {
+			return err
+		}
+		if err := checkHeadersRange(headers, start, maxHeaderFetch); err != nil {
+			return err
+		}
+		if headers[len(headers)-1].BlockHash != skeleton[i].BlockHash {
+			return fmt.Errorf("%w: segment %d does not match skeleton", ErrInvalidHeaders, i)
+		}
+
+		segments[i] = headers
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+// syncBlocks downloads and imports blocks of the header chain, which is ahead of the canonical blocks.
+// Blocks are imported from the header chain fork point, so the canonical chain is switched to it
+func (n *Node) syncBlocks(ctx context.Context) error {
+	for {
+		head, err := n.bc.CurrentHeader()
+		if err != nil {
+			return err
+		}
+		if head.BlockHash == n.bc.LastHash {
+			return nil
+		}
+
+		ancestor, err := n.blocksAncestor()
+		if err != nil {
+			return err
+		}
+
+		to := head.Number
+		if to-ancestor > maxBlocksRound {
+			to = ancestor + maxBlocksRound
+		}
+
+		headers := make([]*types.BlockHeader, 0, to-ancestor)
+		for number := ancestor + 1; number <= to; number++ {
+			header, err := n.bc.GetHeaderByNumber(number)
+			if err != nil {
+				return err
+			}
+			headers = append(headers, header)
+		}
+
+		if err := n.fetchBlocks(ctx, headers); err != nil {
+			return err
+		}
+	}
+}
+
+// blocksAncestor returns the highest canonical block number, which is the header chain block as well
+func (n *Node) blocksAncestor() (uint64, error) {
+	last, err := n.bc.GetBlockHeader(n.bc.LastHash)
+	if err != nil {
+		return 0, err
+	}
+
+	for number := last.Number; number > 0; number-- {
+		header, err := n.bc.GetHeaderByNumber(number)
+		if err != nil && !errors.Is(err, core.ErrBlockNotExists) {
+			return 0, err
+		}
+		if err != nil {
+			continue
+		}
+
+		block, err := n.bc.GetBlockByNumber(number)
+		if err != nil {
+			return 0, err
+		}
+		if block.BlockHash == header.BlockHash {
+			return number, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// bodiesResult is the fetched blocks batch
+type bodiesResult struct {
+	batch  int
+	blocks []*types.Block
+}
+
+// fetchBlocks downloads block bodies of the headers concurrently from all peers in batches,
+// and imports the blocks in order, as soon as all of the previous batches are imported
+func (n *Node) fetchBlocks(ctx context.Context, headers []*types.BlockHeader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := (len(headers) + maxBodyFetch - 1) / maxBodyFetch
+	results := make(chan bodiesResult, batches)
+	errC := make(chan error, 1)
+
+	go func() {
+		errC <- n.fetchTasks(ctx, n.peers.List(), batches, func(ctx context.Context, p *Peer, i int) error {
+			end := (i + 1) * maxBodyFetch
+			if end > len(headers) {
+				end = len(headers)
+			}
+
+			blocks, err := n.requestBlocks(ctx, p, headers[i*maxBodyFetch:end])
+			if err != nil {
+				return err
+			}
+
+			results <- bodiesResult{batch: i, blocks: blocks}
+			return nil
+		})
+	}()
+
+	ready := make(map[int][]*types.Block)
+	for next := 0; next < batches; {
+		select {
+		case res := <-results:
+			ready[res.batch] = res.blocks
+		case err := <-errC:
+			if err != nil {
+				return err
+			}
+			// every batch is fetched, the rest of results is buffered
+			errC = nil
+			continue
+		}
+
+		for ; next < batches && ready[next] != nil; next++ {
+			for _, block := range ready[next] {
+				if err := n.bc.InsertBlock(ctx, block); err != nil && !errors.Is(err, core.ErrBlockAlreadyExists) {
+					return fmt.Errorf("%w; block: %d", err, block.Number)
+				}
+			}
+			delete(ready, next)
+		}
+	}
+
+	return nil
+}
+
+// fetchTasks runs the fetch of every task concurrently by the peers, every peer takes the next task once
+// its previous one is done. Task failed by the peer is retried by the others, and the peer stops fetching
+func (n *Node) fetchTasks(ctx context.Context, peers []*Peer, count int, fetch func(ctx context.Context, p *Peer, task int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tasks := make(chan int, count)
+	for i := 0; i < count; i++ {
+		tasks <- i
+	}
+
+	var lock sync.Mutex
+	failures := make(map[int]int)
+	remaining := int32(count)
+	var fetchErr error
+
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p *Peer) {
+			defer wg.Done()
+
+			for {
+				var i int
+				select {
+				case <-ctx.Done():
+					return
+				case i = <-tasks:
+				}
+
+				if err := fetch(ctx, p, i); err != nil {
+					n.logger.Debugw("Unable to fetch sync task", "peer", p.id, "task", i, "err", err)
+
+					lock.Lock()
+					failures[i]++
+					if failures[i] >= maxFetchFailures && fetchErr == nil {
+						fetchErr = err
+						cancel()
+					}
+					lock.Unlock()
+
+					tasks <- i
+					return
+				}
+
+				if atomic.AddInt32(&remaining, -1) == 0 {
+					cancel()
+					return
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if fetchErr != nil {
+		return fetchErr
+	}
+	if left := atomic.LoadInt32(&remaining); left > 0 {
+		return fmt.Errorf("%w: %d of %d tasks left", ErrSyncIncomplete, left, count)
+	}
+
+	return nil
+}
+
+// sendRequest sends the request with a new id to the peer and waits for its reply
+func (n *Node) sendRequest(ctx context.Context, p *Peer, code uint64, request func(id uint64) interface{}) (interface{}, error) {
+	id, resC := n.downloader.register(p.id)
+	defer n.downloader.unregister(id)
+
+	if err := p2p.Send(p.rw, code, request(id)); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(syncRequestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case res := <-resC:
+		return res, nil
+	case <-timeout.C:
+		return nil, ErrSyncTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// requestHeaders sends the headers request to the peer and waits for its reply
+func (n *Node) requestHeaders(ctx context.Context, p *Peer, req *GetBlockHeadersData) ([]*types.BlockHeader, error) {
+	res, err := n.sendRequest(ctx, p, GetBlockHeadersMsg, func(id uint64) interface{} {
+		req.RequestId = id
+		return req
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headers, ok := res.([]*types.BlockHeader)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected reply", ErrInvalidHeaders)
+	}
+	return headers, nil
+}
+
+// requestBlocks requests bodies of the headers from the peer and returns their blocks.
+// Bodies must match the header transactions and evidence hashes
+func (n *Node) requestBlocks(ctx context.Context, p *Peer, headers []*types.BlockHeader) ([]*types.Block, error) {
+	hashes := make([]common.Hash, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.BlockHash
+	}
+
+	res, err := n.sendRequest(ctx, p, GetBlockBodiesMsg, func(id uint64) interface{} {
+		return &GetBlockBodiesData{
+			RequestId: id,
+			Hashes:    hashes,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bodies, ok := res.([]*BlockBody)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected reply", ErrInvalidBodies)
+	}
+	if len(bodies) != len(headers) {
+		return nil, fmt.Errorf("%w: %d bodies of %d", ErrInvalidBodies, len(bodies), len(headers))
+	}
+
+	blocks := make([]*types.Block, len(headers))
+	for i, header := range headers {
+		block := types.NewBlock(*header, bodies[i].Transactions)
+		block.Evidence = bodies[i].Evidence
+
+		txHash, err := block.HashTransactions()
+		if err != nil {
+			return nil, err
+		}
+		evidenceHash, err := block.HashEvidence()
+		if err != nil {
+			return nil, err
+		}
+		if common.BytesToHash(txHash) != header.TxHash || evidenceHash != header.EvidenceHash {
+			return nil, fmt.Errorf("%w: block %d body does not match its header", ErrInvalidBodies, header.Number)
+		}
+
+		blocks[i] = block
+	}
+
+	return blocks, nil
+}
+
+// checkHeadersRange checks headers are the requested amount of consecutive linked headers from the number
+func checkHeadersRange(headers []*types.BlockHeader, from, amount uint64) error {
+	if uint64(len(headers)) != amount {
+		return fmt.Errorf("%w: %d headers of %d", ErrInvalidHeaders, len(headers), amount)
+	}
+
+	for i, header := range headers {
+		if header.Number != from+uint64(i) {
+			return fmt.Errorf("%w: header %d; expected: %d", ErrInvalidHeaders, header.Number, from+uint64(i))
+		}
+		if i > 0 && header.PrevHash != headers[i-1].BlockHash {
+			return fmt.Errorf("%w: header %d is not linked", ErrInvalidHeaders, header.Number)
+		}
+	}
+
+	return nil
+}
+
+// broadcastStatus sends the node chain status to connected peers, so they know its new chain tip
+func (n *Node) broadcastStatus(ctx context.Context) {
+	if n.peers.Len() == 0 {
+		return
+	}
+
+	status, err := n.localStatus(ctx)
+	if err != nil {
+		n.logger.Warnf("Unable to get node status: %s", err)
+		return
+	}
+
+	for _, p := range n.peers.List() {
+		if err := p2p.Send(p.rw, StatusMsg, status); err != nil {
+			n.logger.Debugw("Unable to send status to peer", "id", p.id, "err", err)
+		}
+	}
+}
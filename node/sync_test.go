@@ -0,0 +1,163 @@
+package node
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"github.com/rovergulf/chain/wallets"
+	"github.com/spf13/viper"
+	"math/big"
+	"testing"
+)
+
+// connectTestNodes registers nodes as handshaked peers of each other and runs their message loops
+func connectTestNodes(t *testing.T, a, b *Node) {
+	t.Helper()
+	ctx := context.Background()
+
+	pa, pb := newTestPeerPair(t)
+	statusA, err := a.localStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusB, err := b.localStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pa.id, pa.account = b.account.Address().Hex(), b.account.Address()
+	pb.id, pb.account = a.account.Address().Hex(), a.account.Address()
+	pa.SetStatus(statusB)
+	pb.SetStatus(statusA)
+
+	a.peers.Register(pa)
+	b.peers.Register(pb)
+	go a.runPeer(pa)
+	go b.runPeer(pb)
+}
+
+func TestSynchronise(t *testing.T) {
+	ctx := context.Background()
+
+	producer := newTestNode(t, nil, 0)
+	signer := producer.account.Address()
+	recipientKey, err := wallets.NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := recipientKey.Address
+
+	// every block rewards the producer with the minimal nether limit, so the transfer fee
+	// is paid once the producer earned params.NetherPrice rewards
+	blocks := params.NetherPrice + 8
+	for i := uint64(0); i < blocks; i++ {
+		if i == params.NetherPrice+1 {
+			tx, err := types.NewTransaction(signer, recipient, big.NewInt(5), producer.bc.GetNextAccountNonce(signer), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signedTx, err := producer.account.SignTx(&tx, producer.bc.Signer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := producer.AddLocalTX(ctx, *signedTx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := producer.generateBlock(ctx, producerConfig{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if balance, err := producer.bc.GetBalance(recipient); err != nil || balance.Balance.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("transfer is not sealed: %+v, %v", balance, err)
+	}
+
+	tests := []struct {
+		name  string
+		mode  SyncMode
+		key   *keystore.Key
+		check func(t *testing.T, n *Node)
+	}{
+		{
+			name: "default mode syncs headers only",
+			mode: SyncModeDefault,
+			check: func(t *testing.T, n *Node) {
+				head, err := n.bc.CurrentHeader()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if head.BlockHash != producer.bc.LastHash || n.bc.ChainLength != 1 {
+					t.Fatalf("expected header %s without blocks, got %s of length %d",
+						producer.bc.LastHash, head.BlockHash, n.bc.ChainLength)
+				}
+			},
+		},
+		{
+			name: "account mode proves wallet transactions",
+			mode: SyncModeAccount,
+			key:  recipientKey,
+			check: func(t *testing.T, n *Node) {
+				head, err := n.bc.CurrentHeader()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if head.BlockHash != producer.bc.LastHash || n.bc.ChainLength != 1 {
+					t.Fatalf("expected header %s without blocks, got %s of length %d",
+						producer.bc.LastHash, head.BlockHash, n.bc.ChainLength)
+				}
+				number, hash, err := n.bc.AccountSyncHead(recipient)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if number != head.Number || hash != head.BlockHash {
+					t.Fatalf("expected account synced up to %d, got %d", head.Number, number)
+				}
+				txs, _, err := n.bc.TransactionsByAddress(recipient, "", 0, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(txs) != 1 || txs[0].Tx.Value.Cmp(big.NewInt(5)) != 0 {
+					t.Fatalf("expected the transfer of 5, got %d transactions", len(txs))
+				}
+			},
+		},
+		{
+			name: "full mode executes blocks",
+			mode: SyncModeFull,
+			check: func(t *testing.T, n *Node) {
+				if n.bc.LastHash != producer.bc.LastHash || n.bc.ChainLength != producer.bc.ChainLength {
+					t.Fatalf("expected head %s, got %s of length %d", producer.bc.LastHash, n.bc.LastHash, n.bc.ChainLength)
+				}
+				for _, addr := range []common.Address{signer, recipient} {
+					expected, err := producer.bc.GetBalance(addr)
+					if err != nil {
+						t.Fatal(err)
+					}
+					balance, err := n.bc.GetBalance(addr)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if balance.Balance.Cmp(expected.Balance) != 0 || balance.Nonce != expected.Nonce {
+						t.Fatalf("%s: expected balance %s and nonce %d, got %s and %d",
+							addr, expected.Balance, expected.Nonce, balance.Balance, balance.Nonce)
+					}
+				}
+			},
+		},
+	}
+
+	defer viper.Set("node.sync_mode", SyncModeDefault.String())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("node.sync_mode", tt.mode.String())
+
+			n := newTestNode(t, tt.key, 0, signer)
+			connectTestNodes(t, producer, n)
+			if err := n.synchronise(ctx); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, n)
+		})
+	}
+}
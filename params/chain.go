@@ -8,6 +8,30 @@ const (
 const (
 	TxPerBlockLimit int = 2560
 
+	TxPoolGlobalSlots  int = 4096 // Maximum amount of pending and queued transactions in the pool
+	TxPoolAccountSlots int = 64   // Maximum amount of pending and queued transactions of a single account
+
+	TxPoolPriceBump uint64 = 10 // Minimal nether price increase percentage to replace pool transaction with the same nonce
+
+	TxPoolMaxNonceGap uint64 = 16 // Maximum distance of queued transaction nonce from the pending one
+
+	TxMaxSize int = 32 * 1024 // Maximum transaction encoded size in bytes
+
+	MaxReorgDepth uint64 = 64 // Maximum amount of canonical blocks may be dropped by chain reorganization
+
+	BlockTime int64 = 15 // Default seconds between sealed blocks
+
+	PoAEpoch uint64 = 30000 // Blocks interval of proof-of-authority checkpoints, which reset signers votes
+
+	PoAFinalityDepth uint64 = 32 // Confirmations after which proof-of-authority block is final, must not exceed MaxReorgDepth
+
+	MaxFutureBlockTime int64 = 15 // Maximum seconds block timestamp may be ahead of the local clock
+
+	EvidencePerBlockLimit int = 16  // Maximum amount of misbehavior evidence included in a single block
+	EvidencePoolSlots     int = 256 // Maximum amount of pending evidence kept by the node
+
+	SlashBurnPercent uint64 = 50 // Percentage of the offender slashed stake, which is burnt. The rest is paid to the block author
+
 	GenesisNetherLimit uint64 = 42e5 // Genesis block nether limit
 
 	NetherLimit uint64 = 48e3 // Minimal nether fee limit may ever be.
@@ -40,10 +40,18 @@ func SetDefaultConfigValues() {
 	viper.SetDefault("node.max_peers", 256)
 	viper.SetDefault("node.addr", "127.0.0.1")
 	viper.SetDefault("node.port", 9420)
-	viper.SetDefault("node.sync_mode", node.SyncModeDefault)
+	viper.SetDefault("node.sync_mode", node.SyncModeDefault.String())
 	viper.SetDefault("node.sync_interval", 5)
 	viper.SetDefault("node.cache_dir", "")
 	viper.SetDefault("node.no_discovery", false)
+	viper.SetDefault("node.block_time", params.BlockTime) // seconds
+	viper.SetDefault("node.skip_empty_blocks", true)
+	viper.SetDefault("node.dev_seal", false) // seal block on the first pool transaction
+
+	// transactions pool
+	viper.SetDefault("txpool.journal", "transactions.rlp")       // relative path is located in data_dir
+	viper.SetDefault("txpool.rejournal", 3600)                   // seconds
+	viper.SetDefault("txpool.pricebump", params.TxPoolPriceBump) // percents
 
 	// http server
 	viper.SetDefault("http.disabled", false)
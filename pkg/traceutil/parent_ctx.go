@@ -5,12 +5,13 @@ import (
 	"github.com/opentracing/opentracing-go"
 )
 
-// ProvideParentSpan returns parent span context option if it exists
+// ProvideParentSpan returns parent span context option if it exists,
+// otherwise it returns empty tags option, as tracers do not accept nil options
 func ProvideParentSpan(ctx context.Context) opentracing.StartSpanOption {
 	parentSpan := opentracing.SpanFromContext(ctx)
 	if parentSpan != nil {
 		return opentracing.ChildOf(parentSpan.Context())
 	}
 
-	return nil
+	return opentracing.Tags{}
 }
@@ -46,25 +46,13 @@ func (w *Wallet) Deserialize(data []byte) error {
 	return decoder.Decode(w)
 }
 
-func (w *Wallet) SignTx(tx *types.Transaction) (*types.SignedTx, error) {
+// SignTx signs transaction for the signer chain
+func (w *Wallet) SignTx(tx *types.Transaction, signer types.Signer) (*types.SignedTx, error) {
 	if w.key == nil {
 		return nil, ErrAccountIsLocked
 	}
 
-	rawTx, err := tx.Serialize()
-	if err != nil {
-		return nil, err
-	}
-
-	sig, err := Sign(rawTx, w.key.PrivateKey)
-	if err != nil {
-		return nil, err
-	}
-
-	return &types.SignedTx{
-		Transaction: *tx,
-		Sig:         sig,
-	}, nil
+	return signer.Sign(*tx, w.key.PrivateKey)
 }
 
 func (w *Wallet) Address() common.Address {
@@ -103,30 +91,14 @@ func (w *Wallet) EncryptKey() error {
 	return nil
 }
 
-func SignTx(tx types.Transaction, privKey *ecdsa.PrivateKey) ([]byte, error) {
-	rawTx, err := tx.Serialize()
-	if err != nil {
-		return nil, err
-	}
-
-	sig, err := Sign(rawTx, privKey)
-	if err != nil {
-		return nil, err
-	}
-
-	return sig, nil
-}
-
-func NewSignedTx(tx types.Transaction, privKey *ecdsa.PrivateKey) (types.SignedTx, error) {
-	sig, err := SignTx(tx, privKey)
+// NewSignedTx binds transaction to the signer chain and signs it
+func NewSignedTx(tx types.Transaction, signer types.Signer, privKey *ecdsa.PrivateKey) (types.SignedTx, error) {
+	signedTx, err := signer.Sign(tx, privKey)
 	if err != nil {
-		return types.SignedTx{}, nil
+		return types.SignedTx{}, err
 	}
 
-	return types.SignedTx{
-		Transaction: tx,
-		Sig:         sig,
-	}, nil
+	return *signedTx, nil
 }
 
 func Sign(msg []byte, privKey *ecdsa.PrivateKey) (sig []byte, err error) {
@@ -4,8 +4,12 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"errors"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rovergulf/chain/core/types"
+	"github.com/rovergulf/chain/params"
+	"math/big"
 	"testing"
 )
 
@@ -41,3 +45,108 @@ func TestSign(t *testing.T) {
 		t.Fatalf("msg was signed by account %s but signature recovery produced an account %s", account.Hex(), recoveredAccount.Hex())
 	}
 }
+
+func TestWalletSignTx(t *testing.T) {
+	key, err := NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Wallet{key: key}
+
+	tx, err := types.NewTransaction(w.Address(), common.HexToAddress("0x55"), big.NewInt(params.Raftel), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := types.NewSigner(big.NewInt(params.OpenDevNetworkId))
+	signedTx, err := w.SignTx(&tx, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signedTx.ChainId.Cmp(signer.ChainId()) != 0 {
+		t.Fatalf("tx is bound to chain %s, expected: %s", signedTx.ChainId, signer.ChainId())
+	}
+
+	ok, err := signedTx.IsAuthentic(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("tx signed by wallet is not authentic")
+	}
+
+	sender, err := signer.Sender(signedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender != w.Address() {
+		t.Fatalf("tx was signed by account %s but sender recovery produced an account %s", w.Address(), sender)
+	}
+}
+
+func TestSignedTxOtherChain(t *testing.T) {
+	key, err := NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := types.NewTransaction(key.Address, common.HexToAddress("0x55"), big.NewInt(params.Raftel), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx, err := NewSignedTx(tx, types.NewSigner(big.NewInt(params.OpenDevNetworkId)), key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := signedTx.IsAuthentic(types.NewSigner(big.NewInt(params.MainNetworkId)))
+	if ok || !errors.Is(err, types.ErrInvalidChainId) {
+		t.Fatalf("tx signed for other chain must be rejected, got: %v, %v", ok, err)
+	}
+}
+
+func TestSignedTxForged(t *testing.T) {
+	key, err := NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := types.NewTransaction(key.Address, common.HexToAddress("0x55"), big.NewInt(params.Raftel), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := types.NewSigner(big.NewInt(params.OpenDevNetworkId))
+	signedTx, err := NewSignedTx(tx, signer, key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx.Value = big.NewInt(params.Raftel * 2)
+	if ok, _ := signedTx.IsAuthentic(signer); ok {
+		t.Fatal("tx with changed value must not be authentic")
+	}
+}
+
+func TestSignTxErrors(t *testing.T) {
+	tx, err := types.NewTransaction(common.HexToAddress("0x54"), common.HexToAddress("0x55"), big.NewInt(params.Raftel), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := types.NewSigner(big.NewInt(params.OpenDevNetworkId))
+	if _, err := new(Wallet).SignTx(&tx, signer); err != ErrAccountIsLocked {
+		t.Fatalf("locked wallet must not sign tx, got: %v", err)
+	}
+
+	key, err := NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSignedTx(tx, types.NewSigner(nil), key.PrivateKey); !errors.Is(err, types.ErrInvalidChainId) {
+		t.Fatalf("tx must not be signed without chain id, got: %v", err)
+	}
+}